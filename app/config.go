@@ -0,0 +1,423 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/rs/zerolog"
+	"gopkg.in/yaml.v3"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"xm/client"
+	"xm/policy"
+	"xm/ratelimit"
+)
+
+// envPrefix starts the environment variables of the settings
+const envPrefix = "XM_"
+
+// redacted replaces the values of the secrets when the config is printed
+const redacted = "******"
+
+// secrets tag the settings that are redacted when the config is printed
+const (
+	// secretValue redacts the whole value
+	secretValue = "true"
+	// secretDSN only redacts the password of a data source name
+	secretDSN = "dsn"
+)
+
+var (
+	// dsnPasswordPattern matches the password of a key/value DSN, e.g. host=localhost password=secret
+	dsnPasswordPattern = regexp.MustCompile(`(?i)(password=)('[^']*'|\S+)`)
+	// dsnUserPasswordPattern matches the password of a MySQL DSN, e.g. xm:secret@tcp(localhost:3306)/xm
+	dsnUserPasswordPattern = regexp.MustCompile(`^([^:@/]*):[^@]*@`)
+)
+
+// DefaultConfig returns the config of the settings that aren't set
+func DefaultConfig() Config {
+	return Config{
+		APIPort:                    "8080",
+		LogLevel:                   zerolog.DebugLevel,
+		DatabaseDialect:            DialectSQLite,
+		DatabaseDSN:                "xm.db",
+		DatabaseMaxIdleConns:       2,
+		ShutdownGracePeriod:        30 * time.Second,
+		ShutdownDelay:              5 * time.Second,
+		PurgeRetention:             30 * 24 * time.Hour,
+		OriginCountry:              "CY",
+		AccessPolicyReloadInterval: time.Minute,
+		IPLocationProviders:        []string{client.ProviderIPAPI},
+		IPLocationStrategy:         string(client.FallbackOnError),
+		IPAPIURL:                   "https://ipapi.co",
+		IPAPIComURL:                "http://ip-api.com",
+		IPInfoURL:                  "https://ipinfo.io",
+	}
+}
+
+// setting is a field of the config
+type setting struct {
+	// name is the name of the setting in the config file
+	name   string
+	env    string
+	flag   string
+	secret string
+	index  int
+}
+
+// settings returns the settings of the fields of the config with a config tag
+func settings() []setting {
+	var settings []setting
+	configType := reflect.TypeOf(Config{})
+	for index := 0; index < configType.NumField(); index++ {
+		field := configType.Field(index)
+		name := field.Tag.Get("config")
+		if len(name) == 0 {
+			continue
+		}
+		words := splitCamelCase(name)
+		settings = append(settings, setting{
+			name:   name,
+			env:    envPrefix + strings.ToUpper(strings.Join(words, "_")),
+			flag:   strings.ToLower(strings.Join(words, "-")),
+			secret: field.Tag.Get("secret"),
+			index:  index,
+		})
+	}
+	return settings
+}
+
+// splitCamelCase splits a camel case name into its words, e.g. ipApiComUrl into ip, Api, Com and Url
+func splitCamelCase(name string) []string {
+	var words []string
+	start := 0
+	for index, r := range name {
+		if index > 0 && unicode.IsUpper(r) {
+			words = append(words, name[start:index])
+			start = index
+		}
+	}
+	return append(words, name[start:])
+}
+
+// ConfigLoader loads the config, layering from the lowest to the highest precedence the defaults, a YAML or TOML config
+// file, the XM_* environment variables and the command line flags
+type ConfigLoader struct {
+	flags    *flag.FlagSet
+	settings []setting
+	values   map[string]*string
+}
+
+// NewConfigLoader returns a loader defining a flag of every setting in the flag set, which must be parsed before the
+// config is loaded
+func NewConfigLoader(flags *flag.FlagSet) *ConfigLoader {
+	loader := &ConfigLoader{flags: flags, settings: settings(), values: map[string]*string{}}
+	for _, setting := range loader.settings {
+		loader.values[setting.name] = flags.String(setting.flag, "", fmt.Sprintf("%s (%s)", setting.name, setting.env))
+	}
+	return loader
+}
+
+// Load returns the validated config, the config file is optional. Lists are comma separated and maps are comma
+// separated key=value pairs in the environment variables and the flags.
+func (loader *ConfigLoader) Load(file string, lookupEnv func(string) (string, bool)) (Config, error) {
+	config := DefaultConfig()
+	value := reflect.ValueOf(&config).Elem()
+
+	if len(file) > 0 {
+		values, err := readConfigFile(file)
+		if err != nil {
+			return Config{}, err
+		}
+		for _, setting := range loader.settings {
+			if fileValue, ok := values[setting.name]; ok {
+				if err := setFileValue(value.Field(setting.index), fileValue); err != nil {
+					return Config{}, fmt.Errorf("invalid %s in %s: %w", setting.name, file, err)
+				}
+				delete(values, setting.name)
+			}
+		}
+		if len(values) > 0 {
+			names := make([]string, 0, len(values))
+			for name := range values {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return Config{}, fmt.Errorf("unknown settings in %s: %s", file, strings.Join(names, ", "))
+		}
+	}
+
+	for _, setting := range loader.settings {
+		if envValue, ok := lookupEnv(setting.env); ok {
+			if err := setString(value.Field(setting.index), envValue); err != nil {
+				return Config{}, fmt.Errorf("invalid %s: %w", setting.env, err)
+			}
+		}
+	}
+
+	var err error
+	loader.flags.Visit(func(visited *flag.Flag) {
+		for _, setting := range loader.settings {
+			if visited.Name == setting.flag && err == nil {
+				if setErr := setString(value.Field(setting.index), *loader.values[setting.name]); setErr != nil {
+					err = fmt.Errorf("invalid --%s: %w", setting.flag, setErr)
+				}
+			}
+		}
+	})
+	if err != nil {
+		return Config{}, err
+	}
+
+	return config, config.Validate()
+}
+
+// readConfigFile reads the settings of the YAML (.yaml, .yml) or TOML (.toml) config file
+func readConfigFile(file string) (map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read config file: %w", err)
+	}
+
+	values := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &values)
+	case ".toml":
+		_, err = toml.Decode(string(data), &values)
+	default:
+		return nil, fmt.Errorf("unknown format of config file %s, expected .yaml, .yml or .toml", file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", file, err)
+	}
+	return values, nil
+}
+
+// setFileValue sets the field to a value decoded from the config file
+func setFileValue(field reflect.Value, value interface{}) error {
+	switch value := value.(type) {
+	case string:
+		return setString(field, value)
+	case bool, int, int64, float64:
+		if field.Kind() == reflect.Slice || field.Kind() == reflect.Map {
+			return fmt.Errorf("expected a %s, got %v", field.Kind(), value)
+		}
+		return setString(field, fmt.Sprint(value))
+	case []interface{}:
+		if field.Kind() != reflect.Slice {
+			return fmt.Errorf("expected a %s, got a list", field.Kind())
+		}
+		elements := make([]string, len(value))
+		for index, element := range value {
+			elements[index] = fmt.Sprint(element)
+		}
+		field.Set(reflect.ValueOf(elements))
+		return nil
+	case map[string]interface{}:
+		if field.Kind() != reflect.Map {
+			return fmt.Errorf("expected a %s, got a map", field.Kind())
+		}
+		pairs := map[string]string{}
+		for key, element := range value {
+			pairs[key] = fmt.Sprint(element)
+		}
+		field.Set(reflect.ValueOf(pairs))
+		return nil
+	default:
+		return fmt.Errorf("unexpected value %v", value)
+	}
+}
+
+// setString sets the field to a value written as a string
+func setString(field reflect.Value, value string) error {
+	switch field.Type() {
+	case reflect.TypeOf(zerolog.Level(0)):
+		level, err := zerolog.ParseLevel(strings.ToLower(value))
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(level))
+		return nil
+	case reflect.TypeOf(time.Duration(0)):
+		duration, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(duration))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int:
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(number))
+	case reflect.Bool:
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(enabled)
+	case reflect.Slice:
+		field.Set(reflect.ValueOf(splitList(value)))
+	case reflect.Map:
+		pairs := map[string]string{}
+		for _, element := range splitList(value) {
+			index := strings.Index(element, "=")
+			if index <= 0 {
+				return fmt.Errorf("expected key=value, got %q", element)
+			}
+			pairs[strings.TrimSpace(element[:index])] = strings.TrimSpace(element[index+1:])
+		}
+		field.Set(reflect.ValueOf(pairs))
+	default:
+		return fmt.Errorf("unsupported setting of kind %s", field.Kind())
+	}
+	return nil
+}
+
+// splitList splits a comma separated list, ignoring empty elements
+func splitList(list string) []string {
+	elements := []string{}
+	for _, element := range strings.Split(list, ",") {
+		if element = strings.TrimSpace(element); len(element) > 0 {
+			elements = append(elements, element)
+		}
+	}
+	return elements
+}
+
+// Validate returns the errors of all the invalid settings of the config
+func (config Config) Validate() error {
+	var problems []string
+	if port, err := strconv.Atoi(config.APIPort); err != nil || port <= 0 || port > 65535 {
+		problems = append(problems, fmt.Sprintf("apiPort: %q isn't a port", config.APIPort))
+	}
+	switch config.DatabaseDialect {
+	case DialectSQLite, DialectPostgres, DialectMySQL:
+	default:
+		problems = append(problems, fmt.Sprintf("databaseDialect: unknown dialect %q", config.DatabaseDialect))
+	}
+	if len(config.DatabaseDSN) == 0 {
+		problems = append(problems, "databaseDsn: required")
+	}
+	if config.DatabaseMaxOpenConns < 0 || config.DatabaseMaxIdleConns < 0 || config.DatabaseConnMaxLifetime < 0 {
+		problems = append(problems, "databaseMaxOpenConns, databaseMaxIdleConns, databaseConnMaxLifetime: mustn't be negative")
+	}
+	if config.ShutdownGracePeriod <= 0 {
+		problems = append(problems, "shutdownGracePeriod: must be positive")
+	}
+	if config.ShutdownDelay < 0 {
+		problems = append(problems, "shutdownDelay: mustn't be negative")
+	}
+	if config.PurgeRetention <= 0 {
+		problems = append(problems, "purgeRetention: must be positive")
+	}
+	if len(config.OriginCountry) == 0 {
+		problems = append(problems, "originCountry: required")
+	}
+	for _, provider := range config.IPLocationProviders {
+		switch provider {
+		case client.ProviderIPAPI, "ipapi", client.ProviderIPAPICom, client.ProviderIPInfo:
+		case client.ProviderMMDB:
+			if len(config.IPLocationDatabase) == 0 {
+				problems = append(problems, "ipLocationDatabase: required by the mmdb provider")
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("ipLocationProviders: unknown provider %q", provider))
+		}
+	}
+	switch client.ChainStrategy(config.IPLocationStrategy) {
+	case client.FallbackOnError, client.FirstSuccess, client.MajorityVote:
+	default:
+		problems = append(problems, fmt.Sprintf("ipLocationStrategy: unknown strategy %q", config.IPLocationStrategy))
+	}
+	if config.AccessPolicyReloadInterval < 0 {
+		problems = append(problems, "accessPolicyReloadInterval: mustn't be negative")
+	}
+	if _, err := policy.ParseNetworks(config.TrustedProxies); err != nil {
+		problems = append(problems, fmt.Sprintf("trustedProxies: %v", err))
+	}
+	if len(config.JWKS) > 0 && (len(config.JWTIssuer) == 0 || len(config.JWTAudience) == 0) {
+		problems = append(problems, "jwtIssuer, jwtAudience: required by jwks")
+	}
+	for route, limit := range config.RateLimits {
+		if _, err := ratelimit.ParseLimit(limit); err != nil {
+			problems = append(problems, fmt.Sprintf("rateLimits: %s: %v", route, err))
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return errors.New("invalid config: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// Print writes the config as YAML, in the order of the settings, with the values of the secrets redacted
+func (config Config) Print() ([]byte, error) {
+	document := &yaml.Node{Kind: yaml.MappingNode}
+	value := reflect.ValueOf(config)
+	for _, setting := range settings() {
+		var settingValue interface{} = value.Field(setting.index).Interface()
+		switch typed := settingValue.(type) {
+		case zerolog.Level:
+			settingValue = typed.String()
+		case time.Duration:
+			settingValue = typed.String()
+		}
+		if !value.Field(setting.index).IsZero() {
+			switch setting.secret {
+			case secretValue:
+				settingValue = redacted
+			case secretDSN:
+				settingValue = redactDSN(settingValue.(string))
+			}
+		}
+
+		valueNode := &yaml.Node{}
+		if err := valueNode.Encode(settingValue); err != nil {
+			return nil, err
+		}
+		document.Content = append(document.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: setting.name}, valueNode)
+	}
+
+	var buffer bytes.Buffer
+	encoder := yaml.NewEncoder(&buffer)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(document); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), encoder.Close()
+}
+
+// redactDSN returns the data source name where the password has been redacted, whether it's a URL, key/value pairs or
+// a MySQL DSN
+func redactDSN(dsn string) string {
+	if strings.Contains(dsn, "://") {
+		if dsnURL, err := url.Parse(dsn); err == nil {
+			if _, hasPassword := dsnURL.User.Password(); hasPassword {
+				// the password is replaced after the URL is written, so that redacted isn't escaped
+				dsnURL.User = url.UserPassword(dsnURL.User.Username(), "password")
+				return strings.Replace(dsnURL.String(), ":password@", ":"+redacted+"@", 1)
+			}
+			return dsnURL.String()
+		}
+	}
+	dsn = dsnPasswordPattern.ReplaceAllString(dsn, "${1}"+redacted)
+	return dsnUserPasswordPattern.ReplaceAllString(dsn, "${1}:"+redacted+"@")
+}
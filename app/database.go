@@ -0,0 +1,44 @@
+package app
+
+import (
+	"fmt"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// dialects of the supported databases
+const (
+	DialectSQLite   = "sqlite"
+	DialectPostgres = "postgres"
+	DialectMySQL    = "mysql"
+)
+
+// openDatabase connects to the database of the configured dialect and sets up its connection pool
+func openDatabase(config Config) (*gorm.DB, error) {
+	var dialector gorm.Dialector
+	switch config.DatabaseDialect {
+	case DialectSQLite:
+		dialector = sqlite.Open(config.DatabaseDSN)
+	case DialectPostgres:
+		dialector = postgres.Open(config.DatabaseDSN)
+	case DialectMySQL:
+		dialector = mysql.Open(config.DatabaseDSN)
+	default:
+		return nil, fmt.Errorf("unknown database dialect %q", config.DatabaseDialect)
+	}
+
+	db, err := gorm.Open(dialector, &gorm.Config{})
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(config.DatabaseMaxOpenConns)
+	sqlDB.SetMaxIdleConns(config.DatabaseMaxIdleConns)
+	sqlDB.SetConnMaxLifetime(config.DatabaseConnMaxLifetime)
+	return db, nil
+}
@@ -0,0 +1,174 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Version is the version of the service, set at build time with -ldflags "-X xm/app.Version=1.2.0"
+var Version = "dev"
+
+// healthCheckTimeout is how long every health checker has to complete
+const healthCheckTimeout = 2 * time.Second
+
+// health statuses of the checks and of the app
+const (
+	HealthStatusOK   = "ok"
+	HealthStatusFail = "fail"
+)
+
+// errNotReady is the error of the readiness of an app that hasn't started serving requests or that is shutting down
+var errNotReady = errors.New("not serving requests")
+
+// HealthChecker checks a dependency the app needs to serve requests, e.g. its database
+type HealthChecker interface {
+	CheckHealth(ctx context.Context) error
+}
+
+// HealthCheckerFunc adapts a function to a HealthChecker
+type HealthCheckerFunc func(ctx context.Context) error
+
+// CheckHealth calls the function
+func (f HealthCheckerFunc) CheckHealth(ctx context.Context) error {
+	return f(ctx)
+}
+
+type healthChecker struct {
+	name    string
+	checker HealthChecker
+}
+
+// HealthCheck is the result of a health checker
+type HealthCheck struct {
+	Status string `json:"status"`
+	// Latency is the time the check took, e.g. "1.2ms"
+	Latency string `json:"latency"`
+	Error   string `json:"error,omitempty"`
+}
+
+// HealthReport is the response of /healthz and /readyz
+type HealthReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]HealthCheck `json:"checks,omitempty"`
+}
+
+// BuildInfo is the response of /version
+type BuildInfo struct {
+	Version string `json:"version"`
+	// ModuleVersion is the version of the main module, known when the service has been built with go install
+	ModuleVersion string `json:"moduleVersion,omitempty"`
+	GoVersion     string `json:"goVersion"`
+}
+
+// RegisterHealthChecker adds a checker to the readiness of the app, /readyz fails while any checker fails
+func (app *App) RegisterHealthChecker(name string, checker HealthChecker) {
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	app.healthCheckers = append(app.healthCheckers, healthChecker{name: name, checker: checker})
+}
+
+// registerHealthRoutes registers /healthz, /readyz and /version, along with the health checker of the database
+func (app *App) registerHealthRoutes() {
+	app.RegisterHealthChecker("database", HealthCheckerFunc(app.pingDatabase))
+	app.Router.HandleFunc("/healthz", app.liveness).Methods(http.MethodGet)
+	app.Router.HandleFunc("/readyz", app.readiness).Methods(http.MethodGet)
+	app.Router.HandleFunc("/version", app.version).Methods(http.MethodGet)
+}
+
+// pingDatabase checks that a connection to the database can be established
+func (app *App) pingDatabase(ctx context.Context) error {
+	sqlDB, err := app.DB.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// liveness reports that the process is alive and able to handle requests, it doesn't check any dependency
+func (app *App) liveness(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, HealthReport{Status: HealthStatusOK})
+}
+
+// readiness reports whether the app serves requests and all its health checkers pass, with the result of every check
+func (app *App) readiness(w http.ResponseWriter, r *http.Request) {
+	report := app.CheckHealth(r.Context())
+	code := http.StatusOK
+	if report.Status != HealthStatusOK {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, report)
+}
+
+// CheckHealth runs the health checkers concurrently, each of them for at most healthCheckTimeout, and reports the
+// readiness of the app
+func (app *App) CheckHealth(ctx context.Context) HealthReport {
+	app.mutex.Lock()
+	checkers := app.healthCheckers
+	app.mutex.Unlock()
+
+	report := HealthReport{Status: HealthStatusOK, Checks: map[string]HealthCheck{}}
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for _, checker := range checkers {
+		wg.Add(1)
+		go func(checker healthChecker) {
+			defer wg.Done()
+			check := runHealthChecker(ctx, checker.checker)
+			mutex.Lock()
+			defer mutex.Unlock()
+			report.Checks[checker.name] = check
+			if check.Status != HealthStatusOK {
+				report.Status = HealthStatusFail
+			}
+		}(checker)
+	}
+	wg.Wait()
+
+	if !app.Ready() {
+		report.Status = HealthStatusFail
+		report.Checks["server"] = HealthCheck{Status: HealthStatusFail, Latency: time.Duration(0).String(), Error: errNotReady.Error()}
+	}
+	return report
+}
+
+// runHealthChecker runs the checker with a timeout and measures its latency
+func runHealthChecker(ctx context.Context, checker HealthChecker) HealthCheck {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	err := checker.CheckHealth(ctx)
+	check := HealthCheck{Status: HealthStatusOK, Latency: time.Since(start).String()}
+	if err != nil {
+		check.Status, check.Error = HealthStatusFail, err.Error()
+	}
+	return check
+}
+
+// version returns the build info of the service
+func (app *App) version(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, GetBuildInfo())
+}
+
+// GetBuildInfo returns the version of the service, and the version of its main module when it's known
+func GetBuildInfo() BuildInfo {
+	buildInfo := BuildInfo{Version: Version, GoVersion: runtime.Version()}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "(devel)" {
+		buildInfo.ModuleVersion = info.Main.Version
+	}
+	return buildInfo
+}
+
+// writeJSON writes the response as JSON, the health endpoints are never cached
+func writeJSON(w http.ResponseWriter, code int, response interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(response)
+}
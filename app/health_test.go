@@ -0,0 +1,117 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/rs/zerolog"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newHealthTestApp returns an initialized app with an in-memory database and the checkers
+func newHealthTestApp(t *testing.T, ready bool, checkers map[string]HealthChecker) *App {
+	config := DefaultConfig()
+	config.DatabaseDSN = "file::memory:"
+	db, err := openDatabase(config)
+	if err != nil {
+		t.Fatalf("unable to open database: %v", err)
+	}
+	logger := zerolog.New(ioutil.Discard)
+	app := &App{name: "XM", config: config, DB: db, Logger: &logger}
+	app.Initialize(nil)
+	for name, checker := range checkers {
+		app.RegisterHealthChecker(name, checker)
+	}
+	if ready {
+		atomic.StoreInt32(&app.ready, 1)
+	}
+	return app
+}
+
+func TestHealthEndpoints(t *testing.T) {
+	passing := HealthCheckerFunc(func(ctx context.Context) error { return nil })
+	failing := HealthCheckerFunc(func(ctx context.Context) error { return errors.New("unreachable") })
+	hanging := HealthCheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	tests := []struct {
+		name           string
+		url            string
+		ready          bool
+		checkers       map[string]HealthChecker
+		closeDB        bool
+		expectedCode   int
+		expectedStatus string
+		expectedChecks map[string]string
+	}{
+		{"+ve:ShouldBeAliveWhenChecksFail", "/healthz", false, map[string]HealthChecker{"ipLocation": failing}, false, http.StatusOK, HealthStatusOK, map[string]string{}},
+		{"+ve:ShouldBeReadyWhenChecksPass", "/readyz", true, map[string]HealthChecker{"ipLocation": passing}, false, http.StatusOK, HealthStatusOK, map[string]string{"database": HealthStatusOK, "ipLocation": HealthStatusOK}},
+		{"-ve:ShouldNotBeReadyWhenCheckFails", "/readyz", true, map[string]HealthChecker{"ipLocation": failing}, false, http.StatusServiceUnavailable, HealthStatusFail, map[string]string{"database": HealthStatusOK, "ipLocation": HealthStatusFail}},
+		{"-ve:ShouldNotBeReadyWhenDatabaseIsClosed", "/readyz", true, nil, true, http.StatusServiceUnavailable, HealthStatusFail, map[string]string{"database": HealthStatusFail}},
+		{"-ve:ShouldNotBeReadyWhenNotServing", "/readyz", false, nil, false, http.StatusServiceUnavailable, HealthStatusFail, map[string]string{"database": HealthStatusOK, "server": HealthStatusFail}},
+		{"-ve:ShouldTimeOutHangingCheck", "/readyz", true, map[string]HealthChecker{"ipLocation": hanging}, false, http.StatusServiceUnavailable, HealthStatusFail, map[string]string{"database": HealthStatusOK, "ipLocation": HealthStatusFail}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newHealthTestApp(t, tt.ready, tt.checkers)
+			if tt.closeDB {
+				sqlDB, _ := app.DB.DB()
+				sqlDB.Close()
+			}
+			req, _ := http.NewRequest(http.MethodGet, tt.url, nil)
+			rr := httptest.NewRecorder()
+
+			start := time.Now()
+			app.Router.ServeHTTP(rr, req)
+
+			if time.Since(start) > healthCheckTimeout+time.Second {
+				t.Errorf("expected the checks to time out after %v, took %v", healthCheckTimeout, time.Since(start))
+			}
+			if rr.Code != tt.expectedCode {
+				t.Errorf("expected response code %d, got %d", tt.expectedCode, rr.Code)
+			}
+			var report HealthReport
+			if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+				t.Fatalf("unable to decode %s: %v", rr.Body.String(), err)
+			}
+			if report.Status != tt.expectedStatus {
+				t.Errorf("expected status %s, got %s", tt.expectedStatus, report.Status)
+			}
+			if len(report.Checks) != len(tt.expectedChecks) {
+				t.Errorf("expected checks %v, got %+v", tt.expectedChecks, report.Checks)
+			}
+			for name, status := range tt.expectedChecks {
+				check := report.Checks[name]
+				if check.Status != status || len(check.Latency) == 0 {
+					t.Errorf("expected check %s to be %s with its latency, got %+v", name, status, check)
+				}
+				if status == HealthStatusFail && len(check.Error) == 0 {
+					t.Errorf("expected the error of check %s, got %+v", name, check)
+				}
+			}
+		})
+	}
+}
+
+func TestVersionEndpoint(t *testing.T) {
+	app := newHealthTestApp(t, true, nil)
+	req, _ := http.NewRequest(http.MethodGet, "/version", nil)
+	rr := httptest.NewRecorder()
+
+	app.Router.ServeHTTP(rr, req)
+
+	var buildInfo BuildInfo
+	if err := json.Unmarshal(rr.Body.Bytes(), &buildInfo); err != nil {
+		t.Fatalf("unable to decode %s: %v", rr.Body.String(), err)
+	}
+	if rr.Code != http.StatusOK || buildInfo.Version != Version || len(buildInfo.GoVersion) == 0 {
+		t.Errorf("expected the build info, got %d %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,138 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// ShutdownHook releases a resource when the app shuts down, it should return when ctx is done
+type ShutdownHook func(ctx context.Context) error
+
+type shutdownHook struct {
+	name string
+	hook ShutdownHook
+}
+
+// OnShutdown registers a hook run when the app shuts down, after the in-flight requests have been drained and before
+// the database is closed. The hooks run in the reverse order of their registration.
+func (app *App) OnShutdown(name string, hook ShutdownHook) {
+	app.mutex.Lock()
+	defer app.mutex.Unlock()
+	app.shutdownHooks = append(app.shutdownHooks, shutdownHook{name: name, hook: hook})
+}
+
+// Ready reports whether the app serves requests, it's false before the app has started and once it shuts down
+func (app *App) Ready() bool {
+	return atomic.LoadInt32(&app.ready) == 1
+}
+
+// Run starts the http server and blocks until SIGINT or SIGTERM is received, or the server fails, then it shuts the
+// app down. The signals are registered before the server starts, so that none of them is missed.
+func (app *App) Run() error {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	listener, err := net.Listen("tcp", app.server.Addr)
+	if err != nil {
+		app.Stop()
+		return fmt.Errorf("unable to listen on %s: %w", app.server.Addr, err)
+	}
+	return app.run(listener, signals)
+}
+
+// run serves the requests of the listener until a signal is received or the server fails
+func (app *App) run(listener net.Listener, signals <-chan os.Signal) error {
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- app.serve(listener)
+	}()
+	app.Logger.Info().Msg(app.name + " service started successfully")
+
+	select {
+	case received := <-signals:
+		app.Logger.Info().Str("signal", received.String()).Msg("shutting down")
+		return app.Stop()
+	case err := <-serveErr:
+		app.Stop()
+		return fmt.Errorf("unable to serve: %w", err)
+	}
+}
+
+// Start http server and start listening to the requests
+func (app *App) Start() {
+	listener, err := net.Listen("tcp", app.server.Addr)
+	if err != nil {
+		app.Logger.Fatal().Err(err).Msg("Unable to start server, exiting the application!")
+	}
+	if err := app.serve(listener); err != nil {
+		app.Logger.Fatal().Err(err).Msg("Unable to start server, exiting the application!")
+	}
+}
+
+// serve marks the app ready and serves the requests of the listener until the server is shut down
+func (app *App) serve(listener net.Listener) error {
+	atomic.StoreInt32(&app.ready, 1)
+	if err := app.server.Serve(listener); err != http.ErrServerClosed {
+		atomic.StoreInt32(&app.ready, 0)
+		return err
+	}
+	return nil
+}
+
+// Stop shuts the app down once: it marks the app not ready and, when it was serving, keeps serving for the shutdown
+// delay, so that the load balancers notice it. Then it drains the in-flight requests for the grace period (the
+// connections still open after it are closed), runs the shutdown hooks in reverse order and closes the database
+// connection pool. The first error is returned, the others are logged.
+func (app *App) Stop() error {
+	app.stopOnce.Do(func() {
+		if atomic.SwapInt32(&app.ready, 0) == 1 {
+			time.Sleep(app.config.ShutdownDelay)
+		}
+		gracePeriod := app.config.ShutdownGracePeriod
+
+		var errs []error
+		if app.server != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+			if err := app.server.Shutdown(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("unable to drain requests: %w", err))
+				app.server.Close()
+			}
+			cancel()
+		}
+
+		app.mutex.Lock()
+		hooks := app.shutdownHooks
+		app.mutex.Unlock()
+		for index := len(hooks) - 1; index >= 0; index-- {
+			ctx, cancel := context.WithTimeout(context.Background(), gracePeriod)
+			if err := hooks[index].hook(ctx); err != nil {
+				errs = append(errs, fmt.Errorf("shutdown hook %s failed: %w", hooks[index].name, err))
+			}
+			cancel()
+		}
+
+		if app.DB != nil {
+			if sqlDB, err := app.DB.DB(); err != nil {
+				errs = append(errs, fmt.Errorf("unable to close database: %w", err))
+			} else if err := sqlDB.Close(); err != nil {
+				errs = append(errs, fmt.Errorf("unable to close database: %w", err))
+			}
+		}
+
+		for _, err := range errs {
+			app.Logger.Err(err).Msg("unable to shut down cleanly")
+		}
+		if len(errs) > 0 {
+			app.stopErr = errs[0]
+		}
+	})
+	return app.stopErr
+}
@@ -0,0 +1,168 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"github.com/rs/zerolog"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// newLifecycleTestApp returns an app serving handler on an ephemeral port with an in-memory database
+func newLifecycleTestApp(t *testing.T, gracePeriod time.Duration, handler http.Handler) (*App, net.Listener) {
+	config := DefaultConfig()
+	config.DatabaseDSN = "file::memory:"
+	config.ShutdownGracePeriod = gracePeriod
+	config.ShutdownDelay = 0
+	db, err := openDatabase(config)
+	if err != nil {
+		t.Fatalf("unable to open database: %v", err)
+	}
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unable to listen: %v", err)
+	}
+	logger := zerolog.New(ioutil.Discard)
+	app := &App{name: "XM", config: config, DB: db, Logger: &logger, server: &http.Server{Handler: handler}}
+	return app, listener
+}
+
+func TestRunShutdown(t *testing.T) {
+	tests := []struct {
+		name          string
+		gracePeriod   time.Duration
+		hookErr       error
+		expectedErr   string
+		expectedBody  string
+		expectedHooks []string
+	}{
+		{"+ve:ShouldDrainRequestsAndRunHooksInReverse", time.Second, nil, "", "done", []string{"second", "first"}},
+		{"-ve:ShouldFailWhenRequestsOutlastGracePeriod", 10 * time.Millisecond, nil, "unable to drain requests", "", []string{"second", "first"}},
+		{"-ve:ShouldFailWhenHookFails", time.Second, errors.New("broken"), "shutdown hook second failed: broken", "done", []string{"second", "first"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			started, release := make(chan struct{}), make(chan struct{})
+			app, listener := newLifecycleTestApp(t, tt.gracePeriod, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				close(started)
+				<-release
+				w.Write([]byte("done"))
+			}))
+			var hooks []string
+			app.OnShutdown("first", func(ctx context.Context) error {
+				hooks = append(hooks, "first")
+				return nil
+			})
+			app.OnShutdown("second", func(ctx context.Context) error {
+				hooks = append(hooks, "second")
+				return tt.hookErr
+			})
+
+			signals := make(chan os.Signal, 1)
+			runErr := make(chan error, 1)
+			go func() { runErr <- app.run(listener, signals) }()
+
+			body := make(chan string, 1)
+			go func() {
+				resp, err := http.Get("http://" + listener.Addr().String())
+				if err != nil {
+					body <- ""
+					return
+				}
+				defer resp.Body.Close()
+				content, _ := ioutil.ReadAll(resp.Body)
+				body <- string(content)
+			}()
+			<-started
+			if !app.Ready() {
+				t.Errorf("expected the app to be ready while serving")
+			}
+
+			signals <- syscall.SIGTERM
+			time.Sleep(50 * time.Millisecond)
+			if app.Ready() {
+				t.Errorf("expected the app not to be ready once it shuts down")
+			}
+			close(release)
+
+			err := <-runErr
+			if len(tt.expectedErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Errorf("expected error %q, got %v", tt.expectedErr, err)
+				}
+			} else if err != nil {
+				t.Errorf("expected no error, got %v", err)
+			}
+			if content := <-body; content != tt.expectedBody {
+				t.Errorf("expected response %q, got %q", tt.expectedBody, content)
+			}
+			if !reflect.DeepEqual(tt.expectedHooks, hooks) {
+				t.Errorf("expected hooks %v, got %v", tt.expectedHooks, hooks)
+			}
+			sqlDB, _ := app.DB.DB()
+			if sqlDB.Ping() == nil {
+				t.Errorf("expected the database to be closed")
+			}
+		})
+	}
+}
+
+func TestStopOnce(t *testing.T) {
+	app, listener := newLifecycleTestApp(t, time.Second, http.NotFoundHandler())
+	listener.Close()
+	calls := 0
+	app.OnShutdown("count", func(ctx context.Context) error {
+		calls++
+		return nil
+	})
+
+	firstErr, secondErr := app.Stop(), app.Stop()
+
+	if firstErr != nil || secondErr != nil {
+		t.Errorf("expected no error, got %v and %v", firstErr, secondErr)
+	}
+	if calls != 1 {
+		t.Errorf("expected the hooks to run once, got %d", calls)
+	}
+}
+
+func TestShutdownDelay(t *testing.T) {
+	delay := 200 * time.Millisecond
+	app, listener := newLifecycleTestApp(t, time.Second, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("done"))
+	}))
+	app.config.ShutdownDelay = delay
+
+	signals := make(chan os.Signal, 1)
+	runErr := make(chan error, 1)
+	go func() { runErr <- app.run(listener, signals) }()
+	for !app.Ready() {
+		time.Sleep(time.Millisecond)
+	}
+
+	stopping := time.Now()
+	signals <- syscall.SIGTERM
+	time.Sleep(50 * time.Millisecond)
+	if app.Ready() {
+		t.Errorf("expected the app not to be ready once it shuts down")
+	}
+	resp, err := http.Get("http://" + listener.Addr().String())
+	if err != nil {
+		t.Fatalf("expected requests to be served during the shutdown delay, got %v", err)
+	}
+	resp.Body.Close()
+
+	if err := <-runErr; err != nil {
+		t.Errorf("expected no error, got %v", err)
+	}
+	if elapsed := time.Since(stopping); elapsed < delay {
+		t.Errorf("expected the server to be shut down after %v, got %v", delay, elapsed)
+	}
+}
@@ -2,8 +2,8 @@ package app
 
 import (
 	"fmt"
+	"github.com/gorilla/mux"
 	"github.com/rs/zerolog"
-	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 	"math/rand"
 	"os"
@@ -11,6 +11,9 @@ import (
 	"xm/log"
 )
 
+// testDatabaseFile is the SQLite database of the tests, unless another database is set
+const testDatabaseFile = "./test.db"
+
 // TestApp Provides convenience methods for test
 type TestApp struct {
 	Application             *App
@@ -18,18 +21,26 @@ type TestApp struct {
 	dbInitializer           func(db *gorm.DB)
 }
 
+// NewTestApp returns an app testing the routes against the SQLite file test.db, or against the database set with the
+// XM_TEST_DATABASE_DIALECT and XM_TEST_DATABASE_DSN environment variables
 func NewTestApp(name string, controllerRouteProvider func(*App) []RouteSpecifier, dbInitializer func(db *gorm.DB)) *TestApp {
-	dbFile := "./test.db?cache=shared&_busy_timeout=60000"
+	config := DefaultConfig()
+	config.DatabaseDSN = testDatabaseFile + "?cache=shared&_busy_timeout=60000"
+	config.ShutdownDelay = 0
+	if dialect, ok := os.LookupEnv("XM_TEST_DATABASE_DIALECT"); ok {
+		config.DatabaseDialect = dialect
+		config.DatabaseDSN = os.Getenv("XM_TEST_DATABASE_DSN")
+	}
 
-	db, err := gorm.Open(sqlite.Open(dbFile), &gorm.Config{})
+	db, err := openDatabase(config)
 	if err != nil {
 		panic(err)
 	}
 
 	rand.Seed(time.Now().UnixNano())
-	randomAPIPort := fmt.Sprintf("10%v%v%v", rand.Intn(9), rand.Intn(9), rand.Intn(9)) // Generating random API port so that if multiple tests can run parallel
+	config.APIPort = fmt.Sprintf("10%v%v%v", rand.Intn(9), rand.Intn(9), rand.Intn(9)) // Generating random API port so that if multiple tests can run parallel
 
-	app := &App{name: name, config: Config{APIPort: randomAPIPort}, DB: db}
+	app := &App{name: name, config: config, DB: db}
 	consoleWriter := zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}
 	app.Logger = log.New(name, zerolog.DebugLevel, consoleWriter)
 	return &TestApp{Application: app, controllerRouteProvider: controllerRouteProvider, dbInitializer: dbInitializer}
@@ -44,11 +55,22 @@ func (testApp *TestApp) Initialize() {
 // Stop the app
 func (testApp *TestApp) Stop() {
 	testApp.Application.Stop()
-	sqlDB, err := testApp.Application.DB.DB()
-	if err != nil {
-		sqlDB.Close()
+	if testApp.Application.config.DatabaseDialect == DialectSQLite {
+		os.Remove(testDatabaseFile)
+	}
+}
+
+// NewRouter returns a router serving the routes built with a changed copy of the config of the app, e.g. another
+// origin country, on the same database. The app under test isn't changed.
+func (testApp *TestApp) NewRouter(update func(config *Config)) *mux.Router {
+	config := testApp.Application.config
+	update(&config)
+	app := &App{name: testApp.Application.name, config: config, DB: testApp.Application.DB, Logger: testApp.Application.Logger}
+	router := mux.NewRouter()
+	for _, routeSpecifier := range testApp.controllerRouteProvider(app) {
+		routeSpecifier.RegisterRoutes(router)
 	}
-	os.Remove("./test.db")
+	return router
 }
 
 // PrepareEmptyTables clears all table of data
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"gorm.io/gorm"
+	"net/http"
+	"xm/model"
+	"xm/repository"
+)
+
+// apiKeyHeader is the header carrying the API key
+const apiKeyHeader = "X-API-Key"
+
+// NewAPIKeyAuthenticator returns an Authenticator of the API keys sent in the X-API-Key header, which are looked up
+// by their hash
+func NewAPIKeyAuthenticator(db *gorm.DB, apiKeyRepository repository.Repository) Authenticator {
+	return &apiKeyAuthenticatorImpl{DB: db, Repository: apiKeyRepository}
+}
+
+type apiKeyAuthenticatorImpl struct {
+	DB         *gorm.DB
+	Repository repository.Repository
+}
+
+// Authenticate returns the identity of the API key of the request
+func (impl *apiKeyAuthenticatorImpl) Authenticate(r *http.Request) (*Identity, error) {
+	key := r.Header.Get(apiKeyHeader)
+	if len(key) == 0 {
+		return nil, nil
+	}
+
+	uow := repository.NewUnitOfWork(impl.DB, true)
+	defer uow.Complete()
+
+	var apiKeys []model.APIKey
+	queryProcessors := []repository.QueryProcessor{
+		repository.FilterBy("hash", repository.Equal, model.HashAPIKey(key)),
+		repository.FilterBy("revokedOn", repository.Equal, nil),
+	}
+	if err := impl.Repository.GetAll(uow, &apiKeys, queryProcessors); err != nil {
+		return nil, err
+	}
+	if len(apiKeys) == 0 {
+		return nil, ErrInvalidCredentials
+	}
+
+	apiKey := apiKeys[0]
+	return &Identity{Subject: apiKey.ID.String(), Name: apiKey.Name, Method: MethodAPIKey, Scopes: apiKey.ScopeList(), Roles: apiKey.RoleList()}, nil
+}
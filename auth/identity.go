@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"xm/model"
+)
+
+// ErrInvalidCredentials is the error of credentials that don't authenticate anyone, e.g. an unknown or revoked key
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// methods the callers authenticate with
+const (
+	MethodAPIKey = "apiKey"
+	MethodJWT    = "jwt"
+)
+
+// Identity is the authenticated caller
+type Identity struct {
+	// Subject identifies the caller, e.g. the id of its API key
+	Subject string
+	Name    string
+	Method  string
+	// Scopes restrict the routes the caller can call, nil when the method doesn't grant scopes
+	Scopes []string
+	// Roles restrict the routes the caller can call, nil when the caller has no role
+	Roles []string
+}
+
+// HasScope reports whether the caller has been granted the scope, callers without scopes aren't restricted by them
+func (identity *Identity) HasScope(scope string) bool {
+	return identity.Scopes == nil || contains(identity.Scopes, scope)
+}
+
+// HasRole reports whether the caller has a role including the role, e.g. admin includes editor. Callers without roles
+// have none of them.
+func (identity *Identity) HasRole(role string) bool {
+	for _, granted := range identity.Roles {
+		if model.RoleIncludes(granted, role) {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator authenticates the caller of a request
+type Authenticator interface {
+	// Authenticate returns the identity of the caller, nil when the request has no credentials of the authenticator.
+	// Credentials that don't authenticate anyone fail with ErrInvalidCredentials.
+	Authenticate(r *http.Request) (*Identity, error)
+}
+
+// NewChainAuthenticator returns an Authenticator trying the authenticators in order, the first one finding credentials
+// in the request authenticates the caller
+func NewChainAuthenticator(authenticators ...Authenticator) Authenticator {
+	return chainAuthenticator(authenticators)
+}
+
+type chainAuthenticator []Authenticator
+
+// Authenticate returns the identity of the first authenticator finding credentials in the request
+func (authenticators chainAuthenticator) Authenticate(r *http.Request) (*Identity, error) {
+	for _, authenticator := range authenticators {
+		identity, err := authenticator.Authenticate(r)
+		if identity != nil || err != nil {
+			return identity, err
+		}
+	}
+	return nil, nil
+}
+
+type identityKey struct{}
+
+// WithIdentity returns a copy of the context carrying the identity of the caller
+func WithIdentity(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, identityKey{}, identity)
+}
+
+// IdentityFrom returns the identity of the caller carried by the context, if it has been authenticated
+func IdentityFrom(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(identityKey{}).(*Identity)
+	return identity, ok
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
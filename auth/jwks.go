@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrUnknownKey is the error of a key id that isn't in the key set, even after it has been refreshed
+var ErrUnknownKey = errors.New("unknown signing key")
+
+// KeySet looks up the public keys verifying the signatures of the tokens
+type KeySet interface {
+	// Key returns the key with the id, or the only key of the set when the id is empty
+	Key(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// JWKSOptions configures NewJWKSKeySet, zero values fall back to the defaults
+type JWKSOptions struct {
+	// RefreshInterval is how often the keys are reloaded, so that rotated keys are picked up (default: 1h)
+	RefreshInterval time.Duration
+	// MinRefreshInterval is the minimum delay between reloads triggered by unknown key ids (default: 1m)
+	MinRefreshInterval time.Duration
+	// HTTPClient fetches the keys of a URL (default: a client with a 5s timeout)
+	HTTPClient *http.Client
+}
+
+// withDefaults returns the options where zero values have been replaced with the defaults
+func (options JWKSOptions) withDefaults() JWKSOptions {
+	if options.RefreshInterval == 0 {
+		options.RefreshInterval = time.Hour
+	}
+	if options.MinRefreshInterval == 0 {
+		options.MinRefreshInterval = time.Minute
+	}
+	if options.HTTPClient == nil {
+		options.HTTPClient = &http.Client{Timeout: 5 * time.Second}
+	}
+	return options
+}
+
+// NewJWKSKeySet returns a KeySet of the RSA and P-256 EC keys of a JSON Web Key Set, loaded from an http(s) URL or a
+// local file. The keys are cached and reloaded every RefreshInterval, or sooner when a token is signed by an unknown
+// key as the keys have been rotated. When a reload fails the cached keys are kept.
+func NewJWKSKeySet(source string, options JWKSOptions) (KeySet, error) {
+	keySet := &jwksKeySetImpl{Source: source, Options: options.withDefaults(), now: time.Now}
+	if err := keySet.load(context.Background()); err != nil {
+		return nil, err
+	}
+	return keySet, nil
+}
+
+type jwksKeySetImpl struct {
+	Source  string
+	Options JWKSOptions
+
+	mutex    sync.RWMutex
+	keys     map[string]crypto.PublicKey
+	loadedAt time.Time
+	now      func() time.Time
+}
+
+// jsonWebKey is the part of a JSON Web Key (RFC 7517) describing RSA and EC public keys
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// Key returns the key with the id, reloading the keys when they are due or the id is unknown
+func (impl *jwksKeySetImpl) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	impl.mutex.RLock()
+	age := impl.now().Sub(impl.loadedAt)
+	key, found := impl.lookUp(kid)
+	impl.mutex.RUnlock()
+
+	if age >= impl.Options.RefreshInterval || (!found && age >= impl.Options.MinRefreshInterval) {
+		if err := impl.load(ctx); err != nil && !found {
+			return nil, err
+		}
+		impl.mutex.RLock()
+		key, found = impl.lookUp(kid)
+		impl.mutex.RUnlock()
+	}
+	if !found {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+	}
+	return key, nil
+}
+
+// lookUp returns the key with the id, or the only key when the id is empty, the mutex must be held
+func (impl *jwksKeySetImpl) lookUp(kid string) (crypto.PublicKey, bool) {
+	if len(kid) == 0 && len(impl.keys) == 1 {
+		for _, key := range impl.keys {
+			return key, true
+		}
+	}
+	key, found := impl.keys[kid]
+	return key, found
+}
+
+// load reads and parses the keys of the source
+func (impl *jwksKeySetImpl) load(ctx context.Context) error {
+	data, err := impl.read(ctx)
+	// a failed reload is only retried after MinRefreshInterval
+	impl.mutex.Lock()
+	impl.loadedAt = impl.now()
+	impl.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return fmt.Errorf("invalid JWKS %s: %w", impl.Source, err)
+	}
+
+	keys := map[string]crypto.PublicKey{}
+	for _, jwk := range jwks.Keys {
+		if len(jwk.Use) > 0 && jwk.Use != "sig" {
+			continue
+		}
+		key, err := jwk.publicKey()
+		if err != nil {
+			return fmt.Errorf("invalid JWKS %s: key %q: %w", impl.Source, jwk.Kid, err)
+		}
+		if key != nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("invalid JWKS %s: no RSA or P-256 signing key", impl.Source)
+	}
+
+	impl.mutex.Lock()
+	defer impl.mutex.Unlock()
+	impl.keys = keys
+	return nil
+}
+
+// read reads the JWKS from the URL or the file of the source
+func (impl *jwksKeySetImpl) read(ctx context.Context) ([]byte, error) {
+	if !strings.HasPrefix(impl.Source, "http://") && !strings.HasPrefix(impl.Source, "https://") {
+		data, err := ioutil.ReadFile(impl.Source)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read JWKS: %w", err)
+		}
+		return data, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, impl.Source, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := impl.Options.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch JWKS: received status %d", resp.StatusCode)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// publicKey returns the RSA or P-256 EC key, nil for the other types of keys
+func (jwk jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, errors.New("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		if jwk.Crv != "P-256" {
+			return nil, nil
+		}
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !elliptic.P256().IsOnCurve(x, y) {
+			return nil, errors.New("point isn't on the P-256 curve")
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+	default:
+		return nil, nil
+	}
+}
+
+// decodeBigInt decodes an unsigned big-endian integer encoded with base64url
+func decodeBigInt(value string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(data) == 0 {
+		return nil, errors.New("invalid base64url integer")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
@@ -0,0 +1,260 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// JWTOptions configures NewJWTAuthenticator
+type JWTOptions struct {
+	// Issuer is the required iss claim
+	Issuer string
+	// Audience must be in the aud claim
+	Audience string
+	// RolesClaim is the claim holding the roles of the caller, a dotted path into nested claims such as
+	// realm_access.roles (default: roles)
+	RolesClaim string
+	// RoleMapping maps the values of the roles claim (e.g. groups) to roles, values that aren't mapped are dropped.
+	// When empty the values are the roles.
+	RoleMapping map[string]string
+	// Leeway is the clock skew tolerated when checking exp and nbf (default: 1m)
+	Leeway time.Duration
+}
+
+// NewJWTAuthenticator returns an Authenticator of the RS256 and ES256 JWTs sent as bearer tokens in the Authorization
+// header. The signature is verified with the keys of the key set, and the iss, aud, exp and nbf claims are checked.
+func NewJWTAuthenticator(keySet KeySet, options JWTOptions) (Authenticator, error) {
+	if len(options.Issuer) == 0 || len(options.Audience) == 0 {
+		return nil, errors.New("the issuer and the audience of the tokens are required")
+	}
+	if len(options.RolesClaim) == 0 {
+		options.RolesClaim = "roles"
+	}
+	if options.Leeway == 0 {
+		options.Leeway = time.Minute
+	}
+	return &jwtAuthenticatorImpl{KeySet: keySet, Options: options, now: time.Now}, nil
+}
+
+type jwtAuthenticatorImpl struct {
+	KeySet  KeySet
+	Options JWTOptions
+	now     func() time.Time
+}
+
+// jwtHeader is the JOSE header of a JWT
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+}
+
+// Authenticate returns the identity of the bearer token of the request
+func (impl *jwtAuthenticatorImpl) Authenticate(r *http.Request) (*Identity, error) {
+	scheme, token := cutSpace(r.Header.Get("Authorization"))
+	if !strings.EqualFold(scheme, "Bearer") || len(token) == 0 {
+		return nil, nil
+	}
+
+	claims, err := impl.verify(r, token)
+	if err != nil {
+		return nil, err
+	}
+	if err := impl.validate(claims); err != nil {
+		return nil, err
+	}
+
+	subject, _ := claims["sub"].(string)
+	name, _ := claims["name"].(string)
+	if len(name) == 0 {
+		name = subject
+	}
+	identity := &Identity{Subject: subject, Name: name, Method: MethodJWT, Roles: impl.roles(claims)}
+	if scope, ok := claims["scope"].(string); ok {
+		identity.Scopes = strings.Fields(scope)
+	}
+	return identity, nil
+}
+
+// verify verifies the signature of the token and returns its claims
+func (impl *jwtAuthenticatorImpl) verify(r *http.Request, token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrInvalidCredentials)
+	}
+
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrInvalidCredentials)
+	}
+
+	key, err := impl.KeySet.Key(r.Context(), header.Kid)
+	if err != nil {
+		if errors.Is(err, ErrUnknownKey) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+		}
+		return nil, err
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if !verifySignature(header.Alg, key, digest[:], signature) {
+		return nil, fmt.Errorf("%w: invalid %s signature", ErrInvalidCredentials, header.Alg)
+	}
+
+	claims := map[string]interface{}{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// verifySignature verifies the RS256 or ES256 signature of the digest, the other algorithms are rejected
+func verifySignature(alg string, key crypto.PublicKey, digest, signature []byte) bool {
+	switch alg {
+	case "RS256":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		return ok && rsa.VerifyPKCS1v15(rsaKey, crypto.SHA256, digest, signature) == nil
+	case "ES256":
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(signature) != 64 {
+			return false
+		}
+		r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+		return ecdsa.Verify(ecKey, digest, r, s)
+	default:
+		return false
+	}
+}
+
+// validate checks the sub, iss, aud, exp and nbf claims
+func (impl *jwtAuthenticatorImpl) validate(claims map[string]interface{}) error {
+	// the subject identifies the caller in the logs and the audit entries
+	if subject, _ := claims["sub"].(string); len(subject) == 0 {
+		return fmt.Errorf("%w: missing sub", ErrInvalidCredentials)
+	}
+	if issuer, _ := claims["iss"].(string); issuer != impl.Options.Issuer {
+		return fmt.Errorf("%w: unexpected issuer %q", ErrInvalidCredentials, issuer)
+	}
+	if !containsAudience(claims["aud"], impl.Options.Audience) {
+		return fmt.Errorf("%w: unexpected audience %v", ErrInvalidCredentials, claims["aud"])
+	}
+
+	now := impl.now()
+	expiresAt, ok := numericDate(claims["exp"])
+	if !ok {
+		return fmt.Errorf("%w: missing exp", ErrInvalidCredentials)
+	}
+	if !now.Before(expiresAt.Add(impl.Options.Leeway)) {
+		return fmt.Errorf("%w: token expired at %s", ErrInvalidCredentials, expiresAt)
+	}
+	if _, present := claims["nbf"]; present {
+		notBefore, ok := numericDate(claims["nbf"])
+		if !ok {
+			return fmt.Errorf("%w: invalid nbf", ErrInvalidCredentials)
+		}
+		if now.Add(impl.Options.Leeway).Before(notBefore) {
+			return fmt.Errorf("%w: token not valid before %s", ErrInvalidCredentials, notBefore)
+		}
+	}
+	return nil
+}
+
+// roles returns the roles of the values of the roles claim, mapped when there is a mapping. It's never nil, as tokens
+// are always restricted by their roles.
+func (impl *jwtAuthenticatorImpl) roles(claims map[string]interface{}) []string {
+	var value interface{} = claims
+	for _, name := range strings.Split(impl.Options.RolesClaim, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return []string{}
+		}
+		value = object[name]
+	}
+
+	values := []string{}
+	switch value := value.(type) {
+	case string:
+		values = strings.Fields(value)
+	case []interface{}:
+		for _, element := range value {
+			if role, ok := element.(string); ok {
+				values = append(values, role)
+			}
+		}
+	}
+	if len(impl.Options.RoleMapping) == 0 {
+		return values
+	}
+
+	roles := []string{}
+	for _, value := range values {
+		if role, ok := impl.Options.RoleMapping[value]; ok {
+			roles = append(roles, role)
+		}
+	}
+	return roles
+}
+
+// decodeSegment decodes a base64url JSON segment of the token
+func decodeSegment(segment string, out interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("%w: malformed token", ErrInvalidCredentials)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(out); err != nil {
+		return fmt.Errorf("%w: malformed token", ErrInvalidCredentials)
+	}
+	return nil
+}
+
+// containsAudience reports whether the aud claim, a string or an array of strings, contains the audience
+func containsAudience(claim interface{}, audience string) bool {
+	switch claim := claim.(type) {
+	case string:
+		return claim == audience
+	case []interface{}:
+		for _, element := range claim {
+			if element == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// numericDate returns the time of a NumericDate claim, seconds since the epoch
+func numericDate(claim interface{}) (time.Time, bool) {
+	number, ok := claim.(json.Number)
+	if !ok {
+		return time.Time{}, false
+	}
+	seconds, err := number.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(seconds*float64(time.Second))), true
+}
+
+// cutSpace slices s around the first space
+func cutSpace(s string) (string, string) {
+	if index := strings.IndexByte(s, ' '); index >= 0 {
+		return s[:index], strings.TrimSpace(s[index+1:])
+	}
+	return s, ""
+}
@@ -0,0 +1,229 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testSigner signs tokens with a locally generated key
+type testSigner struct {
+	kid string
+	key crypto.Signer
+}
+
+func newRSASigner(t *testing.T, kid string) testSigner {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("unable to generate RSA key: %v", err)
+	}
+	return testSigner{kid: kid, key: key}
+}
+
+func newECSigner(t *testing.T, kid string) testSigner {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unable to generate EC key: %v", err)
+	}
+	return testSigner{kid: kid, key: key}
+}
+
+// jwk returns the public JSON Web Key of the signer
+func (signer testSigner) jwk() map[string]string {
+	encode := func(n *big.Int) string { return base64.RawURLEncoding.EncodeToString(n.Bytes()) }
+	switch key := signer.key.Public().(type) {
+	case *rsa.PublicKey:
+		return map[string]string{"kid": signer.kid, "kty": "RSA", "use": "sig", "n": encode(key.N), "e": encode(big.NewInt(int64(key.E)))}
+	case *ecdsa.PublicKey:
+		return map[string]string{"kid": signer.kid, "kty": "EC", "crv": "P-256", "x": encode(key.X), "y": encode(key.Y)}
+	}
+	return nil
+}
+
+func (signer testSigner) sign(t *testing.T, alg string, claims map[string]interface{}) string {
+	encode := func(value interface{}) string {
+		data, _ := json.Marshal(value)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signingInput := encode(map[string]string{"alg": alg, "kid": signer.kid, "typ": "JWT"}) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	switch key := signer.key.(type) {
+	case *rsa.PrivateKey:
+		signature, _ = rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+		if err != nil {
+			t.Fatalf("unable to sign token: %v", err)
+		}
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func writeJWKS(t *testing.T, path string, signers ...testSigner) {
+	keys := make([]map[string]string, len(signers))
+	for index, signer := range signers {
+		keys[index] = signer.jwk()
+	}
+	data, _ := json.Marshal(map[string]interface{}{"keys": keys})
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("unable to write JWKS: %v", err)
+	}
+}
+
+func bearerRequest(token string) *http.Request {
+	request := httptest.NewRequest(http.MethodGet, "/api/companies", nil)
+	if len(token) > 0 {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	return request
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	rsaSigner := newRSASigner(t, "rsa")
+	ecSigner := newECSigner(t, "ec")
+	unknownSigner := newRSASigner(t, "unknown")
+	impostor := newRSASigner(t, "rsa")
+
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	writeJWKS(t, path, rsaSigner, ecSigner)
+	keySet, err := NewJWKSKeySet(path, JWKSOptions{})
+	if err != nil {
+		t.Fatalf("unable to load JWKS: %v", err)
+	}
+	authenticator, err := NewJWTAuthenticator(keySet, JWTOptions{
+		Issuer:      "https://id.xm.com",
+		Audience:    "xm",
+		RolesClaim:  "realm_access.roles",
+		RoleMapping: map[string]string{"xm-editors": "editor", "xm-admins": "admin"},
+	})
+	if err != nil {
+		t.Fatalf("unable to create authenticator: %v", err)
+	}
+
+	now := time.Now().Unix()
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		claims := map[string]interface{}{
+			"iss": "https://id.xm.com", "aud": []string{"crm", "xm"}, "sub": "alice", "exp": now + 60, "nbf": now - 60,
+			"realm_access": map[string]interface{}{"roles": []string{"xm-editors", "offline_access"}},
+		}
+		for name, value := range overrides {
+			if value == nil {
+				delete(claims, name)
+				continue
+			}
+			claims[name] = value
+		}
+		return claims
+	}
+
+	tests := []struct {
+		name             string
+		token            string
+		expectedIdentity *Identity
+		expectedErr      error
+	}{
+		{"+ve:ShouldIgnoreRequestWithoutToken", "", nil, nil},
+		{"+ve:ShouldAuthenticateRS256Token", rsaSigner.sign(t, "RS256", claims(nil)), &Identity{Subject: "alice", Name: "alice", Method: MethodJWT, Roles: []string{"editor"}}, nil},
+		{"+ve:ShouldAuthenticateES256Token", ecSigner.sign(t, "ES256", claims(map[string]interface{}{"aud": "xm", "nbf": nil})), &Identity{Subject: "alice", Name: "alice", Method: MethodJWT, Roles: []string{"editor"}}, nil},
+		{"+ve:ShouldMapScopeClaim", rsaSigner.sign(t, "RS256", claims(map[string]interface{}{"scope": "companies:read", "name": "Alice"})), &Identity{Subject: "alice", Name: "Alice", Method: MethodJWT, Scopes: []string{"companies:read"}, Roles: []string{"editor"}}, nil},
+		{"+ve:ShouldTolerateClockSkew", rsaSigner.sign(t, "RS256", claims(map[string]interface{}{"exp": now - 30})), &Identity{Subject: "alice", Name: "alice", Method: MethodJWT, Roles: []string{"editor"}}, nil},
+		{"+ve:ShouldDropUnmappedRoles", rsaSigner.sign(t, "RS256", claims(map[string]interface{}{"realm_access": map[string]interface{}{"roles": []string{"offline_access"}}})), &Identity{Subject: "alice", Name: "alice", Method: MethodJWT, Roles: []string{}}, nil},
+		{"-ve:ShouldFailWhenExpired", rsaSigner.sign(t, "RS256", claims(map[string]interface{}{"exp": now - 120})), nil, ErrInvalidCredentials},
+		{"-ve:ShouldFailWhenSubjectIsMissing", rsaSigner.sign(t, "RS256", claims(map[string]interface{}{"sub": nil})), nil, ErrInvalidCredentials},
+		{"-ve:ShouldFailWhenSubjectIsEmpty", rsaSigner.sign(t, "RS256", claims(map[string]interface{}{"sub": ""})), nil, ErrInvalidCredentials},
+		{"-ve:ShouldFailWhenExpiryIsMissing", rsaSigner.sign(t, "RS256", claims(map[string]interface{}{"exp": nil})), nil, ErrInvalidCredentials},
+		{"-ve:ShouldFailWhenNotYetValid", rsaSigner.sign(t, "RS256", claims(map[string]interface{}{"nbf": now + 120})), nil, ErrInvalidCredentials},
+		{"-ve:ShouldFailWhenIssuerIsUnexpected", rsaSigner.sign(t, "RS256", claims(map[string]interface{}{"iss": "https://evil.com"})), nil, ErrInvalidCredentials},
+		{"-ve:ShouldFailWhenAudienceIsUnexpected", rsaSigner.sign(t, "RS256", claims(map[string]interface{}{"aud": "crm"})), nil, ErrInvalidCredentials},
+		{"-ve:ShouldFailWhenSignatureIsInvalid", impostor.sign(t, "RS256", claims(nil)), nil, ErrInvalidCredentials},
+		{"-ve:ShouldFailWhenKeyIsUnknown", unknownSigner.sign(t, "RS256", claims(nil)), nil, ErrInvalidCredentials},
+		{"-ve:ShouldFailWhenAlgorithmDoesntMatchKey", rsaSigner.sign(t, "ES256", claims(nil)), nil, ErrInvalidCredentials},
+		{"-ve:ShouldFailWhenAlgorithmIsNone", rsaSigner.sign(t, "none", claims(nil)), nil, ErrInvalidCredentials},
+		{"-ve:ShouldFailWhenTokenIsMalformed", "not.a-token", nil, ErrInvalidCredentials},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, err := authenticator.Authenticate(bearerRequest(tt.token))
+
+			if !errors.Is(err, tt.expectedErr) || (tt.expectedErr == nil && err != nil) {
+				t.Errorf("expected error %v, got %v", tt.expectedErr, err)
+			}
+			if !reflect.DeepEqual(tt.expectedIdentity, identity) {
+				t.Errorf("expected identity %+v, got %+v", tt.expectedIdentity, identity)
+			}
+		})
+	}
+
+	if _, err := NewJWTAuthenticator(keySet, JWTOptions{Issuer: "https://id.xm.com"}); err == nil {
+		t.Errorf("expected error without audience")
+	}
+}
+
+func TestJWKSKeySetRotation(t *testing.T) {
+	oldSigner := newRSASigner(t, "2026-09")
+	newSigner := newECSigner(t, "2026-10")
+
+	var current atomic.Value
+	current.Store([]testSigner{oldSigner})
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		signers := current.Load().([]testSigner)
+		keys := make([]map[string]string, len(signers))
+		for index, signer := range signers {
+			keys[index] = signer.jwk()
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+	}))
+	defer server.Close()
+
+	keySet, err := NewJWKSKeySet(server.URL, JWKSOptions{MinRefreshInterval: time.Nanosecond})
+	if err != nil {
+		t.Fatalf("unable to load JWKS: %v", err)
+	}
+	authenticator, _ := NewJWTAuthenticator(keySet, JWTOptions{Issuer: "https://id.xm.com", Audience: "xm"})
+	claims := map[string]interface{}{"iss": "https://id.xm.com", "aud": "xm", "sub": "alice", "exp": time.Now().Unix() + 60}
+
+	if _, err := authenticator.Authenticate(bearerRequest(oldSigner.sign(t, "RS256", claims))); err != nil {
+		t.Errorf("expected token of the old key to be valid, got %v", err)
+	}
+	if _, err := authenticator.Authenticate(bearerRequest(oldSigner.sign(t, "RS256", claims))); err != nil || atomic.LoadInt32(&fetches) != 1 {
+		t.Errorf("expected cached keys to be used, got %d fetches (%v)", fetches, err)
+	}
+
+	current.Store([]testSigner{oldSigner, newSigner})
+	if _, err := authenticator.Authenticate(bearerRequest(newSigner.sign(t, "ES256", claims))); err != nil {
+		t.Errorf("expected token of the rotated key to be valid, got %v", err)
+	}
+	if atomic.LoadInt32(&fetches) != 2 {
+		t.Errorf("expected keys to be fetched again for the unknown key, got %d fetches", fetches)
+	}
+
+	current.Store([]testSigner{})
+	if _, err := authenticator.Authenticate(bearerRequest(newRSASigner(t, "2026-11").sign(t, "RS256", claims))); err == nil {
+		t.Errorf("expected error when the JWKS can't be reloaded")
+	}
+	if _, err := authenticator.Authenticate(bearerRequest(newSigner.sign(t, "ES256", claims))); err != nil {
+		t.Errorf("expected cached keys to be kept when the JWKS can't be reloaded, got %v", err)
+	}
+}
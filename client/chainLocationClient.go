@@ -0,0 +1,162 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// names of the ip location providers
+const (
+	ProviderIPAPI    = "ipapi.co"
+	ProviderIPAPICom = "ip-api.com"
+	ProviderIPInfo   = "ipinfo"
+	ProviderMMDB     = "mmdb"
+)
+
+// ChainStrategy decides how the providers of a chain are combined
+type ChainStrategy string
+
+const (
+	// FirstSuccess looks up all the providers concurrently and answers with the first location found
+	FirstSuccess ChainStrategy = "first-success"
+	// FallbackOnError looks up the providers one after another, until one of them finds the location
+	FallbackOnError ChainStrategy = "fallback-on-error"
+	// MajorityVote looks up all the providers concurrently and answers with the country found by more than half of
+	// them, it fails with ErrNoMajority otherwise
+	MajorityVote ChainStrategy = "majority-vote"
+)
+
+// ErrNoMajority is the error of the MajorityVote lookups where no country has been found by more than half of the
+// providers, e.g. because they disagree or because too many of them failed
+var ErrNoMajority = errors.New("no majority of the ip location providers agrees on the country")
+
+// NewChainLocationClient returns an IPLocationClient composed from the ordered providers with the strategy
+func NewChainLocationClient(strategy ChainStrategy, providers ...IPLocationClient) (IPLocationClient, error) {
+	switch strategy {
+	case FirstSuccess, FallbackOnError, MajorityVote:
+	default:
+		return nil, fmt.Errorf("unknown ip location strategy: %q", strategy)
+	}
+	if len(providers) == 0 {
+		return nil, errors.New("no ip location provider")
+	}
+	return &chainLocationClientImpl{Strategy: strategy, Providers: providers}, nil
+}
+
+type chainLocationClientImpl struct {
+	Strategy  ChainStrategy
+	Providers []IPLocationClient
+}
+
+// chainResult is the outcome of the lookup of a provider of the chain
+type chainResult struct {
+	index    int
+	location Location
+	err      error
+}
+
+// GetLocation gets location of ip from the providers according to the strategy
+func (impl *chainLocationClientImpl) GetLocation(ctx context.Context, ip string) (Location, error) {
+	switch impl.Strategy {
+	case FallbackOnError:
+		return impl.fallback(ctx, ip)
+	case MajorityVote:
+		return impl.vote(ctx, ip)
+	default:
+		return impl.firstSuccess(ctx, ip)
+	}
+}
+
+// fallback looks up the providers in order until one succeeds
+func (impl *chainLocationClientImpl) fallback(ctx context.Context, ip string) (Location, error) {
+	results := make([]chainResult, 0, len(impl.Providers))
+	for index, provider := range impl.Providers {
+		location, err := provider.GetLocation(ctx, ip)
+		if err == nil {
+			return location, nil
+		}
+		results = append(results, chainResult{index: index, err: err})
+	}
+	return Location{}, chainError(results)
+}
+
+// firstSuccess looks up all the providers concurrently and cancels the others once one succeeds
+func (impl *chainLocationClientImpl) firstSuccess(ctx context.Context, ip string) (Location, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var failures []chainResult
+	for result := range impl.lookUpAll(ctx, ip) {
+		if result.err == nil {
+			return result.location, nil
+		}
+		failures = append(failures, result)
+	}
+	return Location{}, chainError(failures)
+}
+
+// vote looks up all the providers concurrently and answers with the country more than half of them agree on
+func (impl *chainLocationClientImpl) vote(ctx context.Context, ip string) (Location, error) {
+	answers := make([]*Location, len(impl.Providers))
+	var failures []chainResult
+	for result := range impl.lookUpAll(ctx, ip) {
+		if result.err != nil {
+			failures = append(failures, result)
+			continue
+		}
+		location := result.location
+		answers[result.index] = &location
+	}
+	if len(failures) == len(impl.Providers) {
+		return Location{}, chainError(failures)
+	}
+
+	votes := map[string][]string{}
+	for _, answer := range answers {
+		if answer == nil {
+			continue
+		}
+		votes[answer.Country] = append(votes[answer.Country], answer.Provider)
+		if len(votes[answer.Country]) > len(impl.Providers)/2 {
+			return Location{Country: answer.Country, Provider: strings.Join(votes[answer.Country], ",")}, nil
+		}
+	}
+	return Location{}, fmt.Errorf("%w: %d providers, %d failed, votes %v", ErrNoMajority, len(impl.Providers), len(failures), votes)
+}
+
+// lookUpAll looks up all the providers concurrently, the channel is closed once all of them have answered
+func (impl *chainLocationClientImpl) lookUpAll(ctx context.Context, ip string) <-chan chainResult {
+	results := make(chan chainResult, len(impl.Providers))
+	var wg sync.WaitGroup
+	for index, provider := range impl.Providers {
+		wg.Add(1)
+		go func(index int, provider IPLocationClient) {
+			defer wg.Done()
+			location, err := provider.GetLocation(ctx, ip)
+			results <- chainResult{index: index, location: location, err: err}
+		}(index, provider)
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	return results
+}
+
+// chainError combines the errors of the providers, the location is only reported as not found when none of the
+// providers failed
+func chainError(results []chainResult) error {
+	messages := make([]string, len(results))
+	var cause error
+	for index, result := range results {
+		messages[index] = result.err.Error()
+		if cause == nil || (IsUpstreamUnavailable(result.err) && !IsUpstreamUnavailable(cause)) {
+			cause = result.err
+		}
+	}
+	return fmt.Errorf("all ip location providers failed [%s]: %w", strings.Join(messages, "; "), cause)
+}
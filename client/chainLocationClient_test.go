@@ -0,0 +1,169 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	apiError "xm/error"
+)
+
+// providerLocationClient answers every lookup with the same location of the named provider, or error
+type providerLocationClient struct {
+	provider string
+	country  string
+	err      error
+}
+
+func (client providerLocationClient) GetLocation(ctx context.Context, ip string) (Location, error) {
+	if client.err != nil {
+		return Location{}, client.err
+	}
+	return Location{Country: client.country, Provider: client.provider}, nil
+}
+
+func TestChainLocationClient(t *testing.T) {
+	upstreamErr := errors.New("connection refused")
+	notFoundErr := fmt.Errorf("%w: reserved ip address", ErrLocationNotFound)
+
+	tests := []struct {
+		name             string
+		strategy         ChainStrategy
+		providers        []IPLocationClient
+		expectedLocation Location
+		expectedErr      error
+	}{
+		{"+ve:ShouldFallBackToNextProviderOnError",
+			FallbackOnError,
+			[]IPLocationClient{providerLocationClient{provider: "a", err: upstreamErr}, providerLocationClient{provider: "b", country: "CY"}, providerLocationClient{provider: "c", country: "GR"}},
+			Location{Country: "CY", Provider: "b"},
+			nil,
+		},
+		{"+ve:ShouldAnswerWithFirstSuccess",
+			FirstSuccess,
+			[]IPLocationClient{providerLocationClient{provider: "a", err: upstreamErr}, providerLocationClient{provider: "b", country: "CY"}},
+			Location{Country: "CY", Provider: "b"},
+			nil,
+		},
+		{"+ve:ShouldAnswerWithMajority",
+			MajorityVote,
+			[]IPLocationClient{providerLocationClient{provider: "a", country: "GR"}, providerLocationClient{provider: "b", country: "CY"}, providerLocationClient{provider: "c", err: upstreamErr}, providerLocationClient{provider: "d", country: "CY"}, providerLocationClient{provider: "e", country: "CY"}},
+			Location{Country: "CY", Provider: "b,d,e"},
+			nil,
+		},
+		{"-ve:ShouldFailOnTie",
+			MajorityVote,
+			[]IPLocationClient{providerLocationClient{provider: "a", country: "GR"}, providerLocationClient{provider: "b", country: "CY"}},
+			Location{},
+			ErrNoMajority,
+		},
+		{"-ve:ShouldFailOnThreeWaySplit",
+			MajorityVote,
+			[]IPLocationClient{providerLocationClient{provider: "a", country: "CY"}, providerLocationClient{provider: "b", country: "GR"}, providerLocationClient{provider: "c", country: "US"}},
+			Location{},
+			ErrNoMajority,
+		},
+		{"-ve:ShouldFailWithPluralityOnly",
+			MajorityVote,
+			[]IPLocationClient{providerLocationClient{provider: "a", country: "CY"}, providerLocationClient{provider: "b", country: "CY"}, providerLocationClient{provider: "c", country: "GR"}, providerLocationClient{provider: "d", country: "US"}},
+			Location{},
+			ErrNoMajority,
+		},
+		{"-ve:ShouldFailWhenMostProvidersFailed",
+			MajorityVote,
+			[]IPLocationClient{providerLocationClient{provider: "a", country: "CY"}, providerLocationClient{provider: "b", err: upstreamErr}, providerLocationClient{provider: "c", err: notFoundErr}},
+			Location{},
+			ErrNoMajority,
+		},
+		{"-ve:ShouldReportUpstreamFailureWhenAnyProviderFailed",
+			FallbackOnError,
+			[]IPLocationClient{providerLocationClient{provider: "a", err: notFoundErr}, providerLocationClient{provider: "b", err: upstreamErr}},
+			Location{},
+			upstreamErr,
+		},
+		{"-ve:ShouldReportNotFoundWhenNoProviderFailed",
+			MajorityVote,
+			[]IPLocationClient{providerLocationClient{provider: "a", err: notFoundErr}, providerLocationClient{provider: "b", err: notFoundErr}},
+			Location{},
+			ErrLocationNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewChainLocationClient(tt.strategy, tt.providers...)
+			if err != nil {
+				t.Fatalf("unable to create client: %v", err)
+			}
+
+			location, err := client.GetLocation(context.Background(), "1.2.3.4")
+
+			if (tt.expectedErr == nil) != (err == nil) || (tt.expectedErr != nil && !errors.Is(err, tt.expectedErr)) {
+				t.Errorf("expected error %v, got %v", tt.expectedErr, err)
+			}
+			if tt.expectedLocation != location {
+				t.Errorf("expected location %+v, got %+v", tt.expectedLocation, location)
+			}
+		})
+	}
+
+	if _, err := NewChainLocationClient("random", providerLocationClient{}); err == nil {
+		t.Errorf("expected error for unknown strategy")
+	}
+}
+
+func TestHTTPLocationClients(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/1.2.3.4/json/":
+			w.Write([]byte(`{"ip":"1.2.3.4","country":"CY"}`))
+		case "/10.0.0.1/json/":
+			w.Write([]byte(`{"ip":"10.0.0.1","error":true,"reason":"Reserved IP Address"}`))
+		case "/json/1.2.3.4":
+			w.Write([]byte(`{"status":"success","countryCode":"CY"}`))
+		case "/json/10.0.0.1":
+			w.Write([]byte(`{"status":"fail","message":"private range"}`))
+		case "/1.2.3.4/json":
+			if r.Header.Get("Authorization") != "Bearer token" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			w.Write([]byte(`{"ip":"1.2.3.4","country":"CY"}`))
+		case "/10.0.0.1/json":
+			w.Write([]byte(`{"ip":"10.0.0.1","bogon":true}`))
+		default:
+			w.Header().Set("Retry-After", "3")
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer server.Close()
+
+	clients := []struct {
+		provider string
+		client   IPLocationClient
+	}{
+		{ProviderIPAPI, NewIpLocationClient(server.URL)},
+		{ProviderIPAPICom, NewIPAPIComLocationClient(server.URL)},
+		{ProviderIPInfo, NewIPInfoLocationClient(server.URL, "token")},
+	}
+	for _, tt := range clients {
+		t.Run(tt.provider, func(t *testing.T) {
+			location, err := tt.client.GetLocation(context.Background(), "1.2.3.4")
+			if err != nil || location != (Location{Country: "CY", Provider: tt.provider}) {
+				t.Errorf("expected location CY of %v, got %+v (%v)", tt.provider, location, err)
+			}
+
+			if _, err := tt.client.GetLocation(context.Background(), "10.0.0.1"); !errors.Is(err, ErrLocationNotFound) {
+				t.Errorf("expected location not found for reserved ip, got %v", err)
+			}
+
+			_, err = tt.client.GetLocation(context.Background(), "5.5.5.5")
+			var clientErr apiError.APIClientError
+			if !IsUpstreamUnavailable(err) || !errors.As(err, &clientErr) || clientErr.GetRetryAfter() != 3*time.Second {
+				t.Errorf("expected upstream failure with Retry-After, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is the error of the lookups rejected without calling the provider, as it has been failing
+var ErrCircuitOpen = errors.New("ip location circuit breaker is open")
+
+// circuitState is the state of a circuit breaker
+type circuitState int
+
+const (
+	// circuitClosed lets all the lookups through
+	circuitClosed circuitState = iota
+	// circuitOpen rejects all the lookups until the open timeout has elapsed
+	circuitOpen
+	// circuitHalfOpen lets a single probe through, which closes the circuit when it succeeds or opens it again
+	circuitHalfOpen
+)
+
+// CircuitBreakerOptions configures NewCircuitBreakerLocationClient, zero values fall back to the defaults
+type CircuitBreakerOptions struct {
+	// FailureThreshold is the number of consecutive failures opening the circuit (default: 5)
+	FailureThreshold int
+	// OpenTimeout is how long the circuit stays open before a probe is let through (default: 30s)
+	OpenTimeout time.Duration
+}
+
+// withDefaults returns the options where zero values have been replaced with the defaults
+func (options CircuitBreakerOptions) withDefaults() CircuitBreakerOptions {
+	if options.FailureThreshold == 0 {
+		options.FailureThreshold = 5
+	}
+	if options.OpenTimeout == 0 {
+		options.OpenTimeout = 30 * time.Second
+	}
+	return options
+}
+
+// NewCircuitBreakerLocationClient decorates the client with a circuit breaker, which fails fast with ErrCircuitOpen
+// once the provider has failed FailureThreshold times in a row. After OpenTimeout a single probe is let through
+// (half-open), the circuit closes again when it succeeds. Lookups answered without a location aren't failures, nor are
+// the lookups the caller has given up on, i.e. cancelled or expired by the context of the caller.
+func NewCircuitBreakerLocationClient(client IPLocationClient, options CircuitBreakerOptions) IPLocationClient {
+	return &circuitBreakerLocationClientImpl{Client: client, Options: options.withDefaults(), now: time.Now}
+}
+
+type circuitBreakerLocationClientImpl struct {
+	Client  IPLocationClient
+	Options CircuitBreakerOptions
+
+	mutex    sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+	now      func() time.Time
+}
+
+// GetLocation gets location of ip from the decorated client, unless the circuit is open
+func (impl *circuitBreakerLocationClientImpl) GetLocation(ctx context.Context, ip string) (Location, error) {
+	if !impl.allow() {
+		return Location{}, ErrCircuitOpen
+	}
+
+	location, err := impl.Client.GetLocation(ctx, ip)
+	if err != nil && ctx.Err() != nil && errors.Is(err, ctx.Err()) {
+		impl.abandon()
+		return location, err
+	}
+	impl.record(IsUpstreamUnavailable(err))
+	return location, err
+}
+
+// allow reports whether a lookup can be let through, moving an open circuit to half-open once its timeout has elapsed
+func (impl *circuitBreakerLocationClientImpl) allow() bool {
+	impl.mutex.Lock()
+	defer impl.mutex.Unlock()
+
+	switch impl.state {
+	case circuitOpen:
+		if impl.now().Sub(impl.openedAt) < impl.Options.OpenTimeout {
+			return false
+		}
+		impl.state = circuitHalfOpen
+		return true
+	case circuitHalfOpen:
+		// a probe is already in flight
+		return false
+	default:
+		return true
+	}
+}
+
+// abandon forgets a lookup the caller has given up on, which says nothing about the provider. An abandoned probe opens
+// the circuit again without restarting the open timeout, so that the next lookup is let through as a probe.
+func (impl *circuitBreakerLocationClientImpl) abandon() {
+	impl.mutex.Lock()
+	defer impl.mutex.Unlock()
+
+	if impl.state == circuitHalfOpen {
+		impl.state = circuitOpen
+	}
+}
+
+// record updates the state of the circuit with the outcome of a lookup
+func (impl *circuitBreakerLocationClientImpl) record(failed bool) {
+	impl.mutex.Lock()
+	defer impl.mutex.Unlock()
+
+	if !failed {
+		impl.state = circuitClosed
+		impl.failures = 0
+		return
+	}
+
+	impl.failures++
+	if impl.state == circuitHalfOpen || impl.failures >= impl.Options.FailureThreshold {
+		if impl.state != circuitOpen {
+			ipLocationMetrics.Add("circuitOpened", 1)
+		}
+		impl.state = circuitOpen
+		impl.openedAt = impl.now()
+	}
+}
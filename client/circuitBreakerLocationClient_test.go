@@ -0,0 +1,126 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerLocationClient(t *testing.T) {
+	upstreamErr := statusError(http.StatusServiceUnavailable, 0)
+	notFoundErr := fmt.Errorf("%w: reserved ip address", ErrLocationNotFound)
+
+	fake := &fakeLocationClient{}
+	client := NewCircuitBreakerLocationClient(fake, CircuitBreakerOptions{FailureThreshold: 2, OpenTimeout: time.Minute}).(*circuitBreakerLocationClientImpl)
+	now := time.Now()
+	client.now = func() time.Time { return now }
+
+	steps := []struct {
+		name          string
+		elapsed       time.Duration
+		result        fakeResult
+		expectedErr   error
+		expectedCalls int
+	}{
+		{"+ve:ShouldLetLookupsThroughWhenClosed", 0, fakeResult{country: "CY"}, nil, 1},
+		{"+ve:ShouldNotCountMissingLocationsAsFailures", 0, fakeResult{err: notFoundErr}, ErrLocationNotFound, 2},
+		{"-ve:ShouldCountFirstFailure", 0, fakeResult{err: upstreamErr}, upstreamErr, 3},
+		{"-ve:ShouldOpenAfterConsecutiveFailures", 0, fakeResult{err: upstreamErr}, upstreamErr, 4},
+		{"-ve:ShouldFailFastWhenOpen", 30 * time.Second, fakeResult{country: "CY"}, ErrCircuitOpen, 4},
+		{"-ve:ShouldOpenAgainWhenProbeFails", 31 * time.Second, fakeResult{err: upstreamErr}, upstreamErr, 5},
+		{"-ve:ShouldFailFastAfterFailedProbe", 30 * time.Second, fakeResult{country: "CY"}, ErrCircuitOpen, 5},
+		{"+ve:ShouldCloseWhenProbeSucceeds", 31 * time.Second, fakeResult{country: "CY"}, nil, 6},
+		{"+ve:ShouldLetLookupsThroughWhenClosedAgain", 0, fakeResult{err: upstreamErr}, upstreamErr, 7},
+	}
+	for _, step := range steps {
+		t.Run(step.name, func(t *testing.T) {
+			now = now.Add(step.elapsed)
+			fake.results = []fakeResult{step.result}
+
+			_, err := client.GetLocation(context.Background(), "1.2.3.4")
+
+			if (step.expectedErr == nil) != (err == nil) || (step.expectedErr != nil && !errors.Is(err, step.expectedErr)) {
+				t.Errorf("expected error %v, got %v", step.expectedErr, err)
+			}
+			if step.expectedCalls != len(fake.calls) {
+				t.Errorf("expected %d calls, got %d", step.expectedCalls, len(fake.calls))
+			}
+		})
+	}
+}
+
+func TestCircuitBreakerLocationClientProbesOnce(t *testing.T) {
+	blocked := make(chan struct{})
+	probing := make(chan struct{})
+	probe := &blockingLocationClient{started: probing, release: blocked}
+	client := NewCircuitBreakerLocationClient(probe, CircuitBreakerOptions{}).(*circuitBreakerLocationClientImpl)
+	client.state = circuitOpen
+
+	go client.GetLocation(context.Background(), "1.2.3.4")
+	<-probing
+
+	if _, err := client.GetLocation(context.Background(), "1.2.3.4"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("expected lookups to fail fast while probing, got %v", err)
+	}
+	close(blocked)
+}
+
+// blockingLocationClient signals when a lookup has started and blocks it until released
+type blockingLocationClient struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (client *blockingLocationClient) GetLocation(ctx context.Context, ip string) (Location, error) {
+	close(client.started)
+	<-client.release
+	return Location{Country: "CY"}, nil
+}
+
+func TestCircuitBreakerLocationClientIgnoresCallerCancellation(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancelExpired()
+
+	tests := []struct {
+		name          string
+		ctx           context.Context
+		err           error
+		probe         bool
+		expectedState circuitState
+	}{
+		{"+ve:ShouldNotCountCancelledLookup", cancelled, fmt.Errorf("lookup: %w", context.Canceled), false, circuitClosed},
+		{"+ve:ShouldNotCountExpiredLookup", expired, fmt.Errorf("lookup: %w", context.DeadlineExceeded), false, circuitClosed},
+		{"+ve:ShouldProbeAgainAfterAbandonedProbe", cancelled, context.Canceled, true, circuitOpen},
+		{"-ve:ShouldCountTimeoutOfProvider", context.Background(), context.DeadlineExceeded, false, circuitOpen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeLocationClient{results: []fakeResult{{err: tt.err}}}
+			client := NewCircuitBreakerLocationClient(fake, CircuitBreakerOptions{FailureThreshold: 1}).(*circuitBreakerLocationClientImpl)
+			if tt.probe {
+				// the lookup is let through as a probe, the open timeout has elapsed
+				client.state = circuitOpen
+				client.openedAt = time.Now().Add(-time.Hour)
+			}
+
+			if _, err := client.GetLocation(tt.ctx, "1.2.3.4"); !errors.Is(err, tt.err) {
+				t.Errorf("expected error %v, got %v", tt.err, err)
+			}
+
+			if tt.expectedState != client.state {
+				t.Errorf("expected state %v, got %v", tt.expectedState, client.state)
+			}
+			if tt.expectedState == circuitClosed && client.failures != 0 {
+				t.Errorf("expected no failure, got %d", client.failures)
+			}
+			if tt.probe && !client.allow() {
+				t.Errorf("expected the next lookup to be let through as a probe")
+			}
+		})
+	}
+}
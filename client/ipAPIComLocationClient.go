@@ -0,0 +1,40 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	apiError "xm/error"
+)
+
+// ipAPIComResponse is the payload of ip-api.com, status is either "success" or "fail" with the reason in message
+type ipAPIComResponse struct {
+	Status      string `json:"status"`
+	Message     string `json:"message"`
+	CountryCode string `json:"countryCode"`
+}
+
+// NewIPAPIComLocationClient returns an IPLocationClient calling ip-api.com (e.g. http://ip-api.com)
+func NewIPAPIComLocationClient(url string) IPLocationClient {
+	return &ipAPIComLocationClientImpl{BaseURL: url, HTTPClient: &http.Client{}}
+}
+
+type ipAPIComLocationClientImpl struct {
+	BaseURL    string
+	HTTPClient *http.Client
+}
+
+// GetLocation gets location of ip
+func (impl *ipAPIComLocationClientImpl) GetLocation(ctx context.Context, ip string) (Location, error) {
+	apiURL := fmt.Sprintf("%s/json/%s?fields=status,message,countryCode", impl.BaseURL, ip)
+
+	var response ipAPIComResponse
+	if err := getJSON(ctx, impl.HTTPClient, apiURL, nil, &response); err != nil {
+		return Location{}, err
+	}
+
+	if response.Status != "success" || len(response.CountryCode) == 0 {
+		return Location{}, apiError.NewAPIClientError(apiURL, nil, nil, fmt.Errorf("%w: %s", ErrLocationNotFound, response.Message))
+	}
+	return Location{Country: response.CountryCode, Provider: ProviderIPAPICom}, nil
+}
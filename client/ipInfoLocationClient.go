@@ -0,0 +1,45 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	apiError "xm/error"
+)
+
+// ipInfoResponse is the payload of ipinfo, bogon is set for reserved ips which don't have a country
+type ipInfoResponse struct {
+	Country string `json:"country"`
+	Bogon   bool   `json:"bogon"`
+}
+
+// NewIPInfoLocationClient returns an IPLocationClient calling ipinfo (e.g. https://ipinfo.io), the token is optional
+func NewIPInfoLocationClient(url string, token string) IPLocationClient {
+	return &ipInfoLocationClientImpl{BaseURL: url, Token: token, HTTPClient: &http.Client{}}
+}
+
+type ipInfoLocationClientImpl struct {
+	BaseURL    string
+	Token      string
+	HTTPClient *http.Client
+}
+
+// GetLocation gets location of ip
+func (impl *ipInfoLocationClientImpl) GetLocation(ctx context.Context, ip string) (Location, error) {
+	apiURL := fmt.Sprintf("%s/%s/json", impl.BaseURL, ip)
+
+	headers := map[string]string{"Accept": "application/json"}
+	if len(impl.Token) > 0 {
+		headers["Authorization"] = "Bearer " + impl.Token
+	}
+
+	var response ipInfoResponse
+	if err := getJSON(ctx, impl.HTTPClient, apiURL, headers, &response); err != nil {
+		return Location{}, err
+	}
+
+	if response.Bogon || len(response.Country) == 0 {
+		return Location{}, apiError.NewAPIClientError(apiURL, nil, nil, fmt.Errorf("%w: response without country", ErrLocationNotFound))
+	}
+	return Location{Country: response.Country, Provider: ProviderIPInfo}, nil
+}
@@ -1,15 +1,36 @@
 package client
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"time"
 	apiError "xm/error"
 )
 
+// ErrLocationNotFound is the cause of the error of a lookup that has been answered without a location, e.g. for a
+// reserved ip, as opposed to the lookup failing
+var ErrLocationNotFound = errors.New("location not found")
+
+// IsUpstreamUnavailable reports whether the location couldn't be looked up, because the provider failed or didn't answer
+func IsUpstreamUnavailable(err error) bool {
+	return err != nil && !errors.Is(err, ErrLocationNotFound)
+}
+
+// Location is the location of an ip and the provider that has looked it up
+type Location struct {
+	// Country is the ISO country code
+	Country string
+	// Provider is the name of the provider that answered, or of the providers that agreed separated by commas
+	Provider string
+}
+
 type IPLocationClient interface {
-	GetLocation(ip string) (string, error)
+	GetLocation(ctx context.Context, ip string) (Location, error)
 }
 
 // NewIpLocationClient returns a new instance of IpLocationClient
@@ -26,19 +47,39 @@ type ipLocationClientImpl struct {
 }
 
 // GetLocation gets location of ip
-func (impl *ipLocationClientImpl) GetLocation(ip string) (string, error) {
+func (impl *ipLocationClientImpl) GetLocation(ctx context.Context, ip string) (Location, error) {
 	apiURL := fmt.Sprintf("%s/%s/json/", impl.BaseURL, ip)
 
-	req, err := http.NewRequest(http.MethodGet, apiURL, nil)
+	var mapResponse map[string]interface{}
+	if err := getJSON(ctx, impl.HTTPClient, apiURL, map[string]string{"User-Agent": "ipapi.co/#go-v1.5"}, &mapResponse); err != nil {
+		return Location{}, err
+	}
+
+	if isErr, ok := mapResponse["error"].(bool); ok && isErr {
+		return Location{}, apiError.NewAPIClientError(apiURL, nil, nil, fmt.Errorf("%w: %v", ErrLocationNotFound, mapResponse["reason"]))
+	}
+
+	country, ok := mapResponse["country"].(string)
+	if !ok {
+		return Location{}, apiError.NewAPIClientError(apiURL, nil, nil, fmt.Errorf("%w: response without country", ErrLocationNotFound))
+	}
+	return Location{Country: country, Provider: ProviderIPAPI}, nil
+}
+
+// getJSON calls the API and parses the JSON payload of the response
+func getJSON(ctx context.Context, httpClient *http.Client, apiURL string, headers map[string]string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, apiURL, nil)
 	if err != nil {
-		return "", apiError.NewAPIClientError(apiURL, nil, nil, fmt.Errorf("unable to create HTTP request: %w", err))
+		return apiError.NewAPIClientError(apiURL, nil, nil, fmt.Errorf("unable to create HTTP request: %w", err))
 	}
 
-	req.Header.Set("User-Agent", "ipapi.co/#go-v1.5")
+	for header, value := range headers {
+		req.Header.Set(header, value)
+	}
 
-	resp, err := impl.HTTPClient.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		return "", apiError.NewAPIClientError(apiURL, nil, nil, fmt.Errorf("unable to invoke API: %w", err))
+		return apiError.NewAPIClientError(apiURL, nil, nil, fmt.Errorf("unable to invoke API: %w", err))
 	}
 
 	defer resp.Body.Close()
@@ -47,17 +88,25 @@ func (impl *ipLocationClientImpl) GetLocation(ip string) (string, error) {
 		if responseBodyBytes, err := ioutil.ReadAll(resp.Body); err == nil {
 			responseBodyString = string(responseBodyBytes)
 		}
-		return "", apiError.NewAPIClientError(apiURL, &resp.StatusCode, &responseBodyString, fmt.Errorf("received non-ok code: %v", resp.StatusCode))
+		retryAfter := parseRetryAfter(resp.Header.Get("Retry-After"))
+		return apiError.NewAPIClientErrorWithRetryAfter(apiURL, &resp.StatusCode, &responseBodyString, retryAfter, fmt.Errorf("received non-ok code: %v", resp.StatusCode))
 	}
 
-	var mapResponse map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&mapResponse); err != nil {
-		return "", apiError.NewAPIClientError(apiURL, &resp.StatusCode, nil, fmt.Errorf("unable parse response payload: %w", err))
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return apiError.NewAPIClientError(apiURL, &resp.StatusCode, nil, fmt.Errorf("unable parse response payload: %w", err))
 	}
+	return nil
+}
 
-	if isErr, ok := mapResponse["error"].(bool); ok && isErr {
-		return "", apiError.NewAPIClientError(apiURL, nil, nil, fmt.Errorf(mapResponse["reason"].(string)))
+// parseRetryAfter parses the Retry-After header, either delay seconds or an HTTP date, returns 0 when it is missing
+func parseRetryAfter(retryAfter string) time.Duration {
+	if seconds, err := strconv.Atoi(retryAfter); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
 	}
-
-	return mapResponse["country"].(string), nil
+	if date, err := http.ParseTime(retryAfter); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+	return 0
 }
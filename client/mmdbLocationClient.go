@@ -0,0 +1,120 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"github.com/oschwald/maxminddb-golang"
+	"io/ioutil"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// mmdbRecord is the part of a GeoLite2 or DB-IP country/city record holding the country
+type mmdbRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	RegisteredCountry struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"registered_country"`
+}
+
+// NewMMDBLocationClient returns an IPLocationClient looking up the country in a local MaxMind DB file (e.g. GeoLite2
+// or DB-IP). The file is reloaded when its modification time or size changes, which is checked at most once every
+// reloadInterval, so that the database can be updated without a restart.
+func NewMMDBLocationClient(path string, reloadInterval time.Duration) (IPLocationClient, error) {
+	client := &mmdbLocationClientImpl{Path: path, ReloadInterval: reloadInterval}
+	if err := client.load(); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+type mmdbLocationClientImpl struct {
+	Path           string
+	ReloadInterval time.Duration
+
+	mutex     sync.RWMutex
+	reader    *maxminddb.Reader
+	modTime   time.Time
+	size      int64
+	checkedAt time.Time
+}
+
+// GetLocation gets the ISO country code of ip
+func (impl *mmdbLocationClientImpl) GetLocation(ctx context.Context, ip string) (Location, error) {
+	impl.reloadIfChanged()
+
+	address := net.ParseIP(ip)
+	if address == nil {
+		if host, _, err := net.SplitHostPort(ip); err == nil {
+			address = net.ParseIP(host)
+		}
+	}
+	if address == nil {
+		return Location{}, fmt.Errorf("%w: invalid ip address %q", ErrLocationNotFound, ip)
+	}
+
+	impl.mutex.RLock()
+	defer impl.mutex.RUnlock()
+
+	var record mmdbRecord
+	if err := impl.reader.Lookup(address, &record); err != nil {
+		return Location{}, fmt.Errorf("unable to look up %s in %s: %w", ip, impl.Path, err)
+	}
+	if len(record.Country.ISOCode) > 0 {
+		return Location{Country: record.Country.ISOCode, Provider: ProviderMMDB}, nil
+	}
+	if len(record.RegisteredCountry.ISOCode) > 0 {
+		return Location{Country: record.RegisteredCountry.ISOCode, Provider: ProviderMMDB}, nil
+	}
+	return Location{}, fmt.Errorf("%w: %s isn't in %s", ErrLocationNotFound, ip, impl.Path)
+}
+
+// reloadIfChanged reloads the database when the file has changed since it has been loaded. When the new file can't be
+// loaded (e.g. it is still being written) the loaded database is kept and the reload is tried again on the next check.
+func (impl *mmdbLocationClientImpl) reloadIfChanged() {
+	impl.mutex.RLock()
+	due := time.Since(impl.checkedAt) >= impl.ReloadInterval
+	impl.mutex.RUnlock()
+	if !due {
+		return
+	}
+
+	info, err := os.Stat(impl.Path)
+
+	impl.mutex.Lock()
+	impl.checkedAt = time.Now()
+	changed := err == nil && (!info.ModTime().Equal(impl.modTime) || info.Size() != impl.size)
+	impl.mutex.Unlock()
+
+	if changed {
+		impl.load()
+	}
+}
+
+// load reads the whole file into memory, so that it can be replaced or overwritten while it is used
+func (impl *mmdbLocationClientImpl) load() error {
+	info, err := os.Stat(impl.Path)
+	if err != nil {
+		return fmt.Errorf("unable to open MaxMind DB: %w", err)
+	}
+	buffer, err := ioutil.ReadFile(impl.Path)
+	if err != nil {
+		return fmt.Errorf("unable to read MaxMind DB: %w", err)
+	}
+	reader, err := maxminddb.FromBytes(buffer)
+	if err != nil {
+		return fmt.Errorf("unable to load MaxMind DB: %w", err)
+	}
+
+	impl.mutex.Lock()
+	defer impl.mutex.Unlock()
+	impl.reader = reader
+	impl.modTime = info.ModTime()
+	impl.size = info.Size()
+	impl.checkedAt = time.Now()
+	return nil
+}
@@ -0,0 +1,113 @@
+package client
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCountryMMDB writes an IPv4 MaxMind DB mapping 1.0.0.0/8 to the country
+func writeCountryMMDB(t *testing.T, path string, country string) {
+	const nodeCount = 8
+
+	// the search tree follows the bits of 1.0.0.0/8 (00000001), every other branch has no data
+	var tree []byte
+	record := func(value int) []byte { return []byte{byte(value >> 16), byte(value >> 8), byte(value)} }
+	for node := 0; node < nodeCount-1; node++ {
+		tree = append(tree, record(node+1)...)
+		tree = append(tree, record(nodeCount)...)
+	}
+	tree = append(tree, record(nodeCount)...)
+	tree = append(tree, record(nodeCount+16)...)
+
+	str := func(value string) []byte { return append([]byte{0x40 | byte(len(value))}, value...) }
+	var data []byte
+	data = append(data, 0xE1)
+	data = append(data, str("country")...)
+	data = append(data, 0xE1)
+	data = append(data, str("iso_code")...)
+	data = append(data, str(country)...)
+
+	var metadata []byte
+	metadata = append(metadata, 0xAB, 0xCD, 0xEF)
+	metadata = append(metadata, "MaxMind.com"...)
+	metadata = append(metadata, 0xE3)
+	metadata = append(metadata, str("node_count")...)
+	metadata = append(metadata, 0xC1, nodeCount)
+	metadata = append(metadata, str("record_size")...)
+	metadata = append(metadata, 0xA1, 24)
+	metadata = append(metadata, str("ip_version")...)
+	metadata = append(metadata, 0xA1, 4)
+
+	database := append(append(append(tree, make([]byte, 16)...), data...), metadata...)
+	if err := os.WriteFile(path, database, 0644); err != nil {
+		t.Fatalf("unable to write MaxMind DB: %v", err)
+	}
+}
+
+func TestMMDBLocationClient(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "country.mmdb")
+	writeCountryMMDB(t, path, "CY")
+
+	client, err := NewMMDBLocationClient(path, 0)
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+
+	tests := []struct {
+		name            string
+		ip              string
+		expectedCountry string
+		expectedError   bool
+	}{
+		{"+ve:ShouldFindCountry", "1.2.3.4", "CY", false},
+		{"+ve:ShouldFindCountryOfAddressWithPort", "1.2.3.4:8080", "CY", false},
+		{"-ve:ShouldFailWhenAddressIsNotInDatabase", "2.2.3.4", "", true},
+		{"-ve:ShouldFailWhenAddressIsInvalid", "unknown", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			location, err := client.GetLocation(context.Background(), tt.ip)
+			if tt.expectedError != (err != nil) {
+				t.Fatalf("expected error %v, got %v", tt.expectedError, err)
+			}
+			if tt.expectedCountry != location.Country {
+				t.Errorf("expected country %v, got %v", tt.expectedCountry, location.Country)
+			}
+			if !tt.expectedError && location.Provider != ProviderMMDB {
+				t.Errorf("expected provider %v, got %v", ProviderMMDB, location.Provider)
+			}
+		})
+	}
+}
+
+func TestMMDBLocationClientReloadsChangedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "country.mmdb")
+	writeCountryMMDB(t, path, "CY")
+
+	client, err := NewMMDBLocationClient(path, 0)
+	if err != nil {
+		t.Fatalf("unable to create client: %v", err)
+	}
+
+	writeCountryMMDB(t, path, "GR")
+	os.Chtimes(path, time.Now().Add(time.Minute), time.Now().Add(time.Minute))
+
+	if location, err := client.GetLocation(context.Background(), "1.2.3.4"); err != nil || location.Country != "GR" {
+		t.Errorf("expected country of reloaded database GR, got %v (%v)", location.Country, err)
+	}
+
+	os.WriteFile(path, []byte("corrupt"), 0644)
+
+	if location, err := client.GetLocation(context.Background(), "1.2.3.4"); err != nil || location.Country != "GR" {
+		t.Errorf("expected corrupt database to be ignored, got %v (%v)", location.Country, err)
+	}
+}
+
+func TestMMDBLocationClientFailsWithoutDatabase(t *testing.T) {
+	if _, err := NewMMDBLocationClient(filepath.Join(t.TempDir(), "missing.mmdb"), time.Minute); err == nil {
+		t.Errorf("expected error for missing database")
+	}
+}
@@ -0,0 +1,204 @@
+package client
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"expvar"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+	apiError "xm/error"
+)
+
+// ipLocationMetrics are the cache hits and misses and the retries of the ip location clients, published on /debug/vars
+var ipLocationMetrics = expvar.NewMap("ipLocation")
+
+// ResilienceOptions configures the cache, the deadlines and the retries of NewResilientLocationClient,
+// zero values fall back to the defaults
+type ResilienceOptions struct {
+	// CacheTTL is how long a location is cached (default: 1h)
+	CacheTTL time.Duration
+	// CacheSize is the maximum number of cached locations, the least recently used are evicted (default: 10000)
+	CacheSize int
+	// Timeout is the deadline of every attempt (default: 2s)
+	Timeout time.Duration
+	// MaxRetries is the number of retries of 429 and 5xx responses (default: 2), NoRetries disables them
+	MaxRetries int
+	// MinBackoff is the delay before the first retry, doubled on every retry (default: 100ms)
+	MinBackoff time.Duration
+	// MaxBackoff caps the delay between retries, including the delays asked by Retry-After (default: 2s)
+	MaxBackoff time.Duration
+}
+
+// NoRetries is the MaxRetries of the clients that don't retry, as 0 falls back to the default
+const NoRetries = -1
+
+// withDefaults returns the options where zero values have been replaced with the defaults
+func (options ResilienceOptions) withDefaults() ResilienceOptions {
+	if options.CacheTTL == 0 {
+		options.CacheTTL = time.Hour
+	}
+	if options.CacheSize == 0 {
+		options.CacheSize = 10000
+	}
+	if options.Timeout == 0 {
+		options.Timeout = 2 * time.Second
+	}
+	switch {
+	case options.MaxRetries == 0:
+		options.MaxRetries = 2
+	case options.MaxRetries < 0:
+		options.MaxRetries = 0
+	}
+	if options.MinBackoff == 0 {
+		options.MinBackoff = 100 * time.Millisecond
+	}
+	if options.MaxBackoff == 0 {
+		options.MaxBackoff = 2 * time.Second
+	}
+	return options
+}
+
+// NewResilientLocationClient decorates the client with an LRU cache of the locations by ip, a deadline for every call
+// and retries of the calls answered with 429 or 5xx, using exponential backoff with jitter or the delay of Retry-After
+func NewResilientLocationClient(client IPLocationClient, options ResilienceOptions) IPLocationClient {
+	return &resilientLocationClientImpl{
+		Client:  client,
+		Options: options.withDefaults(),
+		entries: map[string]*list.Element{},
+		lru:     list.New(),
+		sleep:   sleepContext,
+	}
+}
+
+type resilientLocationClientImpl struct {
+	Client  IPLocationClient
+	Options ResilienceOptions
+
+	mutex   sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	sleep   func(ctx context.Context, delay time.Duration) error
+}
+
+// cacheEntry is a cached location, the elements of the lru list
+type cacheEntry struct {
+	ip        string
+	location  Location
+	expiresAt time.Time
+}
+
+// GetLocation gets location of ip from the cache or from the decorated client
+func (impl *resilientLocationClientImpl) GetLocation(ctx context.Context, ip string) (Location, error) {
+	if location, ok := impl.cached(ip); ok {
+		ipLocationMetrics.Add("cacheHits", 1)
+		return location, nil
+	}
+	ipLocationMetrics.Add("cacheMisses", 1)
+
+	for attempt := 0; ; attempt++ {
+		location, err := impl.getLocation(ctx, ip)
+		if err == nil {
+			impl.cache(ip, location)
+			return location, nil
+		}
+
+		delay, retry := impl.retryDelay(attempt, err)
+		if !retry {
+			return Location{}, err
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return Location{}, err
+		}
+		ipLocationMetrics.Add("retries", 1)
+		if sleepErr := impl.sleep(ctx, delay); sleepErr != nil {
+			return Location{}, err
+		}
+	}
+}
+
+// getLocation calls the decorated client with the deadline of an attempt
+func (impl *resilientLocationClientImpl) getLocation(ctx context.Context, ip string) (Location, error) {
+	ctx, cancel := context.WithTimeout(ctx, impl.Options.Timeout)
+	defer cancel()
+	return impl.Client.GetLocation(ctx, ip)
+}
+
+// retryDelay returns the delay before the next attempt and whether the call should be retried after the error
+func (impl *resilientLocationClientImpl) retryDelay(attempt int, err error) (time.Duration, bool) {
+	var clientErr apiError.APIClientError
+	if attempt >= impl.Options.MaxRetries || !errors.As(err, &clientErr) || clientErr.GetHTTPStatusCode() == nil {
+		return 0, false
+	}
+	status := *clientErr.GetHTTPStatusCode()
+	if status != http.StatusTooManyRequests && status < http.StatusInternalServerError {
+		return 0, false
+	}
+
+	delay := clientErr.GetRetryAfter()
+	if delay == 0 {
+		// full jitter: a random delay up to the exponential backoff
+		backoff := impl.Options.MinBackoff << attempt
+		if backoff > impl.Options.MaxBackoff || backoff <= 0 {
+			backoff = impl.Options.MaxBackoff
+		}
+		delay = time.Duration(rand.Int63n(int64(backoff)) + 1)
+	}
+	if delay > impl.Options.MaxBackoff {
+		delay = impl.Options.MaxBackoff
+	}
+	return delay, true
+}
+
+// cached returns the cached location of ip, unless it has expired
+func (impl *resilientLocationClientImpl) cached(ip string) (Location, bool) {
+	impl.mutex.Lock()
+	defer impl.mutex.Unlock()
+
+	element, ok := impl.entries[ip]
+	if !ok {
+		return Location{}, false
+	}
+	entry := element.Value.(*cacheEntry)
+	if time.Now().After(entry.expiresAt) {
+		impl.lru.Remove(element)
+		delete(impl.entries, ip)
+		return Location{}, false
+	}
+	impl.lru.MoveToFront(element)
+	return entry.location, true
+}
+
+// cache adds the location of ip to the cache, evicting the least recently used location when the cache is full
+func (impl *resilientLocationClientImpl) cache(ip string, location Location) {
+	impl.mutex.Lock()
+	defer impl.mutex.Unlock()
+
+	entry := &cacheEntry{ip: ip, location: location, expiresAt: time.Now().Add(impl.Options.CacheTTL)}
+	if element, ok := impl.entries[ip]; ok {
+		element.Value = entry
+		impl.lru.MoveToFront(element)
+		return
+	}
+
+	impl.entries[ip] = impl.lru.PushFront(entry)
+	for impl.lru.Len() > impl.Options.CacheSize {
+		oldest := impl.lru.Back()
+		impl.lru.Remove(oldest)
+		delete(impl.entries, oldest.Value.(*cacheEntry).ip)
+	}
+}
+
+// sleepContext waits for the delay, unless the context is done first
+func sleepContext(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,130 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+	apiError "xm/error"
+)
+
+// fakeLocationClient answers with the queued results, one per call, and records the calls
+type fakeLocationClient struct {
+	results   []fakeResult
+	calls     []string
+	deadlines []time.Duration
+}
+
+type fakeResult struct {
+	country string
+	err     error
+}
+
+func (fake *fakeLocationClient) GetLocation(ctx context.Context, ip string) (Location, error) {
+	fake.calls = append(fake.calls, ip)
+	if deadline, ok := ctx.Deadline(); ok {
+		fake.deadlines = append(fake.deadlines, time.Until(deadline))
+	}
+	result := fake.results[0]
+	if len(fake.results) > 1 {
+		fake.results = fake.results[1:]
+	}
+	return Location{Country: result.country, Provider: "fake"}, result.err
+}
+
+func statusError(status int, retryAfter time.Duration) error {
+	return apiError.NewAPIClientErrorWithRetryAfter("https://ipapi.co", &status, nil, retryAfter, errors.New("received non-ok code"))
+}
+
+func TestResilientLocationClientRetries(t *testing.T) {
+	tests := []struct {
+		name            string
+		results         []fakeResult
+		expectedCountry string
+		expectedCalls   int
+		expectedDelays  []time.Duration
+		maxRetries      int
+	}{
+		{"+ve:ShouldRetryServerErrors", []fakeResult{{err: statusError(http.StatusBadGateway, 0)}, {country: "CY"}}, "CY", 2, nil, 0},
+		{"+ve:ShouldHonourRetryAfter", []fakeResult{{err: statusError(http.StatusTooManyRequests, time.Second)}, {country: "CY"}}, "CY", 2, []time.Duration{time.Second}, 0},
+		{"+ve:ShouldCapRetryAfter", []fakeResult{{err: statusError(http.StatusServiceUnavailable, time.Hour)}, {country: "CY"}}, "CY", 2, []time.Duration{5 * time.Second}, 0},
+		{"-ve:ShouldGiveUpAfterMaxRetries", []fakeResult{{err: statusError(http.StatusInternalServerError, 0)}}, "", 3, nil, 0},
+		{"-ve:ShouldNotRetryClientErrors", []fakeResult{{err: statusError(http.StatusBadRequest, 0)}}, "", 1, nil, 0},
+		{"-ve:ShouldNotRetryOtherErrors", []fakeResult{{err: errors.New("reserved ip address")}}, "", 1, nil, 0},
+		{"-ve:ShouldNotRetryWhenRetriesAreDisabled", []fakeResult{{err: statusError(http.StatusBadGateway, 0)}, {country: "CY"}}, "", 1, nil, NoRetries},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeLocationClient{results: tt.results}
+			client := NewResilientLocationClient(fake, ResilienceOptions{MaxRetries: tt.maxRetries, MaxBackoff: 5 * time.Second}).(*resilientLocationClientImpl)
+			var delays []time.Duration
+			client.sleep = func(ctx context.Context, delay time.Duration) error {
+				delays = append(delays, delay)
+				return nil
+			}
+
+			location, err := client.GetLocation(context.Background(), "1.2.3.4")
+
+			if tt.expectedCountry != location.Country || (len(tt.expectedCountry) > 0) != (err == nil) {
+				t.Errorf("expected country %v, got %v (%v)", tt.expectedCountry, location.Country, err)
+			}
+			if tt.expectedCalls != len(fake.calls) {
+				t.Errorf("expected %d calls, got %d", tt.expectedCalls, len(fake.calls))
+			}
+			for index, delay := range delays {
+				if delay <= 0 || delay > 5*time.Second {
+					t.Errorf("expected delay within max backoff, got %v", delay)
+				}
+				if index < len(tt.expectedDelays) && tt.expectedDelays[index] != delay {
+					t.Errorf("expected delay %v, got %v", tt.expectedDelays[index], delay)
+				}
+			}
+		})
+	}
+}
+
+func TestResilientLocationClientDeadlines(t *testing.T) {
+	fake := &fakeLocationClient{results: []fakeResult{{err: statusError(http.StatusTooManyRequests, time.Minute)}, {country: "CY"}}}
+	client := NewResilientLocationClient(fake, ResilienceOptions{Timeout: time.Second, MaxBackoff: time.Hour})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if _, err := client.GetLocation(ctx, "1.2.3.4"); err == nil {
+		t.Errorf("expected error when Retry-After exceeds the deadline of the request")
+	}
+
+	if len(fake.calls) != 1 || len(fake.deadlines) != 1 || fake.deadlines[0] > time.Second {
+		t.Errorf("expected one call with the deadline of an attempt, got %v", fake.deadlines)
+	}
+}
+
+func TestResilientLocationClientCache(t *testing.T) {
+	fake := &fakeLocationClient{results: []fakeResult{{country: "CY"}}}
+	client := NewResilientLocationClient(fake, ResilienceOptions{CacheSize: 2, CacheTTL: time.Hour})
+
+	for _, ip := range []string{"1.1.1.1", "1.1.1.1", "2.2.2.2", "1.1.1.1", "3.3.3.3", "1.1.1.1", "2.2.2.2"} {
+		if location, err := client.GetLocation(context.Background(), ip); err != nil || location.Country != "CY" {
+			t.Fatalf("expected country CY, got %v (%v)", location.Country, err)
+		}
+	}
+
+	// 2.2.2.2 has been evicted as the least recently used when 3.3.3.3 has been added
+	expectedCalls := []string{"1.1.1.1", "2.2.2.2", "3.3.3.3", "2.2.2.2"}
+	if len(expectedCalls) != len(fake.calls) {
+		t.Fatalf("expected calls %v, got %v", expectedCalls, fake.calls)
+	}
+	for index, ip := range fake.calls {
+		if expectedCalls[index] != ip {
+			t.Fatalf("expected calls %v, got %v", expectedCalls, fake.calls)
+		}
+	}
+
+	expiring := NewResilientLocationClient(fake, ResilienceOptions{CacheTTL: time.Nanosecond})
+	expiring.GetLocation(context.Background(), "4.4.4.4")
+	time.Sleep(time.Millisecond)
+	expiring.GetLocation(context.Background(), "4.4.4.4")
+	if calls := len(fake.calls) - len(expectedCalls); calls != 2 {
+		t.Errorf("expected expired location to be looked up again, got %d calls", calls)
+	}
+}
@@ -0,0 +1,228 @@
+package controller
+
+import (
+	"expvar"
+	"github.com/gorilla/mux"
+	uuid "github.com/satori/go.uuid"
+	"net/http"
+	"time"
+	"xm/app"
+	"xm/auth"
+	"xm/model"
+	"xm/ratelimit"
+	"xm/repository"
+)
+
+type apiKeyController struct {
+	app            *app.App
+	repository     repository.Repository
+	authentication authentication
+	trustedProxies trustedProxies
+	rateLimits     rateLimits
+}
+
+// NewAPIKeyController returns the controller of the admin endpoints managing the API keys, which always require an
+// authenticated caller with the keys:admin scope. The buckets of the rate limits are kept in the store, in memory when
+// it's nil.
+func NewAPIKeyController(app *app.App, apiKeyRepository repository.Repository, authenticator auth.Authenticator, rateLimitStore ratelimit.Store) *apiKeyController {
+	trustedProxies, err := parseTrustedProxies(app.Config().TrustedProxies)
+	if err != nil {
+		app.Logger.Fatal().Err(err).Msg("unable to parse trusted proxies, exiting the application!")
+	}
+	rateLimits, err := newRateLimits(app.Config(), rateLimitStore)
+	if err != nil {
+		app.Logger.Fatal().Err(err).Msg("unable to parse rate limits, exiting the application!")
+	}
+
+	return &apiKeyController{
+		app:            app,
+		repository:     apiKeyRepository,
+		authentication: authentication{authenticator: authenticator, required: true},
+		trustedProxies: trustedProxies,
+		rateLimits:     rateLimits,
+	}
+}
+
+// RegisterRoutes implements interface RouteSpecifier
+func (controller *apiKeyController) RegisterRoutes(muxRouter *mux.Router) {
+	router := muxRouter.PathPrefix("/api/keys").Subrouter()
+
+	router.HandleFunc("", controller.admin(controller.add)).Methods(http.MethodPost)
+	router.HandleFunc("", controller.admin(controller.getAll)).Methods(http.MethodGet)
+	router.HandleFunc("/{id}", controller.admin(controller.revoke)).Methods(http.MethodDelete)
+	router.HandleFunc("/{id}/rotate", controller.admin(controller.rotate)).Methods(http.MethodPost)
+
+	// the metrics expose the command line of the service, which can carry its secrets, so only admins are served them
+	muxRouter.HandleFunc("/debug/vars", controller.admin(expvar.Handler().ServeHTTP)).Methods(http.MethodGet)
+}
+
+// admin makes sure that caller has been granted the keys:admin scope and the admin role, and limits the rate of its
+// calls, before invoking actual handler
+func (controller *apiKeyController) admin(handlerFunc func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ip := controller.trustedProxies.clientIP(r)
+		if !controller.rateLimits.allowAuthentication(w, r, ip, controller.app.Logger) {
+			return
+		}
+		r, ok := controller.authentication.authenticate(w, r, model.ScopeKeysAdmin, model.RoleAdmin, controller.app.Logger)
+		if !ok {
+			return
+		}
+		if !controller.rateLimits.allow(w, r, rateLimitKeys, ip, controller.app.Logger) {
+			return
+		}
+		handlerFunc(w, r)
+	}
+}
+
+func (controller *apiKeyController) add(w http.ResponseWriter, r *http.Request) {
+	uow := repository.NewUnitOfWork(controller.app.DB, false)
+	defer uow.Complete()
+
+	reqDTO := apiKeyDTO{}
+	if err := unmarshalJSON(r, &reqDTO); err != nil {
+		controller.app.Logger.Err(err).Msg("unable to marshal request body")
+		respondError(w, err)
+		return
+	}
+
+	apiKey, key, err := model.NewAPIKey(reqDTO.Name, reqDTO.Role, reqDTO.Scopes)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	if err := controller.repository.Add(uow, apiKey); err != nil {
+		controller.app.Logger.Err(err).Msg("unable to add api key to db")
+		respondError(w, err)
+		return
+	}
+
+	uow.Commit()
+
+	controller.audit(r, apiKey, "created")
+	respDTO := toAPIKeyDTO(apiKey)
+	respDTO.Key = key
+	respondJSON(w, http.StatusCreated, respDTO)
+	return
+}
+
+func (controller *apiKeyController) getAll(w http.ResponseWriter, r *http.Request) {
+	uow := repository.NewUnitOfWork(controller.app.DB, true)
+	defer uow.Complete()
+
+	var apiKeys []model.APIKey
+	queryProcessors := []repository.QueryProcessor{repository.Order(repository.SortField{Column: "createdOn"})}
+	if err := controller.repository.GetAll(uow, &apiKeys, queryProcessors); err != nil {
+		controller.app.Logger.Err(err).Msg("unable to get api keys from db")
+		respondError(w, err)
+		return
+	}
+
+	respDTO := make([]apiKeyDTO, len(apiKeys))
+	for index := range apiKeys {
+		respDTO[index] = toAPIKeyDTO(&apiKeys[index])
+	}
+	respondJSON(w, http.StatusOK, respDTO)
+	return
+}
+
+func (controller *apiKeyController) revoke(w http.ResponseWriter, r *http.Request) {
+	uow := repository.NewUnitOfWork(controller.app.DB, false)
+	defer uow.Complete()
+
+	apiKey, ok := controller.getActiveAPIKey(w, uow, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	apiKey.Revoke(time.Now())
+	if err := controller.repository.UpdateColumns(uow, apiKey, "revokedOn"); err != nil {
+		controller.app.Logger.Err(err).Msg("unable to revoke api key in db")
+		respondError(w, err)
+		return
+	}
+
+	uow.Commit()
+
+	controller.audit(r, apiKey, "revoked")
+	respondJSON(w, http.StatusOK, toAPIKeyDTO(apiKey))
+	return
+}
+
+func (controller *apiKeyController) rotate(w http.ResponseWriter, r *http.Request) {
+	uow := repository.NewUnitOfWork(controller.app.DB, false)
+	defer uow.Complete()
+
+	apiKey, ok := controller.getActiveAPIKey(w, uow, mux.Vars(r)["id"])
+	if !ok {
+		return
+	}
+
+	key, err := apiKey.Rotate()
+	if err != nil {
+		controller.app.Logger.Err(err).Msg("unable to generate api key")
+		respondError(w, err)
+		return
+	}
+	if err := controller.repository.UpdateColumns(uow, apiKey, "prefix", "hash"); err != nil {
+		controller.app.Logger.Err(err).Msg("unable to rotate api key in db")
+		respondError(w, err)
+		return
+	}
+
+	uow.Commit()
+
+	controller.audit(r, apiKey, "rotated")
+	respDTO := toAPIKeyDTO(apiKey)
+	respDTO.Key = key
+	respondJSON(w, http.StatusOK, respDTO)
+	return
+}
+
+// getActiveAPIKey gets the API key that hasn't been revoked, otherwise responds 404 and returns false
+func (controller *apiKeyController) getActiveAPIKey(w http.ResponseWriter, uow *repository.UnitOfWork, id string) (*model.APIKey, bool) {
+	apiKey := &model.APIKey{}
+	if err := controller.repository.Get(uow, apiKey, uuid.FromStringOrNil(id)); err != nil {
+		if !err.IsRecordNotFoundError() {
+			controller.app.Logger.Err(err).Msg("unable get api key from db")
+		}
+		respondError(w, err)
+		return nil, false
+	}
+	if apiKey.IsRevoked() {
+		respondJSON(w, http.StatusNotFound, nil)
+		return nil, false
+	}
+	return apiKey, true
+}
+
+// audit logs the change of an API key with the identity of the admin making it
+func (controller *apiKeyController) audit(r *http.Request, apiKey *model.APIKey, change string) {
+	identity, _ := auth.IdentityFrom(r.Context())
+	controller.app.Logger.Info().Str("audit", "apiKey").Str("change", change).Str("apiKey", apiKey.ID.String()).
+		Str("subject", identity.Subject).Msg("api key " + change)
+}
+
+type apiKeyDTO struct {
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Scopes    []string   `json:"scopes"`
+	Role      string     `json:"role,omitempty"`
+	Prefix    string     `json:"prefix"`
+	CreatedOn time.Time  `json:"createdOn"`
+	RevokedOn *time.Time `json:"revokedOn,omitempty"`
+	// Key is only returned when the key is created or rotated
+	Key string `json:"key,omitempty"`
+}
+
+func toAPIKeyDTO(apiKey *model.APIKey) apiKeyDTO {
+	return apiKeyDTO{
+		ID:        apiKey.ID.String(),
+		Name:      apiKey.Name,
+		Scopes:    apiKey.ScopeList(),
+		Role:      apiKey.Role,
+		Prefix:    apiKey.Prefix,
+		CreatedOn: apiKey.CreatedAt,
+		RevokedOn: apiKey.RevokedAt,
+	}
+}
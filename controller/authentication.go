@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"errors"
+	"github.com/rs/zerolog"
+	"net/http"
+	"xm/auth"
+	apiError "xm/error"
+)
+
+// authentication authenticates the callers of the routes and checks the scopes and the roles granted to them
+type authentication struct {
+	authenticator auth.Authenticator
+	// required rejects anonymous callers, otherwise they are let through the routes that don't require a role
+	required bool
+}
+
+// authenticate returns the request carrying the identity of the caller in its context. When the caller can't be
+// authenticated or hasn't been granted the scope or the role the error is responded and false is returned. Anonymous
+// callers have no role, so they are rejected by the routes requiring one.
+func (authentication authentication) authenticate(w http.ResponseWriter, r *http.Request, scope string, role string, logger *zerolog.Logger) (*http.Request, bool) {
+	identity, err := authentication.authenticator.Authenticate(r)
+	if err != nil {
+		if !errors.Is(err, auth.ErrInvalidCredentials) {
+			logger.Err(err).Msg("unable to authenticate caller")
+			respondError(w, err)
+			return nil, false
+		}
+		logger.Info().Err(err).Str("path", r.URL.Path).Msg("rejecting request with invalid credentials")
+		respondJSON(w, http.StatusUnauthorized, map[string]string{"error": apiError.ErrorCodeUnauthenticated})
+		return nil, false
+	}
+
+	if identity == nil {
+		if authentication.required || len(role) > 0 {
+			respondJSON(w, http.StatusUnauthorized, map[string]string{"error": apiError.ErrorCodeUnauthenticated})
+			return nil, false
+		}
+		return r, true
+	}
+
+	if len(scope) > 0 && !identity.HasScope(scope) {
+		logger.Info().Str("subject", identity.Subject).Str("scope", scope).Str("path", r.URL.Path).
+			Msg("rejecting request without the required scope")
+		respondJSON(w, http.StatusForbidden, map[string]string{"error": apiError.ErrorCodeForbidden, "scope": scope})
+		return nil, false
+	}
+	if len(role) > 0 && !identity.HasRole(role) {
+		logger.Info().Str("subject", identity.Subject).Strs("roles", identity.Roles).Str("role", role).Str("path", r.URL.Path).
+			Msg("rejecting request without the required role")
+		respondJSON(w, http.StatusForbidden, map[string]string{"error": apiError.ErrorCodeForbidden, "role": role})
+		return nil, false
+	}
+	return r.WithContext(auth.WithIdentity(r.Context(), identity)), true
+}
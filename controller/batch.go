@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"net/http"
+	"xm/auth"
+	apiError "xm/error"
+	"xm/model"
+	"xm/repository"
+)
+
+const (
+	batchOperationCreate = "create"
+	batchOperationUpdate = "update"
+	batchOperationDelete = "delete"
+	// maxBatchSize is the maximum number of operations in a batch
+	maxBatchSize = 1000
+)
+
+// batchOperationRoles are the roles required by the operations, the ones of the routes making the same changes
+var batchOperationRoles = map[string]string{
+	batchOperationCreate: model.RoleEditor,
+	batchOperationUpdate: model.RoleEditor,
+	batchOperationDelete: model.RoleAdmin,
+}
+
+// batchOperationDTO is a single create, update or delete operation of a batch
+type batchOperationDTO struct {
+	Op      string     `json:"op"`
+	ID      string     `json:"id"`
+	IfMatch string     `json:"ifMatch"`
+	Company companyDTO `json:"company"`
+}
+
+// batchResultDTO is the result of a single operation of a batch
+type batchResultDTO struct {
+	Index   int         `json:"index"`
+	Status  int         `json:"status"`
+	ETag    string      `json:"etag,omitempty"`
+	Company *companyDTO `json:"company,omitempty"`
+	Error   interface{} `json:"error,omitempty"`
+}
+
+// batch applies the array of operations of the request.
+// With 'atomic=true' all the operations are applied in one transaction, which is rolled back when any of them fails,
+// the operations that haven't been applied then get 424 Failed Dependency as status.
+// Otherwise each operation is applied in its own transaction.
+// Every operation requires the role of its own route, the operations the caller doesn't have the role for get 403.
+func (controller *companyController) batch(w http.ResponseWriter, r *http.Request) {
+	atomic := false
+	switch r.FormValue("atomic") {
+	case "", "false":
+	case "true":
+		atomic = true
+	default:
+		respondError(w, apiError.NewInvalidFieldsError(map[string]string{"atomic": apiError.ErrorCodeInvalidValue}))
+		return
+	}
+
+	var operations []batchOperationDTO
+	if err := unmarshalJSON(r, &operations); err != nil {
+		controller.app.Logger.Err(err).Msg("unable to marshal request body")
+		respondError(w, err)
+		return
+	}
+	if len(operations) > maxBatchSize {
+		respondError(w, apiError.NewInvalidRequestPayloadError(apiError.ErrorCodeInvalidValue))
+		return
+	}
+
+	identity, _ := auth.IdentityFrom(r.Context())
+	var results []batchResultDTO
+	if atomic {
+		results = controller.applyAtomicBatch(identity, operations)
+	} else {
+		results = controller.applyBatch(identity, operations)
+	}
+
+	status := http.StatusOK
+	for _, result := range results {
+		if result.Status >= http.StatusBadRequest {
+			status = http.StatusMultiStatus
+		}
+	}
+	respondJSON(w, status, results)
+	return
+}
+
+// applyAtomicBatch applies all the operations in one unit of work
+func (controller *companyController) applyAtomicBatch(identity *auth.Identity, operations []batchOperationDTO) []batchResultDTO {
+	uow := repository.NewUnitOfWork(controller.app.DB, false)
+	defer uow.Complete()
+
+	results := make([]batchResultDTO, len(operations))
+	failed := false
+	for index, operation := range operations {
+		if failed {
+			results[index] = batchResultDTO{Index: index, Status: http.StatusFailedDependency}
+			continue
+		}
+		results[index] = controller.applyBatchOperation(uow, identity, index, operation)
+		failed = results[index].Status >= http.StatusBadRequest
+	}
+
+	if !failed {
+		uow.Commit()
+		return results
+	}
+
+	// the operations that succeeded have been rolled back
+	for index := range results {
+		if results[index].Status < http.StatusBadRequest {
+			results[index] = batchResultDTO{Index: index, Status: http.StatusFailedDependency}
+		}
+	}
+	return results
+}
+
+// applyBatch applies each operation in its own unit of work
+func (controller *companyController) applyBatch(identity *auth.Identity, operations []batchOperationDTO) []batchResultDTO {
+	results := make([]batchResultDTO, len(operations))
+	for index, operation := range operations {
+		uow := repository.NewUnitOfWork(controller.app.DB, false)
+		results[index] = controller.applyBatchOperation(uow, identity, index, operation)
+		if results[index].Status < http.StatusBadRequest {
+			uow.Commit()
+		}
+		uow.Complete()
+	}
+	return results
+}
+
+// applyBatchOperation applies the operation within the unit of work, when the caller has the role it requires, and
+// returns its result
+func (controller *companyController) applyBatchOperation(uow *repository.UnitOfWork, identity *auth.Identity, index int, operation batchOperationDTO) batchResultDTO {
+	if role, ok := batchOperationRoles[operation.Op]; ok && (identity == nil || !identity.HasRole(role)) {
+		controller.app.Logger.Info().Str("op", operation.Op).Str("role", role).Msg("rejecting batch operation without the required role")
+		return batchResultDTO{Index: index, Status: http.StatusForbidden, Error: map[string]string{"error": apiError.ErrorCodeForbidden, "role": role}}
+	}
+
+	switch operation.Op {
+	case batchOperationCreate:
+		company, err := controller.createCompany(uow, operation.Company)
+		if err != nil {
+			return batchErrorResult(index, err)
+		}
+		companyDTO := toCompanyDTO(company)
+		return batchResultDTO{Index: index, Status: http.StatusCreated, ETag: entityTag(company.Version), Company: &companyDTO}
+	case batchOperationUpdate:
+		company, err := controller.updateCompany(uow, operation.ID, operation.IfMatch, operation.Company)
+		if err != nil {
+			return batchErrorResult(index, err)
+		}
+		companyDTO := toCompanyDTO(company)
+		return batchResultDTO{Index: index, Status: http.StatusOK, ETag: entityTag(company.Version), Company: &companyDTO}
+	case batchOperationDelete:
+		if err := controller.deleteCompany(uow, operation.ID, operation.IfMatch); err != nil {
+			return batchErrorResult(index, err)
+		}
+		return batchResultDTO{Index: index, Status: http.StatusOK}
+	default:
+		return batchErrorResult(index, apiError.NewInvalidFieldsError(map[string]string{"op": apiError.ErrorCodeInvalidValue}))
+	}
+}
+
+// batchErrorResult creates the result of a failed operation
+func batchErrorResult(index int, err error) batchResultDTO {
+	status, payload := errorResponse(err)
+	return batchResultDTO{Index: index, Status: status, Error: payload}
+}
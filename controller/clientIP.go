@@ -0,0 +1,97 @@
+package controller
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"xm/policy"
+)
+
+// trustedProxies are the networks of the reverse proxies trusted to forward the ip of the caller
+type trustedProxies []*net.IPNet
+
+// parseTrustedProxies parses the CIDRs of the trusted proxies, a single ip is a network of its own
+func parseTrustedProxies(cidrs []string) (trustedProxies, error) {
+	networks, err := policy.ParseNetworks(cidrs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid trusted proxies: %w", err)
+	}
+	return networks, nil
+}
+
+// contains reports whether ip belongs to a trusted proxy
+func (proxies trustedProxies) contains(ip net.IP) bool {
+	for _, network := range proxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// clientIP gets ip address of the caller from the request. Starting with the peer address, the hops of the Forwarded
+// header (or X-Forwarded-For when there isn't one) are walked from the right as long as the address is of a trusted
+// proxy, so the first untrusted hop is the caller. Hops that can't be parsed stop the walk at the last trusted proxy.
+func (proxies trustedProxies) clientIP(r *http.Request) string {
+	ip := parseHop(r.RemoteAddr)
+	if ip == nil {
+		return r.RemoteAddr
+	}
+
+	hops := forwardedHops(r.Header)
+	for index := len(hops) - 1; index >= 0 && proxies.contains(ip); index-- {
+		hop := parseHop(hops[index])
+		if hop == nil {
+			break
+		}
+		ip = hop
+	}
+	return ip.String()
+}
+
+// forwardedHops returns the addresses of the RFC 7239 Forwarded header, or of X-Forwarded-For when there isn't one,
+// in the order they have been appended
+func forwardedHops(header http.Header) []string {
+	var hops []string
+	if forwarded := header.Values("Forwarded"); len(forwarded) > 0 {
+		for _, element := range strings.Split(strings.Join(forwarded, ","), ",") {
+			// an element without a "for" parameter is a hop of unknown address
+			hop := ""
+			for _, pair := range strings.Split(element, ";") {
+				name, value, found := cut(strings.TrimSpace(pair), "=")
+				if found && strings.EqualFold(name, "for") {
+					hop = value
+				}
+			}
+			hops = append(hops, hop)
+		}
+		return hops
+	}
+
+	for _, forwardedFor := range header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(forwardedFor, ",")...)
+	}
+	return hops
+}
+
+// parseHop parses the ip address of a hop, which may be quoted and have a port. IPv6 addresses with a port are
+// enclosed in brackets. Obfuscated identifiers and "unknown" aren't addresses.
+func parseHop(hop string) net.IP {
+	hop = strings.Trim(strings.TrimSpace(hop), `"`)
+	if ip := net.ParseIP(hop); ip != nil {
+		return ip
+	}
+	if host, _, err := net.SplitHostPort(hop); err == nil {
+		return net.ParseIP(host)
+	}
+	return net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(hop, "["), "]"))
+}
+
+// cut slices s around the first instance of sep, strings.Cut isn't available to go 1.17
+func cut(s, sep string) (before, after string, found bool) {
+	if index := strings.Index(s, sep); index >= 0 {
+		return s[:index], s[index+len(sep):], true
+	}
+	return s, "", false
+}
@@ -0,0 +1,55 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	proxies, err := parseTrustedProxies([]string{"10.0.0.0/8", "2001:db8::/32", "192.0.2.1"})
+	if err != nil {
+		t.Fatalf("unable to parse trusted proxies: %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     http.Header
+		expectedIP string
+	}{
+		{"+ve:ShouldStripPortFromRemoteAddr", "203.0.113.7:54321", nil, "203.0.113.7"},
+		{"+ve:ShouldStripPortFromIPv6RemoteAddr", "[2001:db9::1]:54321", nil, "2001:db9::1"},
+		{"-ve:ShouldIgnoreHeadersOfUntrustedPeer", "203.0.113.7:54321", http.Header{"X-Forwarded-For": {"5.5.5.5"}, "X-Real-Ip": {"5.5.5.5"}}, "203.0.113.7"},
+		{"-ve:ShouldIgnoreRealIPOfTrustedProxy", "10.0.0.1:443", http.Header{"X-Real-Ip": {"5.5.5.5"}}, "10.0.0.1"},
+		{"+ve:ShouldUseForwardedForOfTrustedProxy", "10.0.0.1:443", http.Header{"X-Forwarded-For": {"5.5.5.5"}}, "5.5.5.5"},
+		{"+ve:ShouldStopAtFirstUntrustedHop", "10.0.0.1:443", http.Header{"X-Forwarded-For": {"6.6.6.6, 5.5.5.5, 10.0.0.2"}}, "5.5.5.5"},
+		{"+ve:ShouldJoinForwardedForHeaders", "10.0.0.1:443", http.Header{"X-Forwarded-For": {"6.6.6.6", "5.5.5.5, 192.0.2.1"}}, "5.5.5.5"},
+		{"+ve:ShouldUseLeftmostHopWhenAllAreTrusted", "10.0.0.1:443", http.Header{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}}, "10.0.0.3"},
+		{"-ve:ShouldStopAtInvalidHop", "10.0.0.1:443", http.Header{"X-Forwarded-For": {"5.5.5.5, garbage, 10.0.0.2"}}, "10.0.0.2"},
+		{"+ve:ShouldPreferForwardedHeader", "10.0.0.1:443", http.Header{"Forwarded": {"for=5.5.5.5;proto=https"}, "X-Forwarded-For": {"6.6.6.6"}}, "5.5.5.5"},
+		{"+ve:ShouldParseQuotedIPv6ForwardedHop", "10.0.0.1:443", http.Header{"Forwarded": {`for="[2001:db9::17]:4711", For=10.0.0.2;by=10.0.0.1`}}, "2001:db9::17"},
+		{"+ve:ShouldWalkTrustedIPv6Hops", "[2001:db8::1]:443", http.Header{"Forwarded": {"for=5.5.5.5", `for="[2001:db8::2]"`}}, "5.5.5.5"},
+		{"-ve:ShouldStopAtObfuscatedForwardedHop", "10.0.0.1:443", http.Header{"Forwarded": {"for=5.5.5.5, for=_hidden, for=10.0.0.2"}}, "10.0.0.2"},
+		{"-ve:ShouldStopAtForwardedHopWithoutFor", "10.0.0.1:443", http.Header{"Forwarded": {"for=5.5.5.5, proto=https"}}, "10.0.0.1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/api/companies", nil)
+			request.RemoteAddr = tt.remoteAddr
+			for name, values := range tt.header {
+				request.Header[name] = values
+			}
+
+			if ip := proxies.clientIP(request); tt.expectedIP != ip {
+				t.Errorf("expected ip %v, got %v", tt.expectedIP, ip)
+			}
+		})
+	}
+
+	for _, invalid := range []string{"10.0.0.0/33", "proxy.local"} {
+		if _, err := parseTrustedProxies([]string{invalid}); err == nil {
+			t.Errorf("expected error for invalid trusted proxy %q", invalid)
+		}
+	}
+}
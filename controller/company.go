@@ -1,15 +1,24 @@
 package controller
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/gorilla/mux"
 	uuid "github.com/satori/go.uuid"
+	"gorm.io/gorm/schema"
 	"io/ioutil"
 	"net/http"
+	"reflect"
+	"strings"
+	"time"
 	"xm/app"
+	"xm/auth"
 	"xm/client"
 	apiError "xm/error"
 	"xm/model"
+	"xm/policy"
+	"xm/ratelimit"
 	"xm/repository"
 )
 
@@ -17,25 +26,71 @@ type companyController struct {
 	app              *app.App
 	ipLocationClient client.IPLocationClient
 	repository       repository.Repository
+	columns          map[string]*schema.Field
+	codeScope        []string
+	originPolicies   map[string]originPolicy
+	trustedProxies   trustedProxies
+	accessPolicy     policy.Engine
+	authentication   authentication
+	rateLimits       rateLimits
 }
 
-func NewCompanyController(app *app.App, ipLocationClient client.IPLocationClient, repository repository.Repository) *companyController {
+// NewCompanyController returns the controller of the company routes, the buckets of the rate limits are kept in the
+// store, in memory when it's nil
+func NewCompanyController(app *app.App, ipLocationClient client.IPLocationClient, companyRepository repository.Repository, authenticator auth.Authenticator, rateLimitStore ratelimit.Store) *companyController {
+	columns, err := repository.Columns(&model.Company{})
+	if err != nil {
+		app.Logger.Fatal().Err(err).Msg("unable to parse company schema, exiting the application!")
+	}
+
+	originPolicies, err := parseOriginPolicies(app.Config().OriginCheckFailOpen)
+	if err != nil {
+		app.Logger.Fatal().Err(err).Msg("unable to parse origin check policies, exiting the application!")
+	}
+	trustedProxies, err := parseTrustedProxies(app.Config().TrustedProxies)
+	if err != nil {
+		app.Logger.Fatal().Err(err).Msg("unable to parse trusted proxies, exiting the application!")
+	}
+	accessPolicy, err := newAccessPolicy(app.Config().AccessPolicyFile, app.Config().AccessPolicyReloadInterval, app.Config().OriginCountry, app.Logger)
+	if err != nil {
+		app.Logger.Fatal().Err(err).Msg("unable to load access policy, exiting the application!")
+	}
+	rateLimits, err := newRateLimits(app.Config(), rateLimitStore)
+	if err != nil {
+		app.Logger.Fatal().Err(err).Msg("unable to parse rate limits, exiting the application!")
+	}
+
 	return &companyController{
 		app:              app,
 		ipLocationClient: ipLocationClient,
-		repository:       repository,
+		repository:       companyRepository,
+		columns:          columns,
+		codeScope:        model.CompanyCodeScope(app.Config().CompanyCodeUniquePerCountry),
+		originPolicies:   originPolicies,
+		trustedProxies:   trustedProxies,
+		accessPolicy:     accessPolicy,
+		authentication:   authentication{authenticator: authenticator, required: app.Config().AuthenticationRequired},
+		rateLimits:       rateLimits,
 	}
 }
 
 // RegisterRoutes implements interface RouteSpecifier
 func (controller *companyController) RegisterRoutes(muxRouter *mux.Router) {
+	// registered on the parent router as the sub router only matches paths continuing with '/'
+	// every operation of the batch requires the role of its own route as well
+	muxRouter.HandleFunc("/api/companies:batch", controller.protect(routeBatch, model.RoleEditor, controller.batch)).Methods(http.MethodPost)
+	muxRouter.HandleFunc("/api/companies:import", controller.protect(routeImport, model.RoleEditor, controller.importCompanies)).Methods(http.MethodPost)
+
 	router := muxRouter.PathPrefix("/api/companies").Subrouter()
 
-	router.HandleFunc("", protect(controller.ipLocationClient, controller.add)).Methods(http.MethodPost)
-	router.HandleFunc("", controller.getAll).Methods(http.MethodGet)
-	router.HandleFunc("/{id}", controller.get).Methods(http.MethodGet)
-	router.HandleFunc("/{id}", controller.update).Methods(http.MethodPut)
-	router.HandleFunc("/{id}", protect(controller.ipLocationClient, controller.delete)).Methods(http.MethodDelete)
+	router.HandleFunc("", controller.protect(routeCreate, model.RoleEditor, controller.add)).Methods(http.MethodPost)
+	router.HandleFunc("", controller.protect(routeList, model.RoleViewer, controller.getAll)).Methods(http.MethodGet)
+	router.HandleFunc("/search", controller.protect(routeSearch, model.RoleViewer, controller.search)).Methods(http.MethodGet)
+	router.HandleFunc("/{id}", controller.protect(routeGet, model.RoleViewer, controller.get)).Methods(http.MethodGet)
+	router.HandleFunc("/{id}", controller.protect(routeUpdate, model.RoleEditor, controller.update)).Methods(http.MethodPut)
+	router.HandleFunc("/{id}", controller.protect(routePatch, model.RoleEditor, controller.patch)).Methods(http.MethodPatch)
+	router.HandleFunc("/{id}", controller.protect(routeDelete, model.RoleAdmin, controller.delete)).Methods(http.MethodDelete)
+	router.HandleFunc("/{id}/restore", controller.protect(routeRestore, model.RoleEditor, controller.restore)).Methods(http.MethodPost)
 }
 
 func (controller *companyController) add(w http.ResponseWriter, r *http.Request) {
@@ -49,21 +104,15 @@ func (controller *companyController) add(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	company, err := model.NewCompany(reqDTO.Name, reqDTO.Code, reqDTO.Country, reqDTO.Website, reqDTO.Phone)
+	company, err := controller.createCompany(uow, reqDTO)
 	if err != nil {
-		controller.app.Logger.Err(err).Msg("unable add company")
-		respondError(w, err)
-		return
-	}
-
-	if err := controller.repository.Add(uow, company); err != nil {
-		controller.app.Logger.Err(err).Msg("unable add company to db")
 		respondError(w, err)
 		return
 	}
 
 	uow.Commit()
 
+	setEntityTag(w, company.Version)
 	respondJSON(w, http.StatusCreated, toCompanyDTO(company))
 	return
 }
@@ -72,38 +121,62 @@ func (controller *companyController) getAll(w http.ResponseWriter, r *http.Reque
 	uow := repository.NewUnitOfWork(controller.app.DB, true)
 	defer uow.Complete()
 
-	var queryProcessors []repository.QueryProcessor
-
-	if name := r.FormValue("name"); len(name) > 0 {
-		queryProcessors = append(queryProcessors, repository.Filter("name = ?", name))
+	queryProcessors, err := parseFilters(r.URL.Query(), controller.columns, append(pageParameters, "deleted")...)
+	if err != nil {
+		respondError(w, err)
+		return
 	}
 
-	if code := r.FormValue("code"); len(code) > 0 {
-		queryProcessors = append(queryProcessors, repository.Filter("code = ?", code))
+	switch r.FormValue("deleted") {
+	case "":
+	case "include":
+		queryProcessors = append(queryProcessors, repository.Unscoped())
+	case "only":
+		queryProcessors = append(queryProcessors, repository.OnlyDeleted())
+	default:
+		respondError(w, apiError.NewInvalidFieldsError(map[string]string{"deleted": apiError.ErrorCodeInvalidValue}))
+		return
 	}
 
-	if country := r.FormValue("country"); len(country) > 0 {
-		queryProcessors = append(queryProcessors, repository.Filter("country = ?", country))
+	if format := exportFormat(r.Header.Get("Accept")); len(format) > 0 {
+		controller.export(w, r, format, queryProcessors)
+		return
 	}
 
-	if website := r.FormValue("website"); len(website) > 0 {
-		queryProcessors = append(queryProcessors, repository.Filter("website = ?", website))
+	page, err := parsePageRequest(r, controller.columns)
+	if err != nil {
+		respondError(w, err)
+		return
 	}
 
-	if phone := r.FormValue("phone"); len(phone) > 0 {
-		queryProcessors = append(queryProcessors, repository.Filter("phone = ?", phone))
+	var totalCount int64
+	if err := controller.repository.Count(uow, &model.Company{}, &totalCount, queryProcessors); err != nil {
+		controller.app.Logger.Err(err).Msg("unable to count companies in db")
+		respondError(w, err)
+		return
 	}
 
 	var companies []model.Company
-	if err := controller.repository.GetAll(uow, &companies, queryProcessors); err != nil {
+	if err := controller.repository.GetAll(uow, &companies, append(queryProcessors, page.queryProcessors()...)); err != nil {
 		controller.app.Logger.Err(err).Msg("unable to get companies from db")
 		respondError(w, err)
 		return
 	}
 
-	responseDTO := make([]companyDTO, len(companies))
-	for index, company := range companies {
-		responseDTO[index] = toCompanyDTO(&company)
+	responseDTO := companyPageDTO{Items: []companyDTO{}, TotalCount: totalCount}
+	if len(companies) > page.limit {
+		companies = companies[:page.limit]
+		nextCursor, err := encodeCursor(page.sort, page.sortFields, controller.columns, &companies[page.limit-1])
+		if err != nil {
+			controller.app.Logger.Err(err).Msg("unable to create cursor")
+			respondError(w, err)
+			return
+		}
+		responseDTO.NextCursor = &nextCursor
+	}
+
+	for _, company := range companies {
+		responseDTO.Items = append(responseDTO.Items, toCompanyDTO(&company))
 	}
 
 	respondJSON(w, http.StatusOK, responseDTO)
@@ -128,6 +201,12 @@ func (controller *companyController) get(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	setEntityTag(w, company.Version)
+	if !ifNoneMatch(r, company.Version) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	respondJSON(w, http.StatusOK, toCompanyDTO(company))
 	return
 }
@@ -139,6 +218,33 @@ func (controller *companyController) update(w http.ResponseWriter, r *http.Reque
 	uow := repository.NewUnitOfWork(controller.app.DB, false)
 	defer uow.Complete()
 
+	reqDTO := companyDTO{}
+	if err := unmarshalJSON(r, &reqDTO); err != nil {
+		controller.app.Logger.Err(err).Msg("unable to marshal request body")
+		respondError(w, err)
+		return
+	}
+
+	company, err := controller.updateCompany(uow, id, r.Header.Get("If-Match"), reqDTO)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	uow.Commit()
+
+	setEntityTag(w, company.Version)
+	respondJSON(w, http.StatusOK, toCompanyDTO(company))
+	return
+}
+
+func (controller *companyController) patch(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id := params["id"]
+
+	uow := repository.NewUnitOfWork(controller.app.DB, false)
+	defer uow.Complete()
+
 	company := &model.Company{}
 	if err := controller.repository.Get(uow, company, uuid.FromStringOrNil(id)); err != nil {
 		if err.IsRecordNotFoundError() {
@@ -150,28 +256,51 @@ func (controller *companyController) update(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	reqDTO := companyDTO{}
-	if err := unmarshalJSON(r, &reqDTO); err != nil {
-		controller.app.Logger.Err(err).Msg("unable to marshal request body")
+	if !ifMatch(r, company.Version) {
+		respondError(w, errPreconditionFailed)
+		return
+	}
+
+	patch, err := readBody(r)
+	if err != nil {
+		controller.app.Logger.Err(err).Msg("unable to read request body")
 		respondError(w, err)
 		return
 	}
 
-	err := company.Update(reqDTO.Name, reqDTO.Code, reqDTO.Country, reqDTO.Website, reqDTO.Phone)
+	reqDTO, err := applyPatch(r.Header.Get("Content-Type"), toCompanyDTO(company), patch)
 	if err != nil {
-		controller.app.Logger.Err(err).Msg("unable update company")
+		controller.app.Logger.Err(err).Msg("unable to apply patch")
 		respondError(w, err)
 		return
 	}
+	if reqDTO.ID != company.ID.String() {
+		respondError(w, apiError.NewInvalidFieldsError(map[string]string{"id": apiError.ErrorCodeInvalidValue}))
+		return
+	}
 
-	if err := controller.repository.Update(uow, company); err != nil {
-		controller.app.Logger.Err(err).Msg("unable update company to db")
+	original := *company
+	if err := company.Update(reqDTO.Name, reqDTO.Code, reqDTO.Country, reqDTO.Website, reqDTO.Phone); err != nil {
+		controller.app.Logger.Err(err).Msg("unable patch company")
 		respondError(w, err)
 		return
 	}
 
+	if columns := changedCompanyColumns(&original, company); len(columns) > 0 {
+		if err := controller.checkCodeConflict(uow, company); err != nil {
+			respondError(w, err)
+			return
+		}
+		if err := controller.repository.UpdateColumns(uow, company, columns...); err != nil {
+			controller.app.Logger.Err(err).Msg("unable patch company in db")
+			respondError(w, controller.codeConflictError(uow, company, err))
+			return
+		}
+	}
+
 	uow.Commit()
 
+	setEntityTag(w, company.Version)
 	respondJSON(w, http.StatusOK, toCompanyDTO(company))
 	return
 }
@@ -183,38 +312,217 @@ func (controller *companyController) delete(w http.ResponseWriter, r *http.Reque
 	uow := repository.NewUnitOfWork(controller.app.DB, false)
 	defer uow.Complete()
 
-	company := &model.Company{}
-	if err := controller.repository.Get(uow, company, uuid.FromStringOrNil(id)); err != nil {
-		if err.IsRecordNotFoundError() {
-			respondJSON(w, http.StatusNotFound, nil)
-			return
-		}
-		controller.app.Logger.Err(err).Msg("unable get company from db")
+	if err := controller.deleteCompany(uow, id, r.Header.Get("If-Match")); err != nil {
 		respondError(w, err)
 		return
 	}
 
-	if err := controller.repository.Delete(uow, company); err != nil {
-		controller.app.Logger.Err(err).Msg("unable delete company from db")
+	uow.Commit()
+
+	respondJSON(w, http.StatusOK, nil)
+	return
+}
+
+func (controller *companyController) restore(w http.ResponseWriter, r *http.Request) {
+	params := mux.Vars(r)
+	id := params["id"]
+
+	uow := repository.NewUnitOfWork(controller.app.DB, false)
+	defer uow.Complete()
+
+	var companies []model.Company
+	queryProcessors := []repository.QueryProcessor{repository.OnlyDeleted(), repository.FilterBy("id", repository.Equal, uuid.FromStringOrNil(id))}
+	if err := controller.repository.GetAll(uow, &companies, queryProcessors); err != nil {
+		controller.app.Logger.Err(err).Msg("unable get deleted company from db")
+		respondError(w, err)
+		return
+	}
+	if len(companies) == 0 {
+		respondJSON(w, http.StatusNotFound, nil)
+		return
+	}
+	company := &companies[0]
+
+	if !ifMatch(r, company.Version) {
+		respondError(w, errPreconditionFailed)
+		return
+	}
+
+	if err := controller.checkCodeConflict(uow, company); err != nil {
 		respondError(w, err)
 		return
 	}
 
+	if err := controller.repository.Restore(uow, company); err != nil {
+		controller.app.Logger.Err(err).Msg("unable restore company in db")
+		respondError(w, controller.codeConflictError(uow, company, err))
+		return
+	}
+
 	uow.Commit()
 
-	respondJSON(w, http.StatusOK, nil)
+	setEntityTag(w, company.Version)
+	respondJSON(w, http.StatusOK, toCompanyDTO(company))
 	return
 }
 
+// createCompany validates and adds a new company
+func (controller *companyController) createCompany(uow *repository.UnitOfWork, reqDTO companyDTO) (*model.Company, error) {
+	company, err := model.NewCompany(reqDTO.Name, reqDTO.Code, reqDTO.Country, reqDTO.Website, reqDTO.Phone)
+	if err != nil {
+		controller.app.Logger.Err(err).Msg("unable add company")
+		return nil, err
+	}
+
+	if err := controller.checkCodeConflict(uow, company); err != nil {
+		return nil, err
+	}
+
+	if err := controller.repository.Add(uow, company); err != nil {
+		controller.app.Logger.Err(err).Msg("unable add company to db")
+		return nil, controller.codeConflictError(uow, company, err)
+	}
+	return company, nil
+}
+
+// updateCompany validates and replaces all the fields of an existing company, 'ifMatch' is the If-Match precondition
+func (controller *companyController) updateCompany(uow *repository.UnitOfWork, id string, ifMatch string, reqDTO companyDTO) (*model.Company, error) {
+	company := &model.Company{}
+	if err := controller.repository.Get(uow, company, uuid.FromStringOrNil(id)); err != nil {
+		if !err.IsRecordNotFoundError() {
+			controller.app.Logger.Err(err).Msg("unable get company from db")
+		}
+		return nil, err
+	}
+
+	if !matchIfMatch(ifMatch, company.Version) {
+		return nil, errPreconditionFailed
+	}
+
+	err := company.Update(reqDTO.Name, reqDTO.Code, reqDTO.Country, reqDTO.Website, reqDTO.Phone)
+	if err != nil {
+		controller.app.Logger.Err(err).Msg("unable update company")
+		return nil, err
+	}
+
+	if err := controller.checkCodeConflict(uow, company); err != nil {
+		return nil, err
+	}
+
+	if err := controller.repository.Update(uow, company); err != nil {
+		controller.app.Logger.Err(err).Msg("unable update company to db")
+		return nil, controller.codeConflictError(uow, company, err)
+	}
+	return company, nil
+}
+
+// deleteCompany removes an existing company, 'ifMatch' is the If-Match precondition
+func (controller *companyController) deleteCompany(uow *repository.UnitOfWork, id string, ifMatch string) error {
+	company := &model.Company{}
+	if err := controller.repository.Get(uow, company, uuid.FromStringOrNil(id)); err != nil {
+		if !err.IsRecordNotFoundError() {
+			controller.app.Logger.Err(err).Msg("unable get company from db")
+		}
+		return err
+	}
+
+	if !matchIfMatch(ifMatch, company.Version) {
+		return errPreconditionFailed
+	}
+
+	if err := controller.repository.Delete(uow, company); err != nil {
+		controller.app.Logger.Err(err).Msg("unable delete company from db")
+		return err
+	}
+	return nil
+}
+
+// checkCodeConflict fails with a conflict error pointing at the existing company when another company, that hasn't
+// been deleted, already uses the code of the company within the code scope
+func (controller *companyController) checkCodeConflict(uow *repository.UnitOfWork, company *model.Company) error {
+	existingCompany, err := controller.findCompanyWithCode(uow, company)
+	if err != nil {
+		return err
+	}
+	if existingCompany != nil {
+		return apiError.NewConflictError(map[string]string{"code": apiError.ErrorCodeAlreadyExists}, companyLocation(existingCompany))
+	}
+	return nil
+}
+
+// codeConflictError turns the unique constraint violation of a write, when another company has taken the code since
+// checkCodeConflict, into a conflict error pointing at that company, other errors are returned as they are
+func (controller *companyController) codeConflictError(uow *repository.UnitOfWork, company *model.Company, err apiError.DatabaseError) error {
+	if !err.IsUniqueConstraintError() {
+		return err
+	}
+	existingCompany, findErr := controller.findCompanyWithCode(uow, company)
+	if findErr != nil {
+		// postgres aborts the transaction of the failed write, the company is looked up outside of it
+		readUow := repository.NewUnitOfWork(controller.app.DB, true)
+		defer readUow.Complete()
+		existingCompany, findErr = controller.findCompanyWithCode(readUow, company)
+	}
+	location := ""
+	if findErr == nil && existingCompany != nil {
+		location = companyLocation(existingCompany)
+	}
+	return apiError.NewConflictError(map[string]string{"code": apiError.ErrorCodeAlreadyExists}, location)
+}
+
+// findCompanyWithCode returns another company, that hasn't been deleted, with the code of company within the code
+// scope, nil when there is none
+func (controller *companyController) findCompanyWithCode(uow *repository.UnitOfWork, company *model.Company) (*model.Company, error) {
+	queryProcessors := []repository.QueryProcessor{repository.FilterBy("id", repository.NotEqual, company.ID), repository.Limit(1)}
+	companyValue := reflect.ValueOf(company).Elem()
+	for _, column := range controller.codeScope {
+		value, _ := controller.columns[column].ValueOf(context.Background(), companyValue)
+		queryProcessors = append(queryProcessors, repository.FilterBy(column, repository.Equal, value))
+	}
+
+	var companies []model.Company
+	if err := controller.repository.GetAll(uow, &companies, queryProcessors); err != nil {
+		controller.app.Logger.Err(err).Msg("unable to get companies from db")
+		return nil, err
+	}
+	if len(companies) == 0 {
+		return nil, nil
+	}
+	return &companies[0], nil
+}
+
+// codeScopeKey returns the values of the code scope columns of the company, which are unique together
+func (controller *companyController) codeScopeKey(company *model.Company) string {
+	companyValue := reflect.ValueOf(company).Elem()
+	values := make([]string, len(controller.codeScope))
+	for index, column := range controller.codeScope {
+		value, _ := controller.columns[column].ValueOf(context.Background(), companyValue)
+		values[index] = fmt.Sprint(value)
+	}
+	return strings.Join(values, "\x00")
+}
+
 ////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
 type companyDTO struct {
-	ID      string `json:"id"`
-	Name    string `json:"name"`
-	Code    string `json:"code"`
-	Country string `json:"country"`
-	Website string `json:"website"`
-	Phone   string `json:"phone"`
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Code      string     `json:"code"`
+	Country   string     `json:"country"`
+	Website   string     `json:"website"`
+	Phone     string     `json:"phone"`
+	DeletedOn *time.Time `json:"deletedOn,omitempty"`
+}
+
+type companyPageDTO struct {
+	Items      []companyDTO `json:"items"`
+	NextCursor *string      `json:"nextCursor"`
+	TotalCount int64        `json:"totalCount"`
+}
+
+// companyLocation returns the path of the company resource
+func companyLocation(company *model.Company) string {
+	return "/api/companies/" + company.ID.String()
 }
 
 func toCompanyDTO(company *model.Company) companyDTO {
@@ -226,22 +534,55 @@ func toCompanyDTO(company *model.Company) companyDTO {
 		Website: company.Website,
 		Phone:   company.Phone,
 	}
+	if company.DeletedAt.Valid {
+		dto.DeletedOn = &company.DeletedAt.Time
+	}
 	return dto
 }
 
-// unmarshalJSON checks for empty body and then parses JSON into the target
-func unmarshalJSON(r *http.Request, target interface{}) error {
+// changedCompanyColumns returns the columns of the fields that differ between original and updated company
+func changedCompanyColumns(original, updated *model.Company) []string {
+	var columns []string
+	if original.Name != updated.Name {
+		columns = append(columns, "name")
+	}
+	if original.Code != updated.Code {
+		columns = append(columns, "code")
+	}
+	if original.Country != updated.Country {
+		columns = append(columns, "country")
+	}
+	if original.Website != updated.Website {
+		columns = append(columns, "website")
+	}
+	if original.Phone != updated.Phone {
+		columns = append(columns, "phone")
+	}
+	return columns
+}
+
+// readBody checks for empty body and then returns it
+func readBody(r *http.Request) ([]byte, error) {
 	if r.Body == nil {
-		return apiError.NewInvalidRequestPayloadError(apiError.ErrorCodeEmptyRequestBody)
+		return nil, apiError.NewInvalidRequestPayloadError(apiError.ErrorCodeEmptyRequestBody)
 	}
 
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		return apiError.NewDataReadWriteError(err)
+		return nil, apiError.NewDataReadWriteError(err)
 	}
 
 	if len(body) == 0 {
-		return apiError.NewInvalidRequestPayloadError(apiError.ErrorCodeEmptyRequestBody)
+		return nil, apiError.NewInvalidRequestPayloadError(apiError.ErrorCodeEmptyRequestBody)
+	}
+	return body, nil
+}
+
+// unmarshalJSON checks for empty body and then parses JSON into the target
+func unmarshalJSON(r *http.Request, target interface{}) error {
+	body, err := readBody(r)
+	if err != nil {
+		return err
 	}
 
 	err = json.Unmarshal(body, target)
@@ -266,10 +607,38 @@ func respondJSON(w http.ResponseWriter, status int, payload interface{}) {
 
 // respondError returns a validation error else
 func respondError(w http.ResponseWriter, err error) {
+	status, payload := errorResponse(err)
+	respondJSON(w, status, payload)
+}
+
+// errorResponse returns the http status and the payload of the response for the error
+func errorResponse(err error) (int, interface{}) {
+	if err == errPreconditionFailed {
+		return http.StatusPreconditionFailed, map[string]string{"error": apiError.ErrorCodePreconditionFailed}
+	}
+
 	switch err.(type) {
 	case apiError.ValidationError:
-		respondJSON(w, http.StatusBadRequest, err)
+		return http.StatusBadRequest, err
+	case apiError.ConflictError:
+		return http.StatusConflict, err
+	case apiError.DatabaseError:
+		if err.(apiError.DatabaseError).IsRecordNotFoundError() {
+			return http.StatusNotFound, nil
+		}
+		if err.(apiError.DatabaseError).IsConcurrentModificationError() {
+			return http.StatusPreconditionFailed, map[string]string{"error": apiError.ErrorCodePreconditionFailed}
+		}
+		if err.(apiError.DatabaseError).IsUniqueConstraintError() {
+			return http.StatusConflict, apiError.NewConflictError(map[string]string{}, "")
+		}
+		if err.(apiError.DatabaseError).IsSearchUnavailableError() {
+			return http.StatusNotImplemented, map[string]string{"error": apiError.ErrorCodeSearchUnavailable}
+		}
+		return http.StatusInternalServerError, map[string]string{"error": apiError.ErrorCodeInternalError}
+	case unsupportedMediaTypeError:
+		return http.StatusUnsupportedMediaType, map[string]string{"error": apiError.ErrorCodeUnsupportedMediaType}
 	default:
-		respondJSON(w, http.StatusInternalServerError, map[string]string{"error": apiError.ErrorCodeInternalError})
+		return http.StatusInternalServerError, map[string]string{"error": apiError.ErrorCodeInternalError}
 	}
 }
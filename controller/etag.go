@@ -0,0 +1,66 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// errPreconditionFailed is returned when the If-Match precondition of the request doesn't hold
+var errPreconditionFailed = errors.New("precondition failed")
+
+// entityTag returns the strong entity tag of the specified entity version
+func entityTag(version uint) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// setEntityTag sets the ETag header of the response
+func setEntityTag(w http.ResponseWriter, version uint) {
+	w.Header().Set("ETag", entityTag(version))
+}
+
+// ifMatch reports whether the If-Match precondition of the request holds for the entity version,
+// which is always the case when the request doesn't specify one
+func ifMatch(r *http.Request, version uint) bool {
+	return matchIfMatch(r.Header.Get("If-Match"), version)
+}
+
+// matchIfMatch reports whether the If-Match header value holds for the entity version, an empty value always holds
+func matchIfMatch(header string, version uint) bool {
+	if len(header) == 0 {
+		return true
+	}
+	return matchEntityTag(header, entityTag(version), false)
+}
+
+// ifNoneMatch reports whether the If-None-Match precondition of the request holds for the entity version,
+// which is always the case when the request doesn't specify one
+func ifNoneMatch(r *http.Request, version uint) bool {
+	header := r.Header.Get("If-None-Match")
+	if len(header) == 0 {
+		return true
+	}
+	return !matchEntityTag(header, entityTag(version), true)
+}
+
+// matchEntityTag checks whether any of the comma separated entity tags in header matches the entity tag.
+// Weak entity tags only match with weak comparison (RFC 7232).
+func matchEntityTag(header string, tag string, weakComparison bool) bool {
+	for _, headerTag := range strings.Split(header, ",") {
+		headerTag = strings.TrimSpace(headerTag)
+		if headerTag == "*" {
+			return true
+		}
+		if strings.HasPrefix(headerTag, "W/") {
+			if !weakComparison {
+				continue
+			}
+			headerTag = headerTag[2:]
+		}
+		if headerTag == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,154 @@
+package controller
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"xm/model"
+	"xm/repository"
+)
+
+// exportFlushRows is the number of rows written before the response is flushed to the client
+const exportFlushRows = 1000
+
+// csvHeader are the columns of exported CSV files, in the same format as imported ones
+var csvHeader = []string{"id", "name", "code", "country", "website", "phone"}
+
+// exportFormat returns the export format negotiated with the Accept header, or an empty string when the client accepts
+// JSON or doesn't accept any of the export formats
+func exportFormat(accept string) string {
+	format, quality := "", 0.0
+	for _, mediaRange := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(mediaRange)
+		if err != nil {
+			continue
+		}
+		rangeQuality := 1.0
+		if q, ok := params["q"]; ok {
+			if rangeQuality, err = strconv.ParseFloat(q, 64); err != nil {
+				continue
+			}
+		}
+		if rangeQuality <= quality {
+			continue
+		}
+
+		switch mediaType {
+		case mediaTypeCSV:
+			format, quality = ImportFormatCSV, rangeQuality
+		case mediaTypeNDJSON:
+			format, quality = ImportFormatNDJSON, rangeQuality
+		case "application/json", "application/*", "*/*":
+			format, quality = "", rangeQuality
+		}
+	}
+	return format
+}
+
+// export streams all the companies that match the query processors in the specified format, ordered by the 'sort'
+// query parameter. Rows are written as they are read from the database and flushed in chunks.
+func (controller *companyController) export(w http.ResponseWriter, r *http.Request, format string, queryProcessors []repository.QueryProcessor) {
+	sort := r.FormValue("sort")
+	if len(sort) == 0 {
+		sort = defaultSort
+	}
+	sortFields, err := parseSort(sort, controller.columns)
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+	queryProcessors = append(queryProcessors, repository.Order(sortFields...))
+
+	uow := repository.NewUnitOfWork(controller.app.DB, true)
+	defer uow.Complete()
+
+	contentType, extension := mediaTypeCSV, "csv"
+	if format == ImportFormatNDJSON {
+		contentType, extension = mediaTypeNDJSON, "ndjson"
+	}
+
+	buffer := bufio.NewWriter(w)
+	csvWriter := csv.NewWriter(buffer)
+	writeCSV := func(record []string) error {
+		if err := csvWriter.Write(record); err != nil {
+			return err
+		}
+		csvWriter.Flush()
+		return csvWriter.Error()
+	}
+
+	var writeRow func(company *model.Company) error
+	if format == ImportFormatCSV {
+		writeRow = func(company *model.Company) error {
+			return writeCSV([]string{company.ID.String(), company.Name, company.Code, company.Country, company.Website, company.Phone})
+		}
+	} else {
+		encoder := json.NewEncoder(buffer)
+		writeRow = func(company *model.Company) error {
+			return encoder.Encode(toCompanyDTO(company))
+		}
+	}
+
+	// the headers are only sent with the first row, so that errors of the query can still be responded
+	rows := 0
+	writeHeader := func() error {
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="companies.%s"`, extension))
+		w.WriteHeader(http.StatusOK)
+		if format == ImportFormatCSV {
+			return writeCSV(csvHeader)
+		}
+		return nil
+	}
+
+	flusher, _ := w.(http.Flusher)
+	flush := func() error {
+		if err := buffer.Flush(); err != nil {
+			return err
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+		return nil
+	}
+
+	company := &model.Company{}
+	err = controller.repository.Each(uow, company, queryProcessors, func() error {
+		if rows == 0 {
+			if err := writeHeader(); err != nil {
+				return err
+			}
+		}
+		rows++
+		if err := writeRow(company); err != nil {
+			return err
+		}
+		if rows%exportFlushRows == 0 {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		controller.app.Logger.Err(err).Msg("unable to export companies")
+		if rows == 0 {
+			respondError(w, err)
+		}
+		return
+	}
+
+	if rows == 0 {
+		if err := writeHeader(); err != nil {
+			controller.app.Logger.Err(err).Msg("unable to export companies")
+			return
+		}
+	}
+	if err := flush(); err != nil {
+		controller.app.Logger.Err(err).Msg("unable to export companies")
+	}
+	return
+}
@@ -0,0 +1,134 @@
+package controller
+
+import (
+	"gorm.io/gorm/schema"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	apiError "xm/error"
+	"xm/repository"
+)
+
+// filterOperators maps the operators of the query string filter language to repository operators
+var filterOperators = map[string]repository.Operator{
+	"eq":   repository.Equal,
+	"ne":   repository.NotEqual,
+	"like": repository.Like,
+	"in":   repository.In,
+	"nin":  repository.NotIn,
+	"gt":   repository.GreaterThan,
+	"gte":  repository.GreaterThanOrEqual,
+	"lt":   repository.LessThan,
+	"lte":  repository.LessThanOrEqual,
+}
+
+// filterParameterRegex matches filter query parameters, e.g. 'name' or 'name[like]'
+var filterParameterRegex = regexp.MustCompile(`^([^\[\]]+)(?:\[([^\[\]]*)\])?$`)
+
+// parseFilters parses query parameters of the form 'column[operator]=value' into query processors.
+// 'column=value' is a shorthand for 'column[eq]=value' which is ignored when value is empty.
+// 'like' accepts '*' as wildcard and 'in'/'nin' accept comma separated values.
+// Parameters listed in 'reserved' are not filters and are skipped.
+func parseFilters(query url.Values, columns map[string]*schema.Field, reserved ...string) ([]repository.QueryProcessor, error) {
+	var queryProcessors []repository.QueryProcessor
+	failedFieldValidations := map[string]string{}
+
+	for parameter, values := range query {
+		if isReserved(parameter, reserved) {
+			continue
+		}
+
+		matches := filterParameterRegex.FindStringSubmatch(parameter)
+		if matches == nil {
+			failedFieldValidations[parameter] = apiError.ErrorCodeUnknownField
+			continue
+		}
+		field, ok := columns[matches[1]]
+		if !ok {
+			failedFieldValidations[parameter] = apiError.ErrorCodeUnknownField
+			continue
+		}
+		isShorthand := !strings.Contains(parameter, "[")
+		operatorName := matches[2]
+		if isShorthand {
+			operatorName = "eq"
+		}
+		operator, ok := filterOperators[operatorName]
+		if !ok {
+			failedFieldValidations[parameter] = apiError.ErrorCodeUnknownOperator
+			continue
+		}
+
+		for _, value := range values {
+			if isShorthand && len(value) == 0 {
+				continue
+			}
+			filterValue, err := parseFilterValue(field, operator, value)
+			if err != nil {
+				failedFieldValidations[parameter] = apiError.ErrorCodeInvalidValue
+				break
+			}
+			queryProcessors = append(queryProcessors, repository.FilterBy(field.DBName, operator, filterValue))
+		}
+	}
+
+	if len(failedFieldValidations) > 0 {
+		return nil, apiError.NewInvalidFieldsError(failedFieldValidations)
+	}
+	return queryProcessors, nil
+}
+
+// parseFilterValue converts the query string value into the type expected by the operator and the column
+func parseFilterValue(field *schema.Field, operator repository.Operator, value string) (interface{}, error) {
+	switch operator {
+	case repository.Like:
+		if field.DataType != schema.String {
+			return nil, apiError.NewInvalidFieldsError(map[string]string{field.DBName: apiError.ErrorCodeInvalidValue})
+		}
+		return strings.ReplaceAll(repository.EscapeLike(value), "*", "%"), nil
+	case repository.In, repository.NotIn:
+		var values []interface{}
+		for _, item := range strings.Split(value, ",") {
+			itemValue, err := parseColumnValue(field, item)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, itemValue)
+		}
+		return values, nil
+	default:
+		return parseColumnValue(field, value)
+	}
+}
+
+// parseColumnValue converts the query string value into the type of the column
+func parseColumnValue(field *schema.Field, value string) (interface{}, error) {
+	switch field.DataType {
+	case schema.Time:
+		if date, err := time.Parse("2006-01-02", value); err == nil {
+			return date, nil
+		}
+		return time.Parse(time.RFC3339Nano, value)
+	case schema.Bool:
+		return strconv.ParseBool(value)
+	case schema.Int:
+		return strconv.ParseInt(value, 10, 64)
+	case schema.Uint:
+		return strconv.ParseUint(value, 10, 64)
+	case schema.Float:
+		return strconv.ParseFloat(value, 64)
+	default:
+		return value, nil
+	}
+}
+
+func isReserved(parameter string, reserved []string) bool {
+	for _, reservedParameter := range reserved {
+		if parameter == reservedParameter {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,306 @@
+package controller
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	apiError "xm/error"
+	"xm/model"
+	"xm/repository"
+)
+
+const (
+	// ImportFormatCSV is the format of CSV files with a header row
+	ImportFormatCSV = "csv"
+	// ImportFormatNDJSON is the format of files with one JSON company per line
+	ImportFormatNDJSON = "ndjson"
+
+	mediaTypeCSV    = "text/csv"
+	mediaTypeNDJSON = "application/x-ndjson"
+)
+
+// ImportOptions control how companies are imported
+type ImportOptions struct {
+	// Format is either ImportFormatCSV or ImportFormatNDJSON
+	Format string
+	// DryRun only validates the companies without writing anything
+	DryRun bool
+	// Upsert updates the existing company with the same code instead of failing with a conflict
+	Upsert bool
+}
+
+// ImportReport is the outcome of an import, on a dry run Created and Updated count the companies that would be
+// created or updated
+type ImportReport struct {
+	DryRun  bool             `json:"dryRun"`
+	Rows    int              `json:"rows"`
+	Created int              `json:"created"`
+	Updated int              `json:"updated"`
+	Failed  int              `json:"failed"`
+	Errors  []ImportRowError `json:"errors"`
+	// Error stopped reading the file after the rows of the report, which have been imported
+	Error interface{} `json:"error,omitempty"`
+}
+
+// ImportRowError is the error of a row that couldn't be imported
+type ImportRowError struct {
+	Line  int         `json:"line"`
+	Error interface{} `json:"error"`
+}
+
+// importRow is a company read from the import file together with its position in the file
+type importRow struct {
+	line    int
+	company companyDTO
+	err     error
+}
+
+// csvColumns are the CSV header columns and the companyDTO fields they map to
+var csvColumns = map[string]func(dto *companyDTO) *string{
+	"id":      func(dto *companyDTO) *string { return &dto.ID },
+	"name":    func(dto *companyDTO) *string { return &dto.Name },
+	"code":    func(dto *companyDTO) *string { return &dto.Code },
+	"country": func(dto *companyDTO) *string { return &dto.Country },
+	"website": func(dto *companyDTO) *string { return &dto.Website },
+	"phone":   func(dto *companyDTO) *string { return &dto.Phone },
+}
+
+// importCompanies imports the companies of the request body, its content type selects the format
+func (controller *companyController) importCompanies(w http.ResponseWriter, r *http.Request) {
+	options := ImportOptions{}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case mediaTypeCSV:
+		options.Format = ImportFormatCSV
+	case mediaTypeNDJSON:
+		options.Format = ImportFormatNDJSON
+	default:
+		respondError(w, unsupportedMediaTypeError{r.Header.Get("Content-Type")})
+		return
+	}
+
+	failedFieldValidations := map[string]string{}
+	for parameter, target := range map[string]*bool{"dryRun": &options.DryRun, "upsert": &options.Upsert} {
+		switch r.FormValue(parameter) {
+		case "", "false":
+		case "true":
+			*target = true
+		default:
+			failedFieldValidations[parameter] = apiError.ErrorCodeInvalidValue
+		}
+	}
+	if len(failedFieldValidations) > 0 {
+		respondError(w, apiError.NewInvalidFieldsError(failedFieldValidations))
+		return
+	}
+
+	if r.Body == nil {
+		respondError(w, apiError.NewInvalidRequestPayloadError(apiError.ErrorCodeEmptyRequestBody))
+		return
+	}
+
+	report, err := controller.Import(r.Body, options)
+	if err != nil {
+		controller.app.Logger.Err(err).Msg("unable to import companies")
+		if report.Rows == 0 {
+			respondError(w, err)
+			return
+		}
+		status, payload := errorResponse(err)
+		report.Error = payload
+		respondJSON(w, status, report)
+		return
+	}
+
+	respondJSON(w, http.StatusOK, report)
+	return
+}
+
+// Import reads the companies from the reader and adds them, each row in its own transaction.
+// Rows that fail are reported by their line number. When the file can't be read to its end, the report of the rows
+// read before is returned together with the error.
+func (controller *companyController) Import(reader io.Reader, options ImportOptions) (*ImportReport, error) {
+	rows := make(chan importRow)
+	done := make(chan struct{})
+	defer close(done)
+
+	var readErr error
+	go func() {
+		defer close(rows)
+		switch options.Format {
+		case ImportFormatCSV:
+			readErr = readCSV(reader, rows, done)
+		case ImportFormatNDJSON:
+			readErr = readNDJSON(reader, rows, done)
+		default:
+			readErr = apiError.NewInvalidFieldsError(map[string]string{"format": apiError.ErrorCodeInvalidValue})
+		}
+	}()
+
+	report := &ImportReport{DryRun: options.DryRun, Errors: []ImportRowError{}}
+	dryRunCodes := map[string]bool{}
+	for row := range rows {
+		report.Rows++
+		updated, err := controller.importRow(row, options, dryRunCodes)
+		switch {
+		case err != nil:
+			report.Failed++
+			_, payload := errorResponse(err)
+			report.Errors = append(report.Errors, ImportRowError{Line: row.line, Error: payload})
+		case updated:
+			report.Updated++
+		default:
+			report.Created++
+		}
+	}
+
+	return report, readErr
+}
+
+// importRow adds the company of the row, or updates the existing company with the same code when upserting.
+// It reports whether an existing company has been updated. As nothing is written on a dry run, the codes of the rows
+// that would have been written are tracked in dryRunCodes, so that the rows with the same code are reported like on
+// the actual run.
+func (controller *companyController) importRow(row importRow, options ImportOptions, dryRunCodes map[string]bool) (bool, error) {
+	if row.err != nil {
+		return false, row.err
+	}
+
+	uow := repository.NewUnitOfWork(controller.app.DB, options.DryRun)
+	defer uow.Complete()
+
+	company, err := model.NewCompany(row.company.Name, row.company.Code, row.company.Country, row.company.Website, row.company.Phone)
+	if err != nil {
+		return false, err
+	}
+
+	var existingCompany *model.Company
+	if options.Upsert {
+		if existingCompany, err = controller.findCompanyWithCode(uow, company); err != nil {
+			return false, err
+		}
+	}
+
+	if options.DryRun {
+		code := controller.codeScopeKey(company)
+		if dryRunCodes[code] {
+			if options.Upsert {
+				return true, nil
+			}
+			return false, apiError.NewConflictError(map[string]string{"code": apiError.ErrorCodeAlreadyExists}, "")
+		}
+		if existingCompany == nil {
+			if err := controller.checkCodeConflict(uow, company); err != nil {
+				return false, err
+			}
+		}
+		dryRunCodes[code] = true
+		return existingCompany != nil, nil
+	}
+
+	if existingCompany == nil {
+		_, err = controller.createCompany(uow, row.company)
+	} else {
+		_, err = controller.updateCompany(uow, existingCompany.ID.String(), "", row.company)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	uow.Commit()
+	return existingCompany != nil, nil
+}
+
+// readCSV sends a row for every record of the CSV, the first record is the header which maps columns to fields
+func readCSV(reader io.Reader, rows chan<- importRow, done <-chan struct{}) error {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	header, err := csvReader.Read()
+	if err == io.EOF {
+		return apiError.NewInvalidRequestPayloadError(apiError.ErrorCodeEmptyRequestBody)
+	}
+	if err != nil {
+		return apiError.NewInvalidRequestPayloadError(apiError.ErrorCodeInvalidCSV)
+	}
+
+	fields := make([]func(dto *companyDTO) *string, len(header))
+	failedFieldValidations := map[string]string{}
+	for index, column := range header {
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		if fields[index] = csvColumns[column]; fields[index] == nil {
+			failedFieldValidations[column] = apiError.ErrorCodeUnknownField
+		}
+	}
+	if len(failedFieldValidations) > 0 {
+		return apiError.NewInvalidFieldsError(failedFieldValidations)
+	}
+
+	for {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			return nil
+		}
+
+		row := importRow{}
+		var parseError *csv.ParseError
+		switch {
+		case errors.As(err, &parseError):
+			row.line = parseError.StartLine
+			row.err = apiError.NewInvalidRequestPayloadError(apiError.ErrorCodeInvalidCSV)
+		case err != nil:
+			return apiError.NewDataReadWriteError(err)
+		case len(record) != len(header):
+			row.line, _ = csvReader.FieldPos(0)
+			row.err = apiError.NewInvalidRequestPayloadError(apiError.ErrorCodeInvalidCSV)
+		default:
+			row.line, _ = csvReader.FieldPos(0)
+			for index, value := range record {
+				*fields[index](&row.company) = value
+			}
+		}
+
+		select {
+		case rows <- row:
+		case <-done:
+			return nil
+		}
+	}
+}
+
+// readNDJSON sends a row for every non blank line
+func readNDJSON(reader io.Reader, rows chan<- importRow, done <-chan struct{}) error {
+	scanner := bufio.NewScanner(reader)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+
+		row := importRow{line: line}
+		if err := json.Unmarshal(scanner.Bytes(), &row.company); err != nil {
+			row.err = apiError.NewInvalidRequestPayloadError(apiError.ErrorCodeInvalidJSON)
+		}
+
+		select {
+		case rows <- row:
+		case <-done:
+			return nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return apiError.NewDataReadWriteError(err)
+	}
+	if line == 0 {
+		return apiError.NewInvalidRequestPayloadError(apiError.ErrorCodeEmptyRequestBody)
+	}
+	return nil
+}
@@ -1,49 +1,182 @@
 package controller
 
 import (
+	"errors"
 	"fmt"
+	"github.com/rs/zerolog"
+	"net"
 	"net/http"
-	"os"
+	"time"
+	"xm/auth"
 	"xm/client"
 	apiError "xm/error"
+	"xm/model"
+	"xm/policy"
 )
 
-// protect makes sure that caller is authorized to make the call before invoking actual handler
-func protect(ipLocationClient client.IPLocationClient, handlerFunc func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
-	return func(w http.ResponseWriter, r *http.Request) {
-		ip := getUserIP(r)
+// names of the routes, used to configure their access policy and origin check policy
+const (
+	routeCreate  = "create"
+	routeList    = "list"
+	routeSearch  = "search"
+	routeGet     = "get"
+	routeUpdate  = "update"
+	routePatch   = "patch"
+	routeDelete  = "delete"
+	routeRestore = "restore"
+	routeBatch   = "batch"
+	routeImport  = "import"
+)
 
-		country, err := ipLocationClient.GetLocation(ip)
-		if err != nil || country != originCountry() {
-			fmt.Println(err)
-			respondJSON(w, http.StatusUnauthorized, map[string]string{"error": apiError.ErrorCodeInvalidRequestOrigin})
-			return
+// routes are the names of all the routes
+var routes = []string{routeCreate, routeList, routeSearch, routeGet, routeUpdate, routePatch, routeDelete, routeRestore, routeBatch, routeImport}
+
+// isRoute reports whether the name is the name of a route
+func isRoute(name string) bool {
+	for _, route := range routes {
+		if route == name {
+			return true
 		}
+	}
+	return false
+}
 
-		handlerFunc(w, r)
+// routeScopes are the scopes authenticated callers need to be granted to call the routes
+var routeScopes = map[string]string{
+	routeCreate:  model.ScopeCompaniesWrite,
+	routeList:    model.ScopeCompaniesRead,
+	routeSearch:  model.ScopeCompaniesRead,
+	routeGet:     model.ScopeCompaniesRead,
+	routeUpdate:  model.ScopeCompaniesWrite,
+	routePatch:   model.ScopeCompaniesWrite,
+	routeDelete:  model.ScopeCompaniesWrite,
+	routeRestore: model.ScopeCompaniesWrite,
+	routeBatch:   model.ScopeCompaniesWrite,
+	routeImport:  model.ScopeCompaniesWrite,
+}
+
+// protectedRoutes are the routes only allowed from the origin country by the default access policy
+var protectedRoutes = []string{routeCreate, routeDelete, routeRestore, routeBatch, routeImport}
+
+// originPolicy decides how a protected route handles the location of the caller not being available
+type originPolicy int
+
+const (
+	// failClosed rejects the request with 503
+	failClosed originPolicy = iota
+	// failOpen allows the request and writes an audit log entry
+	failOpen
+)
+
+// parseOriginPolicies returns the policies of the routes, the routes that fail open are named, the others fail closed
+func parseOriginPolicies(failOpenRoutes []string) (map[string]originPolicy, error) {
+	policies := map[string]originPolicy{}
+	for _, route := range routes {
+		policies[route] = failClosed
 	}
+	for _, route := range failOpenRoutes {
+		if _, ok := policies[route]; !ok {
+			return nil, fmt.Errorf("unknown route: %q", route)
+		}
+		policies[route] = failOpen
+	}
+	return policies, nil
 }
 
-// getUserIP gets ip address from request based on
-// X-Real-Ip - fetches first true IP (if the requests sits behind multiple NAT sources/load balancer)
-// X-Forwarded-For - if for some reason X-Real-Ip is blank and does not return response, get from X-Forwarded-For
-// Remote Address - last resort (usually won't be reliable as this might be the last ip or if it is a naked http request to server ie no load balancer)
-func getUserIP(r *http.Request) string {
-	ipAddress := r.Header.Get("X-Real-Ip")
-	if ipAddress == "" {
-		ipAddress = r.Header.Get("X-Forwarded-For")
+// newAccessPolicy returns the engine of the access policy file, or the default policy, which only allows the protected
+// routes from the origin country, when there isn't one
+func newAccessPolicy(path string, reloadInterval time.Duration, originCountry string, logger *zerolog.Logger) (policy.Engine, error) {
+	if len(path) > 0 {
+		return policy.NewFileEngine(path, reloadInterval, routes, logger)
+	}
+	defaultPolicy, err := policy.New(routes, &policy.Rule{Name: "origin-country", Routes: protectedRoutes, AllowCountries: []string{originCountry}})
+	if err != nil {
+		return nil, err
 	}
-	if ipAddress == "" {
-		ipAddress = r.RemoteAddr
+	return policy.NewEngine(defaultPolicy), nil
+}
+
+// protect makes sure that caller is authorized to make the call before invoking actual handler, by authenticating the
+// caller, checking the scope of the route and the role required by it, limiting the rate of the calls of the caller
+// and evaluating the access policy. When the location of the caller can't be looked up the origin check policy of the
+// route decides whether the country conditions are skipped or the call is rejected. When the providers of a majority
+// vote don't agree on the country the call is rejected.
+func (controller *companyController) protect(route string, role string, handlerFunc func(w http.ResponseWriter, r *http.Request)) func(w http.ResponseWriter, r *http.Request) {
+	originPolicy := controller.originPolicies[route]
+	return func(w http.ResponseWriter, r *http.Request) {
+		ip := controller.trustedProxies.clientIP(r)
+		if !controller.rateLimits.allowAuthentication(w, r, ip, controller.app.Logger) {
+			return
+		}
+		r, ok := controller.authentication.authenticate(w, r, routeScopes[route], role, controller.app.Logger)
+		if !ok {
+			return
+		}
+		_, authenticated := auth.IdentityFrom(r.Context())
+		if !controller.rateLimits.allow(w, r, route, ip, controller.app.Logger) {
+			return
+		}
+
+		var location client.Location
+		request := policy.Request{
+			Route:         route,
+			Method:        r.Method,
+			IP:            net.ParseIP(ip),
+			Time:          time.Now(),
+			Authenticated: authenticated,
+			Country: func() (string, error) {
+				var err error
+				location, err = controller.ipLocationClient.GetLocation(r.Context(), ip)
+				if err != nil && !client.IsUpstreamUnavailable(err) {
+					controller.app.Logger.Info().Err(err).Str("route", route).Str("ip", ip).Msg("unable to find the location of the caller")
+					return "", nil
+				}
+				return location.Country, err
+			},
+		}
+
+		decision, err := controller.accessPolicy.Evaluate(request)
+		if errors.Is(err, client.ErrNoMajority) {
+			// the providers have answered without agreeing on the country, the origin is unknown rather than unavailable
+			controller.app.Logger.Info().Err(err).Str("route", route).Str("ip", ip).
+				Msg("rejecting request as the ip location providers disagree on the country of the caller")
+			respondJSON(w, http.StatusUnauthorized, map[string]string{"error": apiError.ErrorCodeInvalidRequestOrigin})
+			return
+		}
+		if err != nil {
+			if originPolicy != failOpen {
+				controller.app.Logger.Err(err).Str("route", route).Str("ip", ip).Msg("unable to look up the location of the caller")
+				respondJSON(w, http.StatusServiceUnavailable, map[string]string{"error": apiError.ErrorCodeUpstreamUnavailable})
+				return
+			}
+			controller.app.Logger.Warn().Err(err).Str("audit", "originCheckFailedOpen").Str("route", route).Str("ip", ip).
+				Msg("skipping the country conditions as the location of the caller can't be looked up")
+			request.Country = nil
+			decision, _ = controller.accessPolicy.Evaluate(request)
+		}
+
+		if !decision.Allowed {
+			controller.app.Logger.Info().Str("route", route).Str("ip", ip).Str("country", location.Country).
+				Str("provider", location.Provider).Str("rule", decision.Rule).Str("reason", decision.Reason).
+				Msg("rejecting request denied by the access policy")
+			respondAccessDenied(w, decision)
+			return
+		}
+
+		controller.app.Logger.Debug().Str("route", route).Str("ip", ip).Str("country", location.Country).
+			Str("provider", location.Provider).Msg("allowing request by the access policy")
+		handlerFunc(w, r)
 	}
-	return ipAddress
 }
 
-func originCountry() string {
-	origin := os.Getenv("ORIGIN_COUNTRY")
-	if len(origin) == 0 {
-		origin = "CY"
-		// todo change it to CY when pushed
+// respondAccessDenied responds with the error of the reason of the denial and the name of the rule that denied it
+func respondAccessDenied(w http.ResponseWriter, decision policy.Decision) {
+	status, errorCode := http.StatusUnauthorized, apiError.ErrorCodeInvalidRequestOrigin
+	switch decision.Reason {
+	case policy.ReasonUnauthenticated:
+		errorCode = apiError.ErrorCodeUnauthenticated
+	case policy.ReasonOutsideTimeWindow:
+		status, errorCode = http.StatusForbidden, apiError.ErrorCodeAccessDenied
 	}
-	return origin
+	respondJSON(w, status, map[string]string{"error": errorCode, "rule": decision.Rule})
 }
@@ -0,0 +1,139 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/rs/zerolog"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"xm/app"
+	"xm/auth"
+	"xm/client"
+	"xm/model"
+)
+
+// fakeLocationClient answers every lookup with the same location or error
+type fakeLocationClient struct {
+	country string
+	err     error
+}
+
+func (fake fakeLocationClient) GetLocation(ctx context.Context, ip string) (client.Location, error) {
+	return client.Location{Country: fake.country, Provider: "fake"}, fake.err
+}
+
+// identityAuthenticator authenticates every request as the same caller, or finds no credentials when it's nil
+type identityAuthenticator struct {
+	identity *auth.Identity
+}
+
+func (authenticator identityAuthenticator) Authenticate(r *http.Request) (*auth.Identity, error) {
+	return authenticator.identity, nil
+}
+
+func TestProtect(t *testing.T) {
+	logger := zerolog.New(ioutil.Discard)
+	upstreamErr := errors.New("connection refused")
+	notFoundErr := fmt.Errorf("%w: reserved ip address", client.ErrLocationNotFound)
+
+	// the providers of a majority vote answer with a country each
+	splitVote, err := client.NewChainLocationClient(client.MajorityVote, fakeLocationClient{country: "CY"}, fakeLocationClient{country: "GR"}, fakeLocationClient{country: "US"})
+	if err != nil {
+		t.Fatalf("unable to create chain: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		failOpenRoutes []string
+		client         client.IPLocationClient
+		expectedStatus int
+		expectedRule   string
+	}{
+		{"+ve:ShouldAllowCallerFromOrigin", nil, fakeLocationClient{country: "CY"}, http.StatusOK, ""},
+		{"-ve:ShouldRejectCallerFromElsewhere", nil, fakeLocationClient{country: "US"}, http.StatusUnauthorized, "origin-country"},
+		{"-ve:ShouldRejectCallerWithoutLocation", []string{routeCreate}, fakeLocationClient{err: notFoundErr}, http.StatusUnauthorized, "origin-country"},
+		{"-ve:ShouldRejectCallerWithoutMajority", []string{routeCreate}, splitVote, http.StatusUnauthorized, ""},
+		{"-ve:ShouldFailClosedWhenUpstreamIsUnavailable", []string{routeDelete}, fakeLocationClient{err: upstreamErr}, http.StatusServiceUnavailable, ""},
+		{"-ve:ShouldFailClosedWhenCircuitIsOpen", nil, fakeLocationClient{err: client.ErrCircuitOpen}, http.StatusServiceUnavailable, ""},
+		{"+ve:ShouldFailOpenWhenUpstreamIsUnavailable", []string{routeCreate}, fakeLocationClient{err: upstreamErr}, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originPolicies, err := parseOriginPolicies(tt.failOpenRoutes)
+			if err != nil {
+				t.Fatalf("unable to parse policies: %v", err)
+			}
+			accessPolicy, err := newAccessPolicy("", time.Minute, "CY", &logger)
+			if err != nil {
+				t.Fatalf("unable to create access policy: %v", err)
+			}
+			controller := &companyController{app: &app.App{Logger: &logger}, ipLocationClient: tt.client, originPolicies: originPolicies, accessPolicy: accessPolicy,
+				authentication: authentication{authenticator: identityAuthenticator{identity: &auth.Identity{Subject: "alice", Roles: []string{model.RoleEditor}}}}}
+
+			handler := controller.protect(routeCreate, model.RoleEditor, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			response := httptest.NewRecorder()
+			handler(response, httptest.NewRequest(http.MethodPost, "/api/companies", nil))
+
+			if tt.expectedStatus != response.Code {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, response.Code)
+			}
+			if response.Code == http.StatusUnauthorized && !strings.Contains(response.Body.String(), `"error":"Key_InvalidRequestOrigin"`) {
+				t.Errorf("expected Key_InvalidRequestOrigin in %s", response.Body.String())
+			}
+			if len(tt.expectedRule) > 0 && !strings.Contains(response.Body.String(), `"rule":"`+tt.expectedRule+`"`) {
+				t.Errorf("expected the denying rule %s in %s", tt.expectedRule, response.Body.String())
+			}
+		})
+	}
+
+	if _, err := parseOriginPolicies([]string{"archive"}); err == nil {
+		t.Errorf("expected error for unknown route")
+	}
+}
+
+func TestProtectRoles(t *testing.T) {
+	logger := zerolog.New(ioutil.Discard)
+
+	tests := []struct {
+		name           string
+		identity       *auth.Identity
+		role           string
+		expectedStatus int
+	}{
+		{"+ve:ShouldAllowCallerWithRole", &auth.Identity{Subject: "alice", Roles: []string{model.RoleEditor}}, model.RoleEditor, http.StatusOK},
+		{"+ve:ShouldAllowCallerWithIncludingRole", &auth.Identity{Subject: "alice", Roles: []string{model.RoleAdmin}}, model.RoleEditor, http.StatusOK},
+		{"-ve:ShouldRejectCallerWithoutRoles", &auth.Identity{Subject: "alice"}, model.RoleViewer, http.StatusForbidden},
+		{"-ve:ShouldRejectAnonymousCaller", nil, model.RoleViewer, http.StatusUnauthorized},
+		{"-ve:ShouldRejectCallerWithLesserRole", &auth.Identity{Subject: "alice", Roles: []string{model.RoleViewer}}, model.RoleEditor, http.StatusForbidden},
+		{"-ve:ShouldRejectCallerWithNoRole", &auth.Identity{Subject: "alice", Roles: []string{}}, model.RoleViewer, http.StatusForbidden},
+		{"-ve:ShouldRejectCallerWithUnknownRole", &auth.Identity{Subject: "alice", Roles: []string{"owner"}}, model.RoleViewer, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originPolicies, _ := parseOriginPolicies(nil)
+			accessPolicy, _ := newAccessPolicy("", time.Minute, "CY", &logger)
+			controller := &companyController{app: &app.App{Logger: &logger}, ipLocationClient: fakeLocationClient{country: "CY"}, originPolicies: originPolicies,
+				accessPolicy: accessPolicy, authentication: authentication{authenticator: identityAuthenticator{identity: tt.identity}}}
+
+			handler := controller.protect(routeUpdate, tt.role, func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+			response := httptest.NewRecorder()
+			handler(response, httptest.NewRequest(http.MethodPut, "/api/companies/1", nil))
+
+			if tt.expectedStatus != response.Code {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, response.Code)
+			}
+			if response.Code == http.StatusForbidden && !strings.Contains(response.Body.String(), `"error":"Key_Forbidden"`) {
+				t.Errorf("expected Key_Forbidden in %s", response.Body.String())
+			}
+		})
+	}
+}
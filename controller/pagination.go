@@ -0,0 +1,169 @@
+package controller
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"gorm.io/gorm/schema"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	apiError "xm/error"
+	"xm/repository"
+)
+
+const (
+	defaultPageLimit = 50
+	maxPageLimit     = 500
+	// defaultSort keeps records in the order they have been created
+	defaultSort = "createdOn"
+)
+
+// pageParameters are the query parameters used for pagination
+var pageParameters = []string{"limit", "cursor", "sort"}
+
+// pageCursor is the opaque position of the last record of a page, handed out to clients as nextCursor
+type pageCursor struct {
+	Sort   string        `json:"s"`
+	Values []interface{} `json:"v"`
+}
+
+// parseLimit parses the page size, falling back to the default page size when it is not specified
+func parseLimit(limit string) (int, error) {
+	if len(limit) == 0 {
+		return defaultPageLimit, nil
+	}
+	value, err := strconv.Atoi(limit)
+	if err != nil || value < 1 || value > maxPageLimit {
+		return 0, apiError.NewInvalidFieldsError(map[string]string{"limit": apiError.ErrorCodeInvalidValue})
+	}
+	return value, nil
+}
+
+// parseSort parses comma separated column names, prefixed by '-' for descending order (e.g. '-createdOn,name').
+// The primary key is always appended as the last sort field so that the order is stable.
+func parseSort(sort string, columns map[string]*schema.Field) ([]repository.SortField, error) {
+	var sortFields []repository.SortField
+	hasPrimaryKey := false
+
+	for _, column := range strings.Split(sort, ",") {
+		sortField := repository.SortField{Column: strings.TrimSpace(column)}
+		if strings.HasPrefix(sortField.Column, "-") {
+			sortField.Column = sortField.Column[1:]
+			sortField.Descending = true
+		}
+		field, ok := columns[sortField.Column]
+		if !ok {
+			return nil, apiError.NewInvalidFieldsError(map[string]string{"sort": apiError.ErrorCodeInvalidValue})
+		}
+		hasPrimaryKey = hasPrimaryKey || field.PrimaryKey
+		sortField.Nullable = repository.Nullable(field)
+		sortFields = append(sortFields, sortField)
+	}
+
+	if !hasPrimaryKey {
+		sortFields = append(sortFields, repository.SortField{Column: "id"})
+	}
+	return sortFields, nil
+}
+
+// encodeCursor creates the cursor pointing at the specified entity
+func encodeCursor(sort string, sortFields []repository.SortField, columns map[string]*schema.Field, entity interface{}) (string, error) {
+	entityValue := reflect.Indirect(reflect.ValueOf(entity))
+
+	cursor := pageCursor{Sort: sort, Values: make([]interface{}, len(sortFields))}
+	for index, sortField := range sortFields {
+		cursor.Values[index], _ = columns[sortField.Column].ValueOf(context.Background(), entityValue)
+	}
+
+	cursorJSON, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(cursorJSON), nil
+}
+
+// decodeCursor returns the values of the sort fields stored in the cursor
+func decodeCursor(encodedCursor, sort string, sortFields []repository.SortField, columns map[string]*schema.Field) ([]interface{}, error) {
+	invalidCursorError := apiError.NewInvalidFieldsError(map[string]string{"cursor": apiError.ErrorCodeInvalidValue})
+
+	cursorJSON, err := base64.RawURLEncoding.DecodeString(encodedCursor)
+	if err != nil {
+		return nil, invalidCursorError
+	}
+
+	var cursor pageCursor
+	if err := json.Unmarshal(cursorJSON, &cursor); err != nil {
+		return nil, invalidCursorError
+	}
+
+	// cursor is only valid for the order it has been created with
+	if cursor.Sort != sort || len(cursor.Values) != len(sortFields) {
+		return nil, invalidCursorError
+	}
+
+	for index, sortField := range sortFields {
+		if cursor.Values[index] == nil {
+			if sortField.Nullable {
+				continue
+			}
+			return nil, invalidCursorError
+		}
+		if columns[sortField.Column].DataType != schema.Time {
+			continue
+		}
+		value, ok := cursor.Values[index].(string)
+		if !ok {
+			return nil, invalidCursorError
+		}
+		if cursor.Values[index], err = time.Parse(time.RFC3339Nano, value); err != nil {
+			return nil, invalidCursorError
+		}
+	}
+	return cursor.Values, nil
+}
+
+// pageRequest holds the pagination parameters of a list request
+type pageRequest struct {
+	limit      int
+	sort       string
+	sortFields []repository.SortField
+	after      []interface{}
+}
+
+// parsePageRequest parses the 'limit', 'cursor' and 'sort' query parameters
+func parsePageRequest(r *http.Request, columns map[string]*schema.Field) (*pageRequest, error) {
+	limit, err := parseLimit(r.FormValue("limit"))
+	if err != nil {
+		return nil, err
+	}
+
+	sort := r.FormValue("sort")
+	if len(sort) == 0 {
+		sort = defaultSort
+	}
+	sortFields, err := parseSort(sort, columns)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &pageRequest{limit: limit, sort: sort, sortFields: sortFields}
+	if cursor := r.FormValue("cursor"); len(cursor) > 0 {
+		if page.after, err = decodeCursor(cursor, sort, sortFields, columns); err != nil {
+			return nil, err
+		}
+	}
+	return page, nil
+}
+
+// queryProcessors returns the query processors that fetch the page, one record more than the limit is fetched
+// to find out whether there is a next page
+func (page *pageRequest) queryProcessors() []repository.QueryProcessor {
+	queryProcessors := []repository.QueryProcessor{repository.Order(page.sortFields...), repository.Limit(page.limit + 1)}
+	if page.after != nil {
+		queryProcessors = append(queryProcessors, repository.After(page.sortFields, page.after))
+	}
+	return queryProcessors
+}
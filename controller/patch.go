@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"mime"
+	apiError "xm/error"
+)
+
+const (
+	// mediaTypeMergePatch is the content type of JSON Merge Patch documents (RFC 7396)
+	mediaTypeMergePatch = "application/merge-patch+json"
+	// mediaTypeJSONPatch is the content type of JSON Patch documents (RFC 6902)
+	mediaTypeJSONPatch = "application/json-patch+json"
+)
+
+// unsupportedMediaTypeError is returned when the content type of the request body is not supported
+type unsupportedMediaTypeError struct {
+	contentType string
+}
+
+func (e unsupportedMediaTypeError) Error() string {
+	return fmt.Sprintf("unsupported media type: %s", e.contentType)
+}
+
+// applyPatch applies the JSON Merge Patch or JSON Patch document, depending on the content type, to the company
+func applyPatch(contentType string, company companyDTO, patch []byte) (companyDTO, error) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return company, unsupportedMediaTypeError{contentType}
+	}
+
+	document, err := json.Marshal(company)
+	if err != nil {
+		return company, err
+	}
+
+	var patchedDocument []byte
+	switch mediaType {
+	case mediaTypeMergePatch:
+		if !json.Valid(patch) {
+			return company, apiError.NewInvalidRequestPayloadError(apiError.ErrorCodeInvalidJSON)
+		}
+		if patchedDocument, err = jsonpatch.MergePatch(document, patch); err != nil {
+			return company, apiError.NewInvalidRequestPayloadError(apiError.ErrorCodeInvalidPatch)
+		}
+	case mediaTypeJSONPatch:
+		operations, err := jsonpatch.DecodePatch(patch)
+		if err != nil {
+			return company, apiError.NewInvalidRequestPayloadError(apiError.ErrorCodeInvalidJSON)
+		}
+		if patchedDocument, err = operations.Apply(document); err != nil {
+			return company, apiError.NewInvalidRequestPayloadError(apiError.ErrorCodeInvalidPatch)
+		}
+	default:
+		return company, unsupportedMediaTypeError{contentType}
+	}
+
+	patchedCompany := companyDTO{}
+	if err := json.Unmarshal(patchedDocument, &patchedCompany); err != nil {
+		return company, apiError.NewInvalidRequestPayloadError(apiError.ErrorCodeInvalidPatch)
+	}
+	return patchedCompany, nil
+}
@@ -0,0 +1,133 @@
+package controller
+
+import (
+	"fmt"
+	"github.com/rs/zerolog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+	"xm/app"
+	"xm/auth"
+	apiError "xm/error"
+	"xm/ratelimit"
+)
+
+// defaultRateLimit names the limit of the routes that don't have their own
+const defaultRateLimit = "default"
+
+// names of the rate limits that aren't company routes
+const (
+	// rateLimitAuthenticate limits the requests of every ip before their credentials are checked, so that guessing
+	// credentials is slow and doesn't cost a lookup of the key or of the JWKS per guess
+	rateLimitAuthenticate = "authenticate"
+	// rateLimitKeys limits the calls of every client to the API key routes
+	rateLimitKeys = "keys"
+)
+
+// defaultAuthenticateLimit is the limit of the requests of every ip before authentication when it isn't set, the
+// default limit doesn't apply to it as all the clients behind an ip share it
+var defaultAuthenticateLimit = ratelimit.Limit{Requests: 600, Period: time.Minute}
+
+// rateLimits limit the calls of every client to the routes, the routes without limit aren't limited
+type rateLimits struct {
+	store  ratelimit.Store
+	limits map[string]ratelimit.Limit
+}
+
+// newRateLimits returns the rate limits of the config, their buckets are kept in the store, in memory when it's nil
+func newRateLimits(config app.Config, store ratelimit.Store) (rateLimits, error) {
+	limits, err := parseRateLimits(config.RateLimits)
+	if err != nil {
+		return rateLimits{}, err
+	}
+	if store == nil {
+		store = ratelimit.NewMemoryStore()
+	}
+	return rateLimits{store: store, limits: limits}, nil
+}
+
+// parseRateLimits returns the limits of the routes, written as <requests>/<s|m|h> by route name, the default limit
+// applies to the routes and to the API key routes that aren't named
+func parseRateLimits(routeLimits map[string]string) (map[string]ratelimit.Limit, error) {
+	limits := map[string]ratelimit.Limit{rateLimitAuthenticate: defaultAuthenticateLimit}
+	if value, ok := routeLimits[defaultRateLimit]; ok {
+		limit, err := ratelimit.ParseLimit(value)
+		if err != nil {
+			return nil, err
+		}
+		for _, route := range routes {
+			limits[route] = limit
+		}
+		limits[rateLimitKeys] = limit
+	}
+	for route, value := range routeLimits {
+		if route == defaultRateLimit {
+			continue
+		}
+		if !isRoute(route) && route != rateLimitKeys && route != rateLimitAuthenticate {
+			return nil, fmt.Errorf("unknown route: %q", route)
+		}
+		limit, err := ratelimit.ParseLimit(value)
+		if err != nil {
+			return nil, err
+		}
+		limits[route] = limit
+	}
+	return limits, nil
+}
+
+// allow takes a token from the bucket of the caller for the route and sets the RateLimit headers. The caller is
+// identified by its credentials, or by its ip when it's anonymous. When the bucket is empty 429 is responded and false
+// is returned.
+func (rateLimits rateLimits) allow(w http.ResponseWriter, r *http.Request, route string, ip string, logger *zerolog.Logger) bool {
+	caller := "ip:" + ip
+	if identity, authenticated := auth.IdentityFrom(r.Context()); authenticated {
+		caller = identity.Method + ":" + identity.Subject
+	}
+	result, limited := rateLimits.take(r, route, caller, logger)
+	if !limited {
+		return true
+	}
+
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+	return result.Allowed || rejectOverLimit(w, route, caller, result, logger)
+}
+
+// allowAuthentication takes a token from the bucket of the ip before the credentials of the request are checked. When
+// the bucket is empty 429 is responded and false is returned. The RateLimit headers are left to the limit of the route.
+func (rateLimits rateLimits) allowAuthentication(w http.ResponseWriter, r *http.Request, ip string, logger *zerolog.Logger) bool {
+	result, limited := rateLimits.take(r, rateLimitAuthenticate, "ip:"+ip, logger)
+	return !limited || result.Allowed || rejectOverLimit(w, rateLimitAuthenticate, "ip:"+ip, result, logger)
+}
+
+// take takes a token from the bucket of the caller for the limit, false is returned when there's no such limit or the
+// store fails
+func (rateLimits rateLimits) take(r *http.Request, name string, caller string, logger *zerolog.Logger) (ratelimit.Result, bool) {
+	limit, ok := rateLimits.limits[name]
+	if !ok {
+		return ratelimit.Result{}, false
+	}
+	result, err := rateLimits.store.Take(r.Context(), name+"|"+caller, limit)
+	if err != nil {
+		// the service stays available when the store isn't
+		logger.Err(err).Str("route", name).Str("caller", caller).Msg("unable to take rate limit token, allowing request")
+		return ratelimit.Result{}, false
+	}
+	return result, true
+}
+
+// rejectOverLimit responds 429 with the time to wait before retrying, it returns false
+func rejectOverLimit(w http.ResponseWriter, name string, caller string, result ratelimit.Result, logger *zerolog.Logger) bool {
+	logger.Info().Str("route", name).Str("caller", caller).Msg("rejecting request over the rate limit")
+	w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+	respondJSON(w, http.StatusTooManyRequests, map[string]string{"error": apiError.ErrorCodeTooManyRequests})
+	return false
+}
+
+// ceilSeconds returns the duration in whole seconds, rounded up
+func ceilSeconds(duration time.Duration) int {
+	return int(math.Ceil(duration.Seconds()))
+}
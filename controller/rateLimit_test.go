@@ -0,0 +1,122 @@
+package controller
+
+import (
+	"context"
+	"github.com/rs/zerolog"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"xm/app"
+	"xm/auth"
+	"xm/model"
+	"xm/ratelimit"
+)
+
+func TestParseRateLimits(t *testing.T) {
+	limits, err := parseRateLimits(map[string]string{"default": "100/m", routeCreate: "10/s"})
+	if err != nil {
+		t.Fatalf("unable to parse rate limits: %v", err)
+	}
+	if limits[routeCreate] != (ratelimit.Limit{Requests: 10, Period: time.Second}) || limits[routeList] != (ratelimit.Limit{Requests: 100, Period: time.Minute}) {
+		t.Errorf("expected the default limit to apply to the routes that aren't named, got %+v", limits)
+	}
+	if limits[rateLimitKeys] != (ratelimit.Limit{Requests: 100, Period: time.Minute}) || limits[rateLimitAuthenticate] != defaultAuthenticateLimit {
+		t.Errorf("expected the default limit of the API key routes and of the authentications, got %+v", limits)
+	}
+	if limits, _ := parseRateLimits(map[string]string{rateLimitAuthenticate: "10/m"}); limits[rateLimitAuthenticate] != (ratelimit.Limit{Requests: 10, Period: time.Minute}) {
+		t.Errorf("expected the limit of the authentications, got %+v", limits)
+	}
+	if _, err := parseRateLimits(map[string]string{"archive": "10/s"}); err == nil {
+		t.Errorf("expected error for unknown route")
+	}
+	if _, err := parseRateLimits(map[string]string{routeCreate: "10"}); err == nil {
+		t.Errorf("expected error for invalid limit")
+	}
+}
+
+func TestRateLimitsAllow(t *testing.T) {
+	logger := zerolog.New(ioutil.Discard)
+	limits := rateLimits{store: ratelimit.NewMemoryStore(), limits: map[string]ratelimit.Limit{routeCreate: {Requests: 1, Period: time.Minute}}}
+	call := func(route string, ip string, identity *auth.Identity) *httptest.ResponseRecorder {
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodPost, "/api/companies", nil)
+		if identity != nil {
+			request = request.WithContext(auth.WithIdentity(context.Background(), identity))
+		}
+		if limits.allow(response, request, route, ip, &logger) {
+			response.WriteHeader(http.StatusOK)
+		}
+		return response
+	}
+
+	tests := []struct {
+		name               string
+		route              string
+		ip                 string
+		identity           *auth.Identity
+		expectedStatus     int
+		expectedRetryAfter string
+	}{
+		{"+ve:ShouldAllowFirstCall", routeCreate, "192.0.2.1", nil, http.StatusOK, ""},
+		{"-ve:ShouldLimitSecondCallOfSameIP", routeCreate, "192.0.2.1", nil, http.StatusTooManyRequests, "60"},
+		{"+ve:ShouldAllowCallOfOtherIP", routeCreate, "192.0.2.2", nil, http.StatusOK, ""},
+		{"+ve:ShouldAllowCallOfKeyFromLimitedIP", routeCreate, "192.0.2.1", &auth.Identity{Subject: "1", Method: auth.MethodAPIKey}, http.StatusOK, ""},
+		{"-ve:ShouldLimitSecondCallOfSameKey", routeCreate, "192.0.2.3", &auth.Identity{Subject: "1", Method: auth.MethodAPIKey}, http.StatusTooManyRequests, "60"},
+		{"+ve:ShouldNotLimitRouteWithoutLimit", routeList, "192.0.2.1", nil, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := call(tt.route, tt.ip, tt.identity)
+
+			if tt.expectedStatus != response.Code {
+				t.Errorf("expected status %d, got %d", tt.expectedStatus, response.Code)
+			}
+			if retryAfter := response.Header().Get("Retry-After"); tt.expectedRetryAfter != retryAfter {
+				t.Errorf("expected Retry-After %q, got %q", tt.expectedRetryAfter, retryAfter)
+			}
+			if tt.route == routeCreate && (response.Header().Get("RateLimit-Limit") != "1" || response.Header().Get("RateLimit-Remaining") != "0") {
+				t.Errorf("expected RateLimit headers, got %v", response.Header())
+			}
+		})
+	}
+}
+
+// countingAuthenticator counts the requests it authenticates, rejecting their credentials
+type countingAuthenticator struct {
+	calls *int
+}
+
+func (authenticator countingAuthenticator) Authenticate(r *http.Request) (*auth.Identity, error) {
+	*authenticator.calls++
+	return nil, auth.ErrInvalidCredentials
+}
+
+func TestProtectLimitsAuthentications(t *testing.T) {
+	logger := zerolog.New(ioutil.Discard)
+	calls := 0
+	originPolicies, _ := parseOriginPolicies(nil)
+	controller := &companyController{app: &app.App{Logger: &logger}, originPolicies: originPolicies,
+		authentication: authentication{authenticator: countingAuthenticator{calls: &calls}},
+		rateLimits:     rateLimits{store: ratelimit.NewMemoryStore(), limits: map[string]ratelimit.Limit{rateLimitAuthenticate: {Requests: 2, Period: time.Minute}}}}
+	handler := controller.protect(routeGet, model.RoleViewer, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	var statuses []int
+	for attempt := 0; attempt < 3; attempt++ {
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodGet, "/api/companies/1", nil)
+		request.Header.Set("X-API-Key", "xm_guess")
+		handler(response, request)
+		statuses = append(statuses, response.Code)
+	}
+
+	if statuses[0] != http.StatusUnauthorized || statuses[1] != http.StatusUnauthorized || statuses[2] != http.StatusTooManyRequests {
+		t.Errorf("expected the third guess to be limited, got %v", statuses)
+	}
+	if calls != 2 {
+		t.Errorf("expected the limited guess not to be authenticated, got %d authentications", calls)
+	}
+}
@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"net/http"
+	"strings"
+	"unicode/utf8"
+	apiError "xm/error"
+	"xm/model"
+	"xm/repository"
+)
+
+// minSearchTermLength is the minimum length of a search term, shorter terms can't be matched by the trigram index
+const minSearchTermLength = 3
+
+// companySearchResultDTO is a company matching a search, with its relevance and the highlighted matching text
+type companySearchResultDTO struct {
+	companyDTO
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"`
+}
+
+type companySearchDTO struct {
+	Items []companySearchResultDTO `json:"items"`
+}
+
+// parseSearchTerms splits the query into the terms that all have to match, terms too short to be matched are ignored
+func parseSearchTerms(query string) ([]string, error) {
+	var terms []string
+	for _, term := range strings.Fields(query) {
+		if utf8.RuneCountInString(term) >= minSearchTermLength {
+			terms = append(terms, term)
+		}
+	}
+	if len(terms) == 0 {
+		return nil, apiError.NewInvalidFieldsError(map[string]string{"q": apiError.ErrorCodeInvalidValue})
+	}
+	return terms, nil
+}
+
+// search finds the companies whose name, code, country, website or phone contain all the terms of 'q', the most
+// relevant first
+func (controller *companyController) search(w http.ResponseWriter, r *http.Request) {
+	terms, err := parseSearchTerms(r.FormValue("q"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	limit, err := parseLimit(r.FormValue("limit"))
+	if err != nil {
+		respondError(w, err)
+		return
+	}
+
+	uow := repository.NewUnitOfWork(controller.app.DB, true)
+	defer uow.Complete()
+
+	var matches []repository.SearchMatch
+	if err := controller.repository.Search(uow, &model.Company{}, terms, limit, &matches); err != nil {
+		controller.app.Logger.Err(err).Msg("unable to search companies in db")
+		respondError(w, err)
+		return
+	}
+
+	responseDTO := companySearchDTO{Items: []companySearchResultDTO{}}
+	if len(matches) == 0 {
+		respondJSON(w, http.StatusOK, responseDTO)
+		return
+	}
+
+	ids := make([]interface{}, len(matches))
+	for index, match := range matches {
+		ids[index] = match.ID
+	}
+	var companies []model.Company
+	if err := controller.repository.GetAll(uow, &companies, []repository.QueryProcessor{repository.FilterBy("id", repository.In, ids)}); err != nil {
+		controller.app.Logger.Err(err).Msg("unable to get companies from db")
+		respondError(w, err)
+		return
+	}
+
+	companiesByID := make(map[string]*model.Company, len(companies))
+	for index := range companies {
+		companiesByID[companies[index].ID.String()] = &companies[index]
+	}
+	for _, match := range matches {
+		if company, ok := companiesByID[match.ID]; ok {
+			responseDTO.Items = append(responseDTO.Items, companySearchResultDTO{companyDTO: toCompanyDTO(company), Score: match.Score, Snippet: match.Snippet})
+		}
+	}
+
+	respondJSON(w, http.StatusOK, responseDTO)
+	return
+}
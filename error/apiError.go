@@ -1,8 +1,15 @@
 package error
 
+import "time"
+
 // NewAPIClientError creates a new API call error
 func NewAPIClientError(apiURL string, httpStatusCode *int, httpResponseBody *string, err error) APIClientError {
-	return &APIClientErrorImpl{apiURL, httpStatusCode, httpResponseBody, createUnexpectedErrorImpl(ErrorCodeAPICallFailure, err)}
+	return &APIClientErrorImpl{apiURL, httpStatusCode, httpResponseBody, 0, createUnexpectedErrorImpl(ErrorCodeAPICallFailure, err)}
+}
+
+// NewAPIClientErrorWithRetryAfter creates a new API call error of a response asking to retry after a delay
+func NewAPIClientErrorWithRetryAfter(apiURL string, httpStatusCode *int, httpResponseBody *string, retryAfter time.Duration, err error) APIClientError {
+	return &APIClientErrorImpl{apiURL, httpStatusCode, httpResponseBody, retryAfter, createUnexpectedErrorImpl(ErrorCodeAPICallFailure, err)}
 }
 
 // APIClientError represents an database query failure error interface
@@ -11,12 +18,14 @@ type APIClientError interface {
 	GetAPIURL() string
 	GetHTTPStatusCode() *int
 	GetHTTPResponseBody() *string
+	GetRetryAfter() time.Duration
 }
 
 type APIClientErrorImpl struct {
 	apiURL           string
 	httpStatusCode   *int
 	httpResponseBody *string
+	retryAfter       time.Duration
 	unexpectedErrorImpl
 }
 
@@ -34,3 +43,8 @@ func (e *APIClientErrorImpl) GetHTTPStatusCode() *int {
 func (e *APIClientErrorImpl) GetHTTPResponseBody() *string {
 	return e.httpResponseBody
 }
+
+// GetRetryAfter gets the delay the API asked to wait before retrying, 0 when it didn't
+func (e *APIClientErrorImpl) GetRetryAfter() time.Duration {
+	return e.retryAfter
+}
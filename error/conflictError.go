@@ -0,0 +1,21 @@
+package error
+
+import "fmt"
+
+// NewConflictError creates a new conflict error.
+// 'conflictingFields' - map key should be the name of the field and value should be the error code.
+// 'location' - path of the existing resource the request conflicts with, empty when unknown.
+func NewConflictError(conflictingFields map[string]string, location string) ConflictError {
+	return ConflictError{ErrorKey: ErrorCodeConflict, Errors: conflictingFields, Location: location}
+}
+
+// ConflictError is an error indicating that the request conflicts with an existing resource
+type ConflictError struct {
+	ErrorKey string            `json:"errorKey"`
+	Errors   map[string]string `json:"errors"`
+	Location string            `json:"location,omitempty"`
+}
+
+func (e ConflictError) Error() string {
+	return fmt.Sprintf("Error: [%s - %s - %s]", e.ErrorKey, e.Errors, e.Location)
+}
@@ -1,14 +1,26 @@
 package error
 
 const (
+	// ErrorCodeAccessDenied error code for a request denied by the access policy
+	ErrorCodeAccessDenied = "Key_AccessDenied"
+	// ErrorCodeAlreadyExists error code for a field value that is already used by another resource
+	ErrorCodeAlreadyExists = "Key_AlreadyExists"
+	// ErrorCodeConflict error code for a request that conflicts with an existing resource
+	ErrorCodeConflict = "Key_Conflict"
 	// ErrorCodeEmptyRequestBody error code for empty request body
 	ErrorCodeEmptyRequestBody = "Key_EmptyRequestBody"
+	// ErrorCodeForbidden error code for a caller that isn't allowed to make the request
+	ErrorCodeForbidden = "Key_Forbidden"
 	// ErrorCodeInternalError error code for internal error
 	ErrorCodeInternalError = "Key_InternalError"
 	// ErrorCodeInvalidFields error code for invalid fields
 	ErrorCodeInvalidFields = "Key_InvalidFields"
+	// ErrorCodeInvalidCSV error code for invalid CSV
+	ErrorCodeInvalidCSV = "Key_InvalidCSV"
 	// ErrorCodeInvalidJSON error code for invalid JSON
 	ErrorCodeInvalidJSON = "Key_InvalidJSON"
+	// ErrorCodeInvalidPatch error code for a patch that can not be applied
+	ErrorCodeInvalidPatch = "Key_InvalidPatch"
 	// ErrorCodeInvalidRequest error code for invalid request
 	ErrorCodeInvalidRequest = "Key_InvalidRequest"
 	// ErrorCodeInvalidRequestOrigin error code for invalid request origin
@@ -17,6 +29,8 @@ const (
 	ErrorCodeInvalidRequestPayload = "Key_InvalidRequestPayload"
 	// ErrorCodeInvalidValue error code for invalid value
 	ErrorCodeInvalidValue = "Key_InvalidValue"
+	// ErrorCodePreconditionFailed error code for failed If-Match precondition
+	ErrorCodePreconditionFailed = "Key_PreconditionFailed"
 	// ErrorCodeReadWriteFailure error code for io error
 	ErrorCodeReadWriteFailure = "Key_ReadWriteFailure"
 	// ErrorCodeDatabaseFailure error code for database failure
@@ -25,4 +39,18 @@ const (
 	ErrorCodeAPICallFailure = "Key_APICallFailure"
 	// ErrorCodeRequired error code for required fields
 	ErrorCodeRequired = "Key_Required"
+	// ErrorCodeSearchUnavailable error code for full-text search not being supported by the database
+	ErrorCodeSearchUnavailable = "Key_SearchUnavailable"
+	// ErrorCodeTooManyRequests error code for a caller exceeding the rate limit of the route
+	ErrorCodeTooManyRequests = "Key_TooManyRequests"
+	// ErrorCodeUpstreamUnavailable error code for a service the request depends on being unavailable
+	ErrorCodeUpstreamUnavailable = "Key_UpstreamUnavailable"
+	// ErrorCodeUnauthenticated error code for a request that requires an authenticated caller
+	ErrorCodeUnauthenticated = "Key_Unauthenticated"
+	// ErrorCodeUnsupportedMediaType error code for unsupported request content type
+	ErrorCodeUnsupportedMediaType = "Key_UnsupportedMediaType"
+	// ErrorCodeUnknownField error code for unknown fields
+	ErrorCodeUnknownField = "Key_UnknownField"
+	// ErrorCodeUnknownOperator error code for unknown filter operators
+	ErrorCodeUnknownOperator = "Key_UnknownOperator"
 )
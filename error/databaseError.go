@@ -5,6 +5,17 @@ import (
 	"gorm.io/gorm"
 )
 
+// ErrConcurrentModification is the cause of a database error when the record has been modified or removed
+// since it has been read
+var ErrConcurrentModification = errors.New("record has been modified concurrently")
+
+// ErrUniqueConstraint is the cause of a database error when a unique constraint has been violated, the repository
+// recognizes the violations by the error codes of the database drivers
+var ErrUniqueConstraint = errors.New("unique constraint violated")
+
+// ErrSearchUnavailable is the cause of a database error when full-text search isn't supported by the database
+var ErrSearchUnavailable = errors.New("full-text search is unavailable")
+
 // NewDatabaseError creates a new database error
 func NewDatabaseError(err error) DatabaseError {
 	return &databaseErrorImpl{createUnexpectedErrorImpl(ErrorCodeDatabaseFailure, err)}
@@ -14,6 +25,9 @@ func NewDatabaseError(err error) DatabaseError {
 type DatabaseError interface {
 	UnexpectedError
 	IsRecordNotFoundError() bool
+	IsConcurrentModificationError() bool
+	IsUniqueConstraintError() bool
+	IsSearchUnavailableError() bool
 }
 
 type databaseErrorImpl struct {
@@ -23,3 +37,15 @@ type databaseErrorImpl struct {
 func (e *databaseErrorImpl) IsRecordNotFoundError() bool {
 	return errors.Is(e.cause, gorm.ErrRecordNotFound)
 }
+
+func (e *databaseErrorImpl) IsConcurrentModificationError() bool {
+	return errors.Is(e.cause, ErrConcurrentModification)
+}
+
+func (e *databaseErrorImpl) IsSearchUnavailableError() bool {
+	return errors.Is(e.cause, ErrSearchUnavailable)
+}
+
+func (e *databaseErrorImpl) IsUniqueConstraintError() bool {
+	return errors.Is(e.cause, ErrUniqueConstraint)
+}
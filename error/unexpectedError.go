@@ -40,6 +40,11 @@ func (e unexpectedErrorImpl) GetCause() error {
 	return e.cause
 }
 
+// Unwrap returns the cause so that errors.Is and errors.As match it
+func (e unexpectedErrorImpl) Unwrap() error {
+	return e.cause
+}
+
 // GetErrorCode returns the error code
 func (e unexpectedErrorImpl) GetErrorCode() string {
 	return e.errCode
@@ -1,44 +1,395 @@
 package main
 
 import (
-	"github.com/rs/zerolog"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"gorm.io/gorm"
 	"os"
-	"os/signal"
-	"syscall"
+	"path/filepath"
+	"strings"
+	"time"
 	"xm/app"
+	"xm/auth"
 	"xm/client"
 	"xm/controller"
+	"xm/migration"
 	"xm/model"
+	"xm/ratelimit"
 	"xm/repository"
 )
 
 func main() {
-	xmApp := app.New("XM", app.Config{APIPort: "8080", LogLevel: zerolog.DebugLevel})
+	flags := flag.NewFlagSet("xm", flag.ExitOnError)
+	configFile := flags.String("config", os.Getenv("XM_CONFIG"), "YAML or TOML config file (XM_CONFIG)")
+	printConfig := flags.Bool("print-config", false, "print the effective config, with the secrets redacted, and exit")
+	configLoader := app.NewConfigLoader(flags)
+	flags.Parse(os.Args[1:])
 
-	xmApp.DB.AutoMigrate(&model.Company{})
+	config, err := configLoader.Load(*configFile, os.LookupEnv)
+	if err != nil {
+		os.Stderr.WriteString(err.Error() + ", exiting the application!\n")
+		os.Exit(2)
+	}
+	if *printConfig {
+		printed, err := config.Print()
+		if err != nil {
+			os.Stderr.WriteString(err.Error() + "\n")
+			os.Exit(1)
+		}
+		os.Stdout.Write(printed)
+		return
+	}
 
-	// initialize app (initializing everything at start to inject dependency)
-	xmApp.Initialize(getRoutes(xmApp))
+	xmApp := app.New("XM", config)
 
-	// run server in a goroutine so that it doesn't block.
-	go xmApp.Start()
+	args := flags.Args()
+	if len(args) > 0 && args[0] == "migrate" {
+		migrate(xmApp, args[1:])
+		xmApp.Stop()
+		return
+	}
 
-	xmApp.Logger.Info().Msg("XM service started successfully")
+	checkSchema(xmApp)
+	registerCompanySearchIndex(xmApp)
 
-	exitSignal := make(chan os.Signal, 1)
-	<-exitSignal
+	if len(args) > 0 {
+		runCommand(xmApp, args[0], args[1:])
+		xmApp.Stop()
+		return
+	}
 
-	signal.Notify(exitSignal, os.Interrupt, syscall.SIGTERM)
+	// initialize app (initializing everything at start to inject dependency)
+	xmApp.Initialize(getRoutes(xmApp))
 
-	// shutdown the API server, waiting for any outstanding requests to complete
-	xmApp.Stop()
+	// serve until SIGINT or SIGTERM, then drain the requests and release the resources
+	if err := xmApp.Run(); err != nil {
+		xmApp.Logger.Fatal().Err(err).Msg("unable to run the server, exiting the application!")
+	}
 
 	xmApp.Logger.Info().Msg("graceful server shutdown complete, exiting")
-	os.Exit(0)
 }
 
 func getRoutes(xmApp *app.App) []app.RouteSpecifier {
-	ipLocationClient := client.NewIpLocationClient("https://ipapi.co")
+	ipLocationClient := newIPLocationClient(xmApp)
 	companyRepository := repository.NewRepository()
-	return []app.RouteSpecifier{controller.NewCompanyController(xmApp, ipLocationClient, companyRepository)}
+	apiKeyRepository := repository.NewRepository()
+	authenticator := newAuthenticator(xmApp, apiKeyRepository)
+	// the controllers share the buckets, so that an ip has one bucket of authentications
+	rateLimitStore := ratelimit.NewMemoryStore()
+	return []app.RouteSpecifier{
+		controller.NewCompanyController(xmApp, ipLocationClient, companyRepository, authenticator, rateLimitStore),
+		controller.NewAPIKeyController(xmApp, apiKeyRepository, authenticator, rateLimitStore),
+	}
+}
+
+// newAuthenticator creates the authenticator of the API keys, and of the bearer JWTs when a JWKS is configured
+func newAuthenticator(xmApp *app.App, apiKeyRepository repository.Repository) auth.Authenticator {
+	config := xmApp.Config()
+	apiKeyAuthenticator := auth.NewAPIKeyAuthenticator(xmApp.DB, apiKeyRepository)
+	if len(config.JWKS) == 0 {
+		return apiKeyAuthenticator
+	}
+
+	keySet, err := auth.NewJWKSKeySet(config.JWKS, auth.JWKSOptions{})
+	if err != nil {
+		xmApp.Logger.Fatal().Err(err).Msg("unable to load JWKS, exiting the application!")
+	}
+	jwtAuthenticator, err := auth.NewJWTAuthenticator(keySet, auth.JWTOptions{
+		Issuer:      config.JWTIssuer,
+		Audience:    config.JWTAudience,
+		RolesClaim:  config.JWTRolesClaim,
+		RoleMapping: config.JWTRoleMapping,
+	})
+	if err != nil {
+		xmApp.Logger.Fatal().Err(err).Msg("unable to create JWT authenticator, exiting the application!")
+	}
+	return auth.NewChainAuthenticator(apiKeyAuthenticator, jwtAuthenticator)
+}
+
+// newIPLocationClient creates the ip location client of the configured providers, combined with the configured
+// strategy when there are several of them
+func newIPLocationClient(xmApp *app.App) client.IPLocationClient {
+	config := xmApp.Config()
+	var providers []client.IPLocationClient
+	for _, providerName := range config.IPLocationProviders {
+		var provider client.IPLocationClient
+		switch providerName {
+		case client.ProviderIPAPI, "ipapi":
+			provider = client.NewIpLocationClient(config.IPAPIURL)
+			providers = append(providers, withResilience(provider))
+		case client.ProviderIPAPICom:
+			provider = client.NewIPAPIComLocationClient(config.IPAPIComURL)
+			providers = append(providers, withResilience(provider))
+		case client.ProviderIPInfo:
+			provider = client.NewIPInfoLocationClient(config.IPInfoURL, config.IPInfoToken)
+			providers = append(providers, withResilience(provider))
+		case client.ProviderMMDB:
+			ipLocationClient, err := client.NewMMDBLocationClient(config.IPLocationDatabase, time.Minute)
+			if err != nil {
+				xmApp.Logger.Fatal().Err(err).Msg("unable to load ip location database, exiting the application!")
+			}
+			provider = ipLocationClient
+			providers = append(providers, ipLocationClient)
+		default:
+			xmApp.Logger.Fatal().Str("provider", providerName).Msg("unknown ip location provider, exiting the application!")
+		}
+		if config.HealthCheckIPLocation {
+			xmApp.RegisterHealthChecker("ipLocation:"+providerName, ipLocationHealthChecker(provider))
+		}
+	}
+	if len(providers) == 1 {
+		return providers[0]
+	}
+
+	ipLocationClient, err := client.NewChainLocationClient(client.ChainStrategy(config.IPLocationStrategy), providers...)
+	if err != nil {
+		xmApp.Logger.Fatal().Err(err).Msg("unable to create ip location client, exiting the application!")
+	}
+	return ipLocationClient
+}
+
+// healthCheckIP is the public ip looked up to check that an ip location provider is reachable
+const healthCheckIP = "1.1.1.1"
+
+// ipLocationHealthChecker checks that the provider is reachable, its cache and circuit breaker are bypassed. A provider
+// that doesn't know the ip is reachable.
+func ipLocationHealthChecker(provider client.IPLocationClient) app.HealthChecker {
+	return app.HealthCheckerFunc(func(ctx context.Context) error {
+		if _, err := provider.GetLocation(ctx, healthCheckIP); err != nil && !errors.Is(err, client.ErrLocationNotFound) {
+			return err
+		}
+		return nil
+	})
+}
+
+// withResilience decorates the client of a remote provider with caching, retries and a circuit breaker
+func withResilience(ipLocationClient client.IPLocationClient) client.IPLocationClient {
+	ipLocationClient = client.NewResilientLocationClient(ipLocationClient, client.ResilienceOptions{})
+	return client.NewCircuitBreakerLocationClient(ipLocationClient, client.CircuitBreakerOptions{})
+}
+
+// splitList splits a comma separated list, ignoring empty elements
+func splitList(list string) []string {
+	var elements []string
+	for _, element := range strings.Split(list, ",") {
+		if element = strings.TrimSpace(element); len(element) > 0 {
+			elements = append(elements, element)
+		}
+	}
+	return elements
+}
+
+// checkSchema refuses to start when migrations are pending, unless they are applied at startup, or when the unique
+// index on company codes, created by the migrations, doesn't have the configured scope
+func checkSchema(xmApp *app.App) {
+	if xmApp.Config().MigrateOnStartup {
+		migrateUp(xmApp, 0, nil)
+	} else if err := migration.Check(xmApp.DB, migration.Migrations()); err != nil {
+		xmApp.Logger.Fatal().Err(err).Msg("unable to start with the current schema, run xm migrate up, exiting the application!")
+	}
+
+	scope := model.CompanyCodeScope(xmApp.Config().CompanyCodeUniquePerCountry)
+	found, err := repository.HasUniqueIndex(xmApp.DB, &model.Company{}, scope...)
+	if err != nil {
+		xmApp.Logger.Fatal().Err(err).Msg("unable to check company code index, exiting the application!")
+	}
+	if !found {
+		xmApp.Logger.Fatal().Strs("scope", scope).Msg("the company code index doesn't match companyCodeUniquePerCountry, apply or revert migration scope_company_code_index_to_country, exiting the application!")
+	}
+}
+
+// registerCompanySearchIndex enables the full-text search of companies, search is unavailable when the migrations
+// haven't created its index
+func registerCompanySearchIndex(xmApp *app.App) {
+	if err := repository.RegisterSearchIndex(xmApp.DB, &model.Company{}, model.CompanySearchColumns()...); err != nil {
+		xmApp.Logger.Warn().Err(err).Msg("unable to register company search index, search is unavailable (build with -tags sqlite_fts5 and run xm reindex)")
+	}
+}
+
+// runCommand runs the administrative command instead of starting the API server
+func runCommand(xmApp *app.App, command string, args []string) {
+	switch command {
+	case "purge":
+		purge(xmApp, args)
+	case "import":
+		importCompanies(xmApp, args)
+	case "reindex":
+		reindex(xmApp)
+	case "create-key":
+		createAPIKey(xmApp, args)
+	default:
+		xmApp.Logger.Fatal().Str("command", command).Msg("unknown command, exiting the application!")
+	}
+}
+
+// migrate runs the migration subcommand: up, down, status or create
+func migrate(xmApp *app.App, args []string) {
+	if len(args) == 0 {
+		xmApp.Logger.Fatal().Msg("missing migrate subcommand: up, down, status or create, exiting the application!")
+	}
+
+	flags := flag.NewFlagSet("migrate "+args[0], flag.ExitOnError)
+	switch args[0] {
+	case "up":
+		steps := flags.Int("steps", 0, "number of pending migrations to apply, 0 applies all of them")
+		include := flags.String("include", "", "comma separated names of the optional migrations to apply")
+		flags.Parse(args[1:])
+		var names []string
+		if len(*include) > 0 {
+			names = strings.Split(*include, ",")
+		}
+		migrateUp(xmApp, *steps, names)
+	case "down":
+		steps := flags.Int("steps", 1, "number of applied migrations to revert")
+		flags.Parse(args[1:])
+		reverted, err := migration.Down(xmApp.DB, migration.Migrations(), *steps)
+		for _, m := range reverted {
+			xmApp.Logger.Info().Int64("version", m.Version).Str("name", m.Name).Msg("reverted migration")
+		}
+		if err != nil {
+			xmApp.Logger.Fatal().Err(err).Msg("unable to revert migrations, exiting the application!")
+		}
+	case "status":
+		flags.Parse(args[1:])
+		statuses, err := migration.GetStatus(xmApp.DB, migration.Migrations())
+		if err != nil {
+			xmApp.Logger.Fatal().Err(err).Msg("unable to get migration status, exiting the application!")
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Optional {
+				state = "optional"
+			}
+			if status.AppliedAt != nil {
+				state = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			if status.Unknown {
+				state += " (unknown)"
+			}
+			os.Stdout.WriteString(fmt.Sprintf("%d_%s\t%s\n", status.Version, status.Name, state))
+		}
+	case "create":
+		name := flags.String("name", "", "name of the migration, e.g. add_company_email")
+		dir := flags.String("dir", "migration", "directory of the migrations")
+		flags.Parse(args[1:])
+		file, err := migration.Create(*dir, *name, time.Now())
+		if err != nil {
+			xmApp.Logger.Fatal().Err(err).Msg("unable to create migration, exiting the application!")
+		}
+		xmApp.Logger.Info().Str("file", file).Msg("created migration")
+	default:
+		xmApp.Logger.Fatal().Str("subcommand", args[0]).Msg("unknown migrate subcommand, exiting the application!")
+	}
+}
+
+// migrateUp applies the pending migrations and the optional migrations that are included, at most steps of them unless
+// steps is 0
+func migrateUp(xmApp *app.App, steps int, include []string) {
+	migrations, err := migration.Include(migration.Migrations(), include...)
+	if err != nil {
+		xmApp.Logger.Fatal().Err(err).Msg("unable to include optional migrations, exiting the application!")
+	}
+	applied, err := migration.Up(xmApp.DB, migrations, steps)
+	for _, m := range applied {
+		xmApp.Logger.Info().Int64("version", m.Version).Str("name", m.Name).Msg("applied migration")
+	}
+	if err != nil {
+		xmApp.Logger.Fatal().Err(err).Msg("unable to apply migrations, exiting the application!")
+	}
+}
+
+// purge permanently removes companies that have been soft deleted before the retention window
+func purge(xmApp *app.App, args []string) {
+	flags := flag.NewFlagSet("purge", flag.ExitOnError)
+	retention := flags.Duration("retention", xmApp.Config().PurgeRetention, "how long deleted companies are kept before they are purged (purgeRetention)")
+	flags.Parse(args)
+
+	uow := repository.NewUnitOfWork(xmApp.DB, false)
+	defer uow.Complete()
+
+	var purged int64
+	deletedBefore := time.Now().Add(-*retention)
+	if err := repository.NewRepository().Purge(uow, &model.Company{}, deletedBefore, &purged); err != nil {
+		xmApp.Logger.Fatal().Err(err).Msg("unable to purge deleted companies, exiting the application!")
+	}
+
+	uow.Commit()
+
+	xmApp.Logger.Info().Int64("purged", purged).Time("deletedBefore", deletedBefore).Msg("purged deleted companies")
+}
+
+// reindex rebuilds the full-text search index of companies, it's created when the migrations have been applied by a
+// build without FTS5
+func reindex(xmApp *app.App) {
+	if err := xmApp.DB.Transaction(func(tx *gorm.DB) error {
+		return repository.CreateSearchIndex(tx, &model.Company{}, model.CompanySearchColumns()...)
+	}); err != nil {
+		xmApp.Logger.Fatal().Err(err).Msg("unable to rebuild company search index, exiting the application!")
+	}
+	xmApp.Logger.Info().Msg("rebuilt company search index")
+}
+
+// createAPIKey creates an API key and prints it, e.g. the first key with the keys:admin scope
+func createAPIKey(xmApp *app.App, args []string) {
+	flags := flag.NewFlagSet("create-key", flag.ExitOnError)
+	name := flags.String("name", "", "name of the client the key is for")
+	scopes := flags.String("scopes", "", "comma separated scopes: "+strings.Join(model.Scopes(), ", "))
+	role := flags.String("role", "", "role of the key, required: "+strings.Join(model.Roles(), ", "))
+	flags.Parse(args)
+
+	apiKey, key, err := model.NewAPIKey(*name, *role, splitList(*scopes))
+	if err != nil {
+		xmApp.Logger.Fatal().Err(err).Msg("invalid api key, exiting the application!")
+	}
+
+	uow := repository.NewUnitOfWork(xmApp.DB, false)
+	defer uow.Complete()
+
+	if err := repository.NewRepository().Add(uow, apiKey); err != nil {
+		xmApp.Logger.Fatal().Err(err).Msg("unable to create api key, exiting the application!")
+	}
+
+	uow.Commit()
+
+	xmApp.Logger.Info().Str("id", apiKey.ID.String()).Str("name", apiKey.Name).Str("scopes", apiKey.Scopes).Str("role", apiKey.Role).Msg("created api key")
+	os.Stdout.WriteString(key + "\n")
+}
+
+// importCompanies imports companies from a CSV or NDJSON file and prints the report
+func importCompanies(xmApp *app.App, args []string) {
+	flags := flag.NewFlagSet("import", flag.ExitOnError)
+	file := flags.String("file", "", "path of the CSV or NDJSON file to import")
+	format := flags.String("format", "", "format of the file: csv or ndjson (default: derived from the file extension)")
+	dryRun := flags.Bool("dry-run", false, "only validate the companies without writing anything")
+	upsert := flags.Bool("upsert", false, "update the existing company with the same code instead of failing")
+	flags.Parse(args)
+
+	if len(*format) == 0 {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*file)), ".")
+	}
+
+	reader, err := os.Open(*file)
+	if err != nil {
+		xmApp.Logger.Fatal().Err(err).Msg("unable to open import file, exiting the application!")
+	}
+	defer reader.Close()
+
+	companyController := controller.NewCompanyController(xmApp, nil, repository.NewRepository(), nil, nil)
+	report, err := companyController.Import(reader, controller.ImportOptions{Format: *format, DryRun: *dryRun, Upsert: *upsert})
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if report.Rows > 0 {
+		encoder.Encode(report)
+	}
+	if err != nil {
+		xmApp.Logger.Fatal().Err(err).Msg("unable to import companies, exiting the application!")
+	}
+
+	if report.Failed > 0 {
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,41 @@
+package migration
+
+import (
+	uuid "github.com/satori/go.uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// company is the companies table as created by this migration, later migrations must not change it
+type company struct {
+	ID        uuid.UUID      `gorm:"type:varchar(36);primary_key;"`
+	CreatedAt time.Time      `gorm:"column:createdOn"`
+	UpdatedAt time.Time      `gorm:"column:modifiedOn"`
+	DeletedAt gorm.DeletedAt `gorm:"column:deletedOn;index"`
+	Name      string         `gorm:"column:name"`
+	Code      string         `gorm:"column:code;size:255"`
+	Country   string         `gorm:"column:country;size:255"`
+	Website   string         `gorm:"column:website"`
+	Phone     string         `gorm:"column:phone"`
+	Version   uint           `gorm:"column:version;not null;default:1"`
+}
+
+// TableName implements interface schema.Tabler
+func (company) TableName() string {
+	return "companies"
+}
+
+func init() {
+	Register(Migration{
+		Version: 20261018000000,
+		Name:    "create_companies",
+		// the table is auto migrated rather than created, so that the databases created before the migrations are
+		// adopted as they are
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().AutoMigrate(&company{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&company{})
+		},
+	})
+}
@@ -0,0 +1,38 @@
+package migration
+
+import (
+	uuid "github.com/satori/go.uuid"
+	"gorm.io/gorm"
+	"time"
+)
+
+// apiKey is the api_keys table as created by this migration, later migrations must not change it
+type apiKey struct {
+	ID        uuid.UUID  `gorm:"type:varchar(36);primary_key;"`
+	CreatedAt time.Time  `gorm:"column:createdOn"`
+	UpdatedAt time.Time  `gorm:"column:modifiedOn"`
+	RevokedAt *time.Time `gorm:"column:revokedOn"`
+	Name      string     `gorm:"column:name"`
+	Prefix    string     `gorm:"column:prefix"`
+	Hash      string     `gorm:"column:hash;size:64;uniqueIndex"`
+	Scopes    string     `gorm:"column:scopes"`
+	Role      string     `gorm:"column:role"`
+}
+
+// TableName implements interface schema.Tabler
+func (apiKey) TableName() string {
+	return "api_keys"
+}
+
+func init() {
+	Register(Migration{
+		Version: 20261018000100,
+		Name:    "create_api_keys",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().AutoMigrate(&apiKey{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&apiKey{})
+		},
+	})
+}
@@ -0,0 +1,25 @@
+package migration
+
+import (
+	"gorm.io/gorm"
+)
+
+func init() {
+	Register(Migration{
+		Version: 20261019000000,
+		Name:    "backfill_api_key_roles",
+		// the keys created before the roles passed every role check, they're given the role matching their scopes:
+		// admin for keys:admin, editor for companies:write, viewer otherwise
+		Up: func(tx *gorm.DB) error {
+			return tx.Exec(`UPDATE api_keys SET role = CASE
+				WHEN scopes LIKE '%keys:admin%' THEN 'admin'
+				WHEN scopes LIKE '%companies:write%' THEN 'editor'
+				ELSE 'viewer' END
+				WHERE role IS NULL OR role = ''`).Error
+		},
+		// the keys that have been backfilled aren't known anymore, so their roles are kept
+		Down: func(tx *gorm.DB) error {
+			return nil
+		},
+	})
+}
@@ -0,0 +1,49 @@
+package migration
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"strings"
+)
+
+// createCompanyCodeIndex creates the named unique index on the columns of the companies that haven't been soft
+// deleted, when it doesn't exist. MySQL doesn't support partial indexes, NULL is indexed for the soft deleted companies
+// instead, which never conflicts (functional key parts require MySQL 8.0.13).
+func createCompanyCodeIndex(tx *gorm.DB, name string, columns ...string) error {
+	if tx.Migrator().HasIndex("companies", name) {
+		return nil
+	}
+	quoted := make([]string, len(columns))
+	for position, column := range columns {
+		quoted[position] = tx.Statement.Quote(column)
+	}
+
+	sql := "CREATE UNIQUE INDEX %s ON %s (%s) WHERE %s IS NULL"
+	if tx.Dialector.Name() == "mysql" {
+		sql = "CREATE UNIQUE INDEX %s ON %s (%s, (IF(%s IS NULL, 1, NULL)))"
+	}
+	return tx.Exec(fmt.Sprintf(sql, tx.Statement.Quote(name), tx.Statement.Quote("companies"), strings.Join(quoted, ", "),
+		tx.Statement.Quote("deletedOn"))).Error
+}
+
+// dropCompanyCodeIndex drops the named unique index on the companies, if it exists
+func dropCompanyCodeIndex(tx *gorm.DB, name string) error {
+	if !tx.Migrator().HasIndex("companies", name) {
+		return nil
+	}
+	return tx.Migrator().DropIndex("companies", name)
+}
+
+func init() {
+	Register(Migration{
+		Version: 20261020000000,
+		Name:    "create_company_code_index",
+		// the index was created on boot by the previous versions, it's only created when it doesn't exist
+		Up: func(tx *gorm.DB) error {
+			return createCompanyCodeIndex(tx, "uq_companies_code", "code")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropCompanyCodeIndex(tx, "uq_companies_code")
+		},
+	})
+}
@@ -0,0 +1,42 @@
+package migration
+
+import (
+	"gorm.io/gorm"
+)
+
+func init() {
+	Register(Migration{
+		Version: 20261020000100,
+		Name:    "create_company_search_index",
+		// search is unavailable when the database isn't SQLite or the driver has been built without FTS5, the index
+		// isn't created then, xm reindex creates it once the service is built with FTS5
+		Up: func(tx *gorm.DB) error {
+			if tx.Dialector.Name() != "sqlite" {
+				return nil
+			}
+			var fts5 bool
+			if err := tx.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5).Error; err != nil || !fts5 {
+				return err
+			}
+			return execAll(tx,
+				`CREATE VIRTUAL TABLE IF NOT EXISTS "companies_search"
+					USING fts5("id" UNINDEXED, "name", "code", "country", "website", "phone", tokenize = 'trigram')`,
+				`DELETE FROM "companies_search"`,
+				`INSERT INTO "companies_search" ("id", "name", "code", "country", "website", "phone")
+					SELECT "id", "name", "code", "country", "website", "phone" FROM "companies" WHERE "deletedOn" IS NULL`)
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable("companies_search")
+		},
+	})
+}
+
+// execAll executes the statements in order until one fails
+func execAll(tx *gorm.DB, statements ...string) error {
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package migration
+
+import (
+	"gorm.io/gorm"
+)
+
+func init() {
+	Register(Migration{
+		Version: 20261020000200,
+		Name:    "scope_company_code_index_to_country",
+		// the codes of the companies are unique per country with companyCodeUniquePerCountry, the migration is applied
+		// along with the setting: xm migrate up -include scope_company_code_index_to_country
+		Optional: true,
+		Up: func(tx *gorm.DB) error {
+			if err := dropCompanyCodeIndex(tx, "uq_companies_code"); err != nil {
+				return err
+			}
+			return createCompanyCodeIndex(tx, "uq_companies_country_code", "country", "code")
+		},
+		// it fails when companies of different countries share a code
+		Down: func(tx *gorm.DB) error {
+			if err := dropCompanyCodeIndex(tx, "uq_companies_country_code"); err != nil {
+				return err
+			}
+			return createCompanyCodeIndex(tx, "uq_companies_code", "code")
+		},
+	})
+}
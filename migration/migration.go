@@ -0,0 +1,267 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// ErrSchemaBehind is the error of a database where some migrations haven't been applied
+var ErrSchemaBehind = errors.New("the database schema is behind")
+
+// versionLayout formats the time a migration is created at into its version
+const versionLayout = "20060102150405"
+
+// namePattern matches the names of the migrations, which are part of their file name
+var namePattern = regexp.MustCompile(`^[a-z0-9_]+$`)
+
+// Migration changes the schema, or the data, of the database from one version to the next one and back
+type Migration struct {
+	// Version orders the migrations, it's the time the migration has been created at, e.g. 20261018093000
+	Version int64
+	Name    string
+	// Optional migrations are only applied once they're included (see Include), e.g. the schema change that a setting
+	// of the service requires
+	Optional bool
+	Up       func(tx *gorm.DB) error
+	Down     func(tx *gorm.DB) error
+}
+
+// schemaMigration is a migration that has been applied
+type schemaMigration struct {
+	Version   int64     `gorm:"column:version;primaryKey;autoIncrement:false"`
+	Name      string    `gorm:"column:name;size:255"`
+	AppliedAt time.Time `gorm:"column:appliedOn"`
+}
+
+// TableName implements interface schema.Tabler
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+var registered = map[int64]Migration{}
+
+// Register adds a migration to the migrations of the service, it's called by the init function of the migration
+func Register(migration Migration) {
+	if _, found := registered[migration.Version]; found {
+		panic(fmt.Sprintf("migration %d registered twice", migration.Version))
+	}
+	registered[migration.Version] = migration
+}
+
+// Migrations returns the registered migrations ordered by version
+func Migrations() []Migration {
+	migrations := make([]Migration, 0, len(registered))
+	for _, migration := range registered {
+		migrations = append(migrations, migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations
+}
+
+// Include returns the migrations where the optional migrations with the specified names are applied like the others
+func Include(migrations []Migration, names ...string) ([]Migration, error) {
+	included := make([]Migration, len(migrations))
+	copy(included, migrations)
+	for _, name := range names {
+		found := false
+		for index := range included {
+			if included[index].Name == name {
+				included[index].Optional = false
+				found = true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("unknown migration %q", name)
+		}
+	}
+	return included, nil
+}
+
+// Status is the state of a migration in the database
+type Status struct {
+	Version int64
+	Name    string
+	// AppliedAt is nil when the migration is pending
+	AppliedAt *time.Time
+	// Optional reports whether the migration is only applied once it's included
+	Optional bool
+	// Unknown reports whether the migration has been applied by a more recent version of the service
+	Unknown bool
+}
+
+// GetStatus returns the status of the migrations, and of the applied migrations that are unknown, ordered by version
+func GetStatus(db *gorm.DB, migrations []Migration) ([]Status, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var statuses []Status
+	for _, migration := range migrations {
+		status := Status{Version: migration.Version, Name: migration.Name, Optional: migration.Optional}
+		if appliedMigration, found := applied[migration.Version]; found {
+			status.AppliedAt = &appliedMigration.AppliedAt
+			delete(applied, migration.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, appliedMigration := range applied {
+		appliedAt := appliedMigration.AppliedAt
+		statuses = append(statuses, Status{Version: appliedMigration.Version, Name: appliedMigration.Name, AppliedAt: &appliedAt, Unknown: true})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// appliedMigrations returns the migrations that have been applied keyed by version, the schema_migrations table is
+// created when it doesn't exist
+func appliedMigrations(db *gorm.DB) (map[int64]schemaMigration, error) {
+	if err := db.AutoMigrate(&schemaMigration{}); err != nil {
+		return nil, fmt.Errorf("unable to create schema_migrations: %w", err)
+	}
+	var schemaMigrations []schemaMigration
+	if err := db.Find(&schemaMigrations).Error; err != nil {
+		return nil, err
+	}
+	applied := map[int64]schemaMigration{}
+	for _, schemaMigration := range schemaMigrations {
+		applied[schemaMigration.Version] = schemaMigration
+	}
+	return applied, nil
+}
+
+// Up applies the pending migrations in order, except the optional ones, at most steps of them unless steps is 0. Every
+// migration is applied in its own transaction, the migrations are applied until one fails. MySQL commits DDL
+// statements implicitly, so a migration failing there may be partially applied without being recorded: migrations have
+// to be idempotent, e.g. create an index only when it doesn't exist, so that they can be applied again once the failure
+// has been fixed.
+func Up(db *gorm.DB, migrations []Migration, steps int) ([]Migration, error) {
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, migration := range migrations {
+		if _, found := applied[migration.Version]; found || migration.Optional {
+			continue
+		}
+		if steps > 0 && len(done) == steps {
+			break
+		}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&schemaMigration{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("unable to apply migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Down reverts the last applied migrations in reverse order, steps of them. A migration that has been applied by a
+// more recent version of the service can't be reverted. Like Up, a migration failing on MySQL may be partially reverted.
+func Down(db *gorm.DB, migrations []Migration, steps int) ([]Migration, error) {
+	statuses, err := GetStatus(db, migrations)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := map[int64]Migration{}
+	for _, migration := range migrations {
+		byVersion[migration.Version] = migration
+	}
+
+	var done []Migration
+	for index := len(statuses) - 1; index >= 0 && len(done) < steps; index-- {
+		status := statuses[index]
+		if status.AppliedAt == nil {
+			continue
+		}
+		if status.Unknown {
+			return done, fmt.Errorf("unable to revert migration %d_%s: it's unknown to this version", status.Version, status.Name)
+		}
+		migration := byVersion[status.Version]
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := migration.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&schemaMigration{Version: migration.Version}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("unable to revert migration %d_%s: %w", migration.Version, migration.Name, err)
+		}
+		done = append(done, migration)
+	}
+	return done, nil
+}
+
+// Check fails with ErrSchemaBehind when some migrations, except the optional ones, haven't been applied to the database
+func Check(db *gorm.DB, migrations []Migration) error {
+	statuses, err := GetStatus(db, migrations)
+	if err != nil {
+		return err
+	}
+	pending := 0
+	for _, status := range statuses {
+		if status.AppliedAt == nil && !status.Optional {
+			pending++
+		}
+	}
+	if pending > 0 {
+		return fmt.Errorf("%w: %d pending migrations", ErrSchemaBehind, pending)
+	}
+	return nil
+}
+
+// Create writes the skeleton of a new migration into the directory and returns its file, the version of the
+// migration is the time it's created at
+func Create(dir string, name string, now time.Time) (string, error) {
+	if !namePattern.MatchString(name) {
+		return "", fmt.Errorf("invalid migration name %q: only lower case letters, digits and _ are allowed", name)
+	}
+	version := now.UTC().Format(versionLayout)
+	file := filepath.Join(dir, version+"_"+name+".go")
+	if _, err := os.Stat(file); err == nil {
+		return "", fmt.Errorf("migration %s already exists", file)
+	}
+
+	versionNumber, _ := strconv.ParseInt(version, 10, 64)
+	source := fmt.Sprintf(migrationTemplate, versionNumber, name)
+	if err := ioutil.WriteFile(file, []byte(source), 0644); err != nil {
+		return "", err
+	}
+	return file, nil
+}
+
+// migrationTemplate is the skeleton of a new migration, formatted with its version and name
+const migrationTemplate = `package migration
+
+import (
+	"gorm.io/gorm"
+)
+
+func init() {
+	Register(Migration{
+		Version: %d,
+		Name:    %q,
+		Up: func(tx *gorm.DB) error {
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			return nil
+		},
+	})
+}
+`
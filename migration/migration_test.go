@@ -0,0 +1,312 @@
+package migration
+
+import (
+	"errors"
+	uuid "github.com/satori/go.uuid"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testMigrations creates a table per migration and records the order they're applied and reverted in
+func testMigrations(log *[]string, versions ...int64) []Migration {
+	var migrations []Migration
+	for _, version := range versions {
+		version := version
+		table := "table_" + time.Unix(version, 0).UTC().Format("150405")
+		migrations = append(migrations, Migration{
+			Version: version,
+			Name:    "create_" + table,
+			Up: func(tx *gorm.DB) error {
+				*log = append(*log, "up "+table)
+				return tx.Exec("CREATE TABLE " + table + " (id INTEGER)").Error
+			},
+			Down: func(tx *gorm.DB) error {
+				*log = append(*log, "down "+table)
+				return tx.Exec("DROP TABLE " + table).Error
+			},
+		})
+	}
+	return migrations
+}
+
+func openTestDB(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "migration.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("unable to open database: %v", err)
+	}
+	return db
+}
+
+func TestUpAndDown(t *testing.T) {
+	tests := []struct {
+		name        string
+		run         func(db *gorm.DB, migrations []Migration) ([]Migration, error)
+		expectedLog []string
+		pending     int
+	}{
+		{"+ve:ShouldApplyAllMigrationsInOrder", func(db *gorm.DB, migrations []Migration) ([]Migration, error) {
+			return Up(db, migrations, 0)
+		}, []string{"up table_000001", "up table_000002", "up table_000003"}, 0},
+		{"+ve:ShouldApplyStepsMigrations", func(db *gorm.DB, migrations []Migration) ([]Migration, error) {
+			return Up(db, migrations, 2)
+		}, []string{"up table_000001", "up table_000002"}, 1},
+		{"+ve:ShouldNotReapplyMigrations", func(db *gorm.DB, migrations []Migration) ([]Migration, error) {
+			Up(db, migrations, 1)
+			return Up(db, migrations, 0)
+		}, []string{"up table_000001", "up table_000002", "up table_000003"}, 0},
+		{"+ve:ShouldRevertLastMigration", func(db *gorm.DB, migrations []Migration) ([]Migration, error) {
+			Up(db, migrations, 0)
+			return Down(db, migrations, 1)
+		}, []string{"up table_000001", "up table_000002", "up table_000003", "down table_000003"}, 1},
+		{"+ve:ShouldRevertMigrationsInReverseOrder", func(db *gorm.DB, migrations []Migration) ([]Migration, error) {
+			Up(db, migrations, 2)
+			return Down(db, migrations, 5)
+		}, []string{"up table_000001", "up table_000002", "down table_000002", "down table_000001"}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			var log []string
+			migrations := testMigrations(&log, 1, 2, 3)
+
+			if _, err := tt.run(db, migrations); err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+
+			if !reflect.DeepEqual(tt.expectedLog, log) {
+				t.Errorf("expected migrations %v, got %v", tt.expectedLog, log)
+			}
+			err := Check(db, migrations)
+			if tt.pending == 0 && err != nil {
+				t.Errorf("expected no pending migration, got %v", err)
+			}
+			if tt.pending > 0 && (!errors.Is(err, ErrSchemaBehind) || !strings.Contains(err.Error(), "pending")) {
+				t.Errorf("expected %d pending migrations, got %v", tt.pending, err)
+			}
+		})
+	}
+}
+
+func TestOptionalMigration(t *testing.T) {
+	db := openTestDB(t)
+	var log []string
+	migrations := testMigrations(&log, 1, 2, 3)
+	migrations[1].Optional = true
+
+	if _, err := Up(db, migrations, 0); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if err := Check(db, migrations); err != nil {
+		t.Errorf("expected the optional migration not to be pending, got %v", err)
+	}
+	statuses, _ := GetStatus(db, migrations)
+	if statuses[1].AppliedAt != nil || !statuses[1].Optional {
+		t.Errorf("expected the optional migration not to be applied, got %+v", statuses[1])
+	}
+
+	included, err := Include(migrations, "create_table_000002")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if _, err := Up(db, included, 0); err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	expectedLog := []string{"up table_000001", "up table_000003", "up table_000002"}
+	if !reflect.DeepEqual(expectedLog, log) {
+		t.Errorf("expected migrations %v, got %v", expectedLog, log)
+	}
+	if !migrations[1].Optional {
+		t.Errorf("expected the migrations to be left unchanged")
+	}
+	if _, err := Include(migrations, "unknown"); err == nil || !strings.Contains(err.Error(), "unknown migration") {
+		t.Errorf("expected an unknown migration not to be included, got %v", err)
+	}
+}
+
+func TestUpFailure(t *testing.T) {
+	db := openTestDB(t)
+	var log []string
+	migrations := testMigrations(&log, 1, 2)
+	migrations[1].Up = func(tx *gorm.DB) error {
+		tx.Exec("CREATE TABLE partial (id INTEGER)")
+		return errors.New("broken")
+	}
+
+	applied, err := Up(db, migrations, 0)
+
+	if err == nil || !strings.Contains(err.Error(), "unable to apply migration 2_create_table_000002: broken") {
+		t.Errorf("expected the migration to fail, got %v", err)
+	}
+	if len(applied) != 1 {
+		t.Errorf("expected the first migration to be applied, got %v", applied)
+	}
+	if db.Migrator().HasTable("partial") {
+		t.Errorf("expected the failed migration to be rolled back")
+	}
+	statuses, _ := GetStatus(db, migrations)
+	if statuses[0].AppliedAt == nil || statuses[1].AppliedAt != nil {
+		t.Errorf("expected only the first migration to be applied, got %+v", statuses)
+	}
+}
+
+func TestStatusOfUnknownMigration(t *testing.T) {
+	db := openTestDB(t)
+	var log []string
+	Up(db, testMigrations(&log, 1, 2), 0)
+	migrations := testMigrations(&log, 1)
+
+	statuses, err := GetStatus(db, migrations)
+
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	if len(statuses) != 2 || statuses[0].Unknown || !statuses[1].Unknown || statuses[1].Version != 2 {
+		t.Errorf("expected the second migration to be unknown, got %+v", statuses)
+	}
+	if err := Check(db, migrations); err != nil {
+		t.Errorf("expected a newer schema to be accepted, got %v", err)
+	}
+	if _, err := Down(db, migrations, 1); err == nil || !strings.Contains(err.Error(), "unknown") {
+		t.Errorf("expected the unknown migration not to be reverted, got %v", err)
+	}
+}
+
+func TestMigrationsOrder(t *testing.T) {
+	migrations := Migrations()
+	if len(migrations) == 0 {
+		t.Fatalf("expected registered migrations")
+	}
+	for index := 1; index < len(migrations); index++ {
+		if migrations[index-1].Version >= migrations[index].Version {
+			t.Errorf("expected migrations ordered by version, got %d before %d", migrations[index-1].Version, migrations[index].Version)
+		}
+	}
+
+	db := openTestDB(t)
+	if _, err := Up(db, migrations, 0); err != nil {
+		t.Fatalf("unable to apply migrations: %v", err)
+	}
+	if _, err := Down(db, migrations, len(migrations)); err != nil {
+		t.Fatalf("unable to revert migrations: %v", err)
+	}
+	if db.Migrator().HasTable("companies") || db.Migrator().HasTable("api_keys") {
+		t.Errorf("expected the tables to be dropped")
+	}
+}
+
+func TestCreate(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC)
+	tests := []struct {
+		name         string
+		migration    string
+		expectedFile string
+		expectedErr  string
+	}{
+		{"+ve:ShouldWriteMigration", "add_company_email", "20261018093000_add_company_email.go", ""},
+		{"-ve:ShouldFailWhenNameIsInvalid", "Add company email", "", "invalid migration name"},
+		{"-ve:ShouldFailWhenNameIsEmpty", "", "", "invalid migration name"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+
+			file, err := Create(dir, tt.migration, now)
+
+			if len(tt.expectedErr) > 0 {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Errorf("expected error %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if filepath.Base(file) != tt.expectedFile {
+				t.Errorf("expected file %s, got %s", tt.expectedFile, file)
+			}
+			source, _ := ioutil.ReadFile(file)
+			if !strings.Contains(string(source), "Version: 20261018093000,") || !strings.Contains(string(source), `Name:    "add_company_email",`) {
+				t.Errorf("expected the version and name in the migration, got\n%s", source)
+			}
+			if _, err := Create(dir, tt.migration, now); err == nil {
+				t.Errorf("expected existing migration not to be overwritten")
+			}
+		})
+	}
+}
+
+func TestBackfillAPIKeyRoles(t *testing.T) {
+	db := openTestDB(t)
+	var migrations []Migration
+	for _, migration := range Migrations() {
+		if migration.Version < 20261019000000 {
+			migrations = append(migrations, migration)
+		}
+	}
+	if _, err := Up(db, migrations, 0); err != nil {
+		t.Fatalf("unable to apply migrations: %v", err)
+	}
+	keys := map[string]string{"keys": "keys:admin companies:read", "writer": "companies:read companies:write", "reader": "companies:read", "editor": "companies:read"}
+	for id, scopes := range keys {
+		role := ""
+		if id == "editor" {
+			role = "editor"
+		}
+		db.Create(&apiKey{ID: uuid.NewV4(), Name: id, Hash: id, Scopes: scopes, Role: role})
+	}
+
+	if _, err := Up(db, Migrations(), 0); err != nil {
+		t.Fatalf("unable to apply migrations: %v", err)
+	}
+
+	expectedRoles := map[string]string{"keys": "admin", "writer": "editor", "reader": "viewer", "editor": "editor"}
+	for name, expectedRole := range expectedRoles {
+		var backfilled apiKey
+		db.First(&backfilled, "name = ?", name)
+		if backfilled.Role != expectedRole {
+			t.Errorf("expected key %s to have role %s, got %q", name, expectedRole, backfilled.Role)
+		}
+	}
+}
+
+func TestCreateCompanyCodeIndex(t *testing.T) {
+	tests := []struct {
+		name            string
+		include         []string
+		expectedIndex   string
+		unexpectedIndex string
+	}{
+		{"+ve:ShouldCreateGlobalIndex", nil, "uq_companies_code", "uq_companies_country_code"},
+		{"+ve:ShouldScopeIndexToCountry", []string{"scope_company_code_index_to_country"}, "uq_companies_country_code", "uq_companies_code"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := openTestDB(t)
+			migrations, err := Include(Migrations(), tt.include...)
+			if err != nil {
+				t.Fatalf("unable to include migrations: %v", err)
+			}
+			if _, err := Up(db, migrations, 0); err != nil {
+				t.Fatalf("unable to apply migrations: %v", err)
+			}
+
+			if !db.Migrator().HasIndex("companies", tt.expectedIndex) || db.Migrator().HasIndex("companies", tt.unexpectedIndex) {
+				t.Errorf("expected only index %s to exist", tt.expectedIndex)
+			}
+
+			if _, err := Down(db, migrations, len(migrations)); err != nil {
+				t.Fatalf("unable to revert migrations: %v", err)
+			}
+			if db.Migrator().HasTable("companies") {
+				t.Errorf("expected the tables to be dropped")
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package model
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	uuid "github.com/satori/go.uuid"
+	"strings"
+	"time"
+	apiError "xm/error"
+)
+
+// scopes granted to API keys
+const (
+	// ScopeCompaniesRead allows to get, list, search and export companies
+	ScopeCompaniesRead = "companies:read"
+	// ScopeCompaniesWrite allows to create, update, delete, restore and import companies
+	ScopeCompaniesWrite = "companies:write"
+	// ScopeKeysAdmin allows to manage the API keys
+	ScopeKeysAdmin = "keys:admin"
+)
+
+// apiKeyPrefix starts every API key, so that leaked keys are easy to recognize
+const apiKeyPrefix = "xm_"
+
+// APIKey authenticates the calls of a client, only the hash of the key is stored
+type APIKey struct {
+	ID        uuid.UUID  `gorm:"type:varchar(36);primary_key;"`
+	CreatedAt time.Time  `gorm:"column:createdOn"`
+	UpdatedAt time.Time  `gorm:"column:modifiedOn"`
+	RevokedAt *time.Time `gorm:"column:revokedOn"`
+	Name      string     `gorm:"column:name"`
+	// Prefix is the beginning of the key, which identifies it without disclosing it
+	Prefix string `gorm:"column:prefix"`
+	Hash   string `gorm:"column:hash;size:64;uniqueIndex"`
+	// Scopes are separated by spaces
+	Scopes string `gorm:"column:scopes"`
+	// Role of the key, the keys created before the roles have been backfilled with the role of their scopes
+	Role string `gorm:"column:role"`
+}
+
+// Scopes returns all the scopes an API key can be granted
+func Scopes() []string {
+	return []string{ScopeCompaniesRead, ScopeCompaniesWrite, ScopeKeysAdmin}
+}
+
+// NewAPIKey creates new API key, the key itself is returned as only its hash is kept
+func NewAPIKey(name string, role string, scopes []string) (*APIKey, string, error) {
+	if err := validateAPIKey(name, role, scopes); err != nil {
+		return nil, "", err
+	}
+
+	apiKey := &APIKey{ID: uuid.NewV4(), Name: name, Scopes: strings.Join(scopes, " "), Role: role}
+	key, err := apiKey.Rotate()
+	if err != nil {
+		return nil, "", err
+	}
+	return apiKey, key, nil
+}
+
+// Rotate replaces the key, the previous key stops working
+func (apiKey *APIKey) Rotate() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	key := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	apiKey.Prefix = key[:len(apiKeyPrefix)+8]
+	apiKey.Hash = HashAPIKey(key)
+	return key, nil
+}
+
+// Revoke revokes the key, it can't authenticate anymore
+func (apiKey *APIKey) Revoke(now time.Time) {
+	apiKey.RevokedAt = &now
+}
+
+// IsRevoked reports whether the key has been revoked
+func (apiKey *APIKey) IsRevoked() bool {
+	return apiKey.RevokedAt != nil
+}
+
+// ScopeList returns the scopes granted to the key
+func (apiKey *APIKey) ScopeList() []string {
+	return strings.Fields(apiKey.Scopes)
+}
+
+// RoleList returns the role of the key, nil when it has none
+func (apiKey *APIKey) RoleList() []string {
+	if len(apiKey.Role) == 0 {
+		return nil
+	}
+	return []string{apiKey.Role}
+}
+
+// HashAPIKey returns the hash an API key is stored and looked up with. Keys are random, so a fast hash is enough.
+func HashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+func validateAPIKey(name string, role string, scopes []string) error {
+	if len(name) == 0 {
+		return apiError.NewInvalidFieldsError(map[string]string{"name": apiError.ErrorCodeRequired})
+	}
+	if len(scopes) == 0 {
+		return apiError.NewInvalidFieldsError(map[string]string{"scopes": apiError.ErrorCodeRequired})
+	}
+	for _, scope := range scopes {
+		if !isScopeValid(scope) {
+			return apiError.NewInvalidFieldsError(map[string]string{"scopes": apiError.ErrorCodeInvalidValue})
+		}
+	}
+	if len(role) == 0 {
+		return apiError.NewInvalidFieldsError(map[string]string{"role": apiError.ErrorCodeRequired})
+	}
+	if roleRank(role) < 0 {
+		return apiError.NewInvalidFieldsError(map[string]string{"role": apiError.ErrorCodeInvalidValue})
+	}
+	return nil
+}
+
+func isScopeValid(scope string) bool {
+	for _, valid := range Scopes() {
+		if scope == valid {
+			return true
+		}
+	}
+	return false
+}
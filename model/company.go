@@ -2,6 +2,7 @@ package model
 
 import (
 	uuid "github.com/satori/go.uuid"
+	"gorm.io/gorm"
 	"net/url"
 	"regexp"
 	"time"
@@ -10,15 +11,30 @@ import (
 
 // Company contains the data of each company
 type Company struct {
-	ID        uuid.UUID  `gorm:"type:varchar(36);primary_key;"`
-	CreatedAt time.Time  `gorm:"column:createdOn"`
-	UpdatedAt time.Time  `gorm:"column:modifiedOn"`
-	DeletedAt *time.Time `sql:"index" gorm:"column:deletedOn"`
-	Name      string     `gorm:"column:name"`
-	Code      string     `gorm:"column:code"`
-	Country   string     `gorm:"column:country"`
-	Website   string     `gorm:"column:website"`
-	Phone     string     `gorm:"column:phone"`
+	ID        uuid.UUID      `gorm:"type:varchar(36);primary_key;"`
+	CreatedAt time.Time      `gorm:"column:createdOn"`
+	UpdatedAt time.Time      `gorm:"column:modifiedOn"`
+	DeletedAt gorm.DeletedAt `gorm:"column:deletedOn;index"`
+	Name      string         `gorm:"column:name"`
+	Code      string         `gorm:"column:code;size:255"`
+	Country   string         `gorm:"column:country;size:255"`
+	Website   string         `gorm:"column:website"`
+	Phone     string         `gorm:"column:phone"`
+	Version   uint           `gorm:"column:version;not null;default:1"`
+}
+
+// CompanyCodeScope returns the columns within which the code of a company has to be unique,
+// globally or per country
+func CompanyCodeScope(perCountry bool) []string {
+	if perCountry {
+		return []string{"country", "code"}
+	}
+	return []string{"code"}
+}
+
+// CompanySearchColumns returns the columns matched by the full-text search of companies
+func CompanySearchColumns() []string {
+	return []string{"name", "code", "country", "website", "phone"}
 }
 
 // NewCompany creates new company
@@ -34,6 +50,7 @@ func NewCompany(name, code, country, website, phone string) (*Company, error) {
 		Country: country,
 		Website: website,
 		Phone:   phone,
+		Version: 1,
 	}, nil
 }
 
@@ -51,6 +68,16 @@ func (company *Company) Update(name, code, country, website, phone string) error
 	return nil
 }
 
+// GetVersion returns the version of the company, it is incremented on every modification
+func (company *Company) GetVersion() uint {
+	return company.Version
+}
+
+// SetVersion sets the version of the company
+func (company *Company) SetVersion(version uint) {
+	company.Version = version
+}
+
 func validateCompany(name, code, country, website, phone string) error {
 	if len(name) == 0 {
 		return apiError.NewInvalidFieldsError(map[string]string{"name": apiError.ErrorCodeRequired})
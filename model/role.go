@@ -0,0 +1,32 @@
+package model
+
+// roles of the callers, each role includes the roles before it
+const (
+	// RoleViewer allows to get, list, search and export companies
+	RoleViewer = "viewer"
+	// RoleEditor allows to create, update, patch, restore and import companies as well
+	RoleEditor = "editor"
+	// RoleAdmin allows to delete companies and to manage the API keys as well
+	RoleAdmin = "admin"
+)
+
+// Roles returns all the roles, from the least to the most privileged
+func Roles() []string {
+	return []string{RoleViewer, RoleEditor, RoleAdmin}
+}
+
+// RoleIncludes reports whether the role includes the required role, e.g. admin includes editor and viewer
+func RoleIncludes(role string, required string) bool {
+	requiredRank := roleRank(required)
+	return requiredRank >= 0 && roleRank(role) >= requiredRank
+}
+
+// roleRank returns the position of the role in Roles, -1 when the role is unknown
+func roleRank(role string) int {
+	for rank, valid := range Roles() {
+		if role == valid {
+			return rank
+		}
+	}
+	return -1
+}
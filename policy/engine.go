@@ -0,0 +1,116 @@
+package policy
+
+import (
+	"fmt"
+	"github.com/rs/zerolog"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Engine evaluates the access policy of the requests
+type Engine interface {
+	Evaluate(request Request) (Decision, error)
+}
+
+// NewEngine returns an Engine evaluating a fixed policy
+func NewEngine(policy *Policy) Engine {
+	return policy
+}
+
+// NewFileEngine returns an Engine evaluating the policy of a YAML (.yaml, .yml) or JSON (.json) file. The file is
+// reloaded when its modification time or size changes, which is checked at most once every reloadInterval, so that
+// the policy can be updated without a restart. A file that can't be reloaded is logged and the previous policy is kept.
+func NewFileEngine(path string, reloadInterval time.Duration, routes []string, logger *zerolog.Logger) (Engine, error) {
+	engine := &fileEngineImpl{Path: path, ReloadInterval: reloadInterval, Routes: routes, Logger: logger}
+	if err := engine.load(); err != nil {
+		return nil, err
+	}
+	return engine, nil
+}
+
+type fileEngineImpl struct {
+	Path           string
+	ReloadInterval time.Duration
+	Routes         []string
+	Logger         *zerolog.Logger
+
+	mutex     sync.RWMutex
+	policy    *Policy
+	modTime   time.Time
+	size      int64
+	checkedAt time.Time
+}
+
+// Evaluate evaluates the request against the policy of the file
+func (impl *fileEngineImpl) Evaluate(request Request) (Decision, error) {
+	impl.reloadIfChanged()
+
+	impl.mutex.RLock()
+	policy := impl.policy
+	impl.mutex.RUnlock()
+	return policy.Evaluate(request)
+}
+
+// reloadIfChanged reloads the policy when the file has changed since it has been loaded, or can't be opened anymore
+func (impl *fileEngineImpl) reloadIfChanged() {
+	impl.mutex.RLock()
+	due := time.Since(impl.checkedAt) >= impl.ReloadInterval
+	impl.mutex.RUnlock()
+	if !due {
+		return
+	}
+
+	info, err := os.Stat(impl.Path)
+
+	impl.mutex.Lock()
+	impl.checkedAt = time.Now()
+	changed := err != nil || !info.ModTime().Equal(impl.modTime) || info.Size() != impl.size
+	impl.mutex.Unlock()
+
+	if !changed {
+		return
+	}
+	if err := impl.load(); err != nil {
+		impl.Logger.Err(err).Str("path", impl.Path).Msg("unable to reload access policy, keeping the previous policy")
+		return
+	}
+	impl.Logger.Info().Str("path", impl.Path).Msg("reloaded access policy")
+}
+
+// load parses the file, its format is given by the extension
+func (impl *fileEngineImpl) load() error {
+	var format Format
+	switch strings.ToLower(filepath.Ext(impl.Path)) {
+	case ".json":
+		format = FormatJSON
+	case ".yaml", ".yml":
+		format = FormatYAML
+	default:
+		return fmt.Errorf("unknown policy format of %s, expected .yaml, .yml or .json", impl.Path)
+	}
+
+	info, err := os.Stat(impl.Path)
+	if err != nil {
+		return fmt.Errorf("unable to open policy: %w", err)
+	}
+	data, err := ioutil.ReadFile(impl.Path)
+	if err != nil {
+		return fmt.Errorf("unable to read policy: %w", err)
+	}
+	policy, err := Parse(data, format, impl.Routes)
+	if err != nil {
+		return fmt.Errorf("unable to load policy %s: %w", impl.Path, err)
+	}
+
+	impl.mutex.Lock()
+	defer impl.mutex.Unlock()
+	impl.policy = policy
+	impl.modTime = info.ModTime()
+	impl.size = info.Size()
+	impl.checkedAt = time.Now()
+	return nil
+}
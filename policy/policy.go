@@ -0,0 +1,349 @@
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"net"
+	"strings"
+	"time"
+)
+
+// reasons of the denials
+const (
+	// ReasonDeniedIP the ip of the caller is in the denied ips of the rule
+	ReasonDeniedIP = "deniedIP"
+	// ReasonDeniedCountry the country of the caller is in the denied countries of the rule
+	ReasonDeniedCountry = "deniedCountry"
+	// ReasonNotAllowed neither the ip nor the country of the caller are in the allowed ips and countries of the rule
+	ReasonNotAllowed = "notAllowed"
+	// ReasonOutsideTimeWindow the request has been made outside of the time windows of the rule
+	ReasonOutsideTimeWindow = "outsideTimeWindow"
+	// ReasonUnauthenticated the rule requires an authenticated caller
+	ReasonUnauthenticated = "unauthenticated"
+)
+
+// Format is the format of a policy file
+type Format string
+
+const (
+	// FormatJSON is the format of .json policy files
+	FormatJSON Format = "json"
+	// FormatYAML is the format of .yaml and .yml policy files
+	FormatYAML Format = "yaml"
+)
+
+// Policy is the ordered list of access rules, a request is allowed when none of the rules applying to it deny it
+type Policy struct {
+	Rules []*Rule `json:"rules" yaml:"rules"`
+}
+
+// Rule restricts the access to routes. Deny lists always win, when there are allowed ips or countries the caller must
+// match at least one of them.
+type Rule struct {
+	// Name is reported when the rule denies a request
+	Name string `json:"name" yaml:"name"`
+	// Routes are the names of the routes the rule applies to, all of them when empty
+	Routes []string `json:"routes" yaml:"routes"`
+	// Methods are the http methods the rule applies to, all of them when empty
+	Methods        []string     `json:"methods" yaml:"methods"`
+	AllowCountries []string     `json:"allowCountries" yaml:"allowCountries"`
+	DenyCountries  []string     `json:"denyCountries" yaml:"denyCountries"`
+	AllowIPs       []string     `json:"allowIPs" yaml:"allowIPs"`
+	DenyIPs        []string     `json:"denyIPs" yaml:"denyIPs"`
+	TimeWindows    []TimeWindow `json:"timeWindows" yaml:"timeWindows"`
+	RequireAuth    bool         `json:"requireAuth" yaml:"requireAuth"`
+
+	allowNetworks []*net.IPNet
+	denyNetworks  []*net.IPNet
+}
+
+// TimeWindow is a daily window of time, e.g. 08:00-18:00 on weekdays. A window ending before it starts spans midnight.
+type TimeWindow struct {
+	// Days are the days of the week (mon, tue, ...) of the window, every day when empty
+	Days  []string `json:"days" yaml:"days"`
+	Start string   `json:"start" yaml:"start"`
+	End   string   `json:"end" yaml:"end"`
+	// TimeZone is the IANA time zone of the window (default: UTC)
+	TimeZone string `json:"timeZone" yaml:"timeZone"`
+
+	days     map[time.Weekday]bool
+	start    int
+	end      int
+	location *time.Location
+}
+
+// Request is the part of a http request the rules are evaluated against
+type Request struct {
+	Route         string
+	Method        string
+	IP            net.IP
+	Time          time.Time
+	Authenticated bool
+	// Country looks up the country of the caller, only when a rule needs it. When nil the country conditions are skipped.
+	Country func() (string, error)
+}
+
+// Decision is the outcome of the evaluation of a policy, the rule and the reason are set on denial
+type Decision struct {
+	Allowed bool
+	Rule    string
+	Reason  string
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Parse parses and validates a policy, the rules can only name the known routes
+func Parse(data []byte, format Format, routes []string) (*Policy, error) {
+	policy := &Policy{}
+	switch format {
+	case FormatJSON:
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(policy); err != nil {
+			return nil, fmt.Errorf("invalid policy: %w", err)
+		}
+	case FormatYAML:
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(policy); err != nil {
+			return nil, fmt.Errorf("invalid policy: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown policy format: %q", format)
+	}
+
+	if err := policy.compile(routes); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// New returns the policy of the rules, validated against the known routes
+func New(routes []string, rules ...*Rule) (*Policy, error) {
+	policy := &Policy{Rules: rules}
+	if err := policy.compile(routes); err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// compile validates the rules and parses their networks and time windows
+func (policy *Policy) compile(routes []string) error {
+	knownRoutes := map[string]bool{}
+	for _, route := range routes {
+		knownRoutes[route] = true
+	}
+
+	names := map[string]bool{}
+	for index, rule := range policy.Rules {
+		if len(rule.Name) == 0 {
+			return fmt.Errorf("rule %d has no name", index)
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("duplicate rule: %q", rule.Name)
+		}
+		names[rule.Name] = true
+
+		for _, route := range rule.Routes {
+			if !knownRoutes[route] {
+				return fmt.Errorf("rule %q: unknown route: %q", rule.Name, route)
+			}
+		}
+		for index, method := range rule.Methods {
+			rule.Methods[index] = strings.ToUpper(method)
+		}
+		for index, country := range rule.AllowCountries {
+			rule.AllowCountries[index] = strings.ToUpper(country)
+		}
+		for index, country := range rule.DenyCountries {
+			rule.DenyCountries[index] = strings.ToUpper(country)
+		}
+
+		var err error
+		if rule.allowNetworks, err = ParseNetworks(rule.AllowIPs); err != nil {
+			return fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+		if rule.denyNetworks, err = ParseNetworks(rule.DenyIPs); err != nil {
+			return fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+		for index := range rule.TimeWindows {
+			if err := rule.TimeWindows[index].compile(); err != nil {
+				return fmt.Errorf("rule %q: %w", rule.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// compile parses the days, the bounds and the time zone of the window
+func (window *TimeWindow) compile() error {
+	window.days = map[time.Weekday]bool{}
+	for _, day := range window.Days {
+		weekday, ok := weekdays[strings.ToLower(day)]
+		if !ok {
+			return fmt.Errorf("invalid day: %q", day)
+		}
+		window.days[weekday] = true
+	}
+
+	var err error
+	if window.start, err = parseClock(window.Start); err != nil {
+		return err
+	}
+	if window.end, err = parseClock(window.End); err != nil {
+		return err
+	}
+	if window.location, err = time.LoadLocation(window.TimeZone); err != nil {
+		return fmt.Errorf("invalid time zone: %q", window.TimeZone)
+	}
+	return nil
+}
+
+// contains reports whether the time is within the window
+func (window *TimeWindow) contains(t time.Time) bool {
+	t = t.In(window.location)
+	if len(window.days) > 0 && !window.days[t.Weekday()] {
+		return false
+	}
+	minute := t.Hour()*60 + t.Minute()
+	if window.end < window.start {
+		return minute >= window.start || minute < window.end
+	}
+	return minute >= window.start && minute < window.end
+}
+
+// Evaluate evaluates the rules applying to the request in order and denies it on the first rule that doesn't hold.
+// The error is the one of the country lookup.
+func (policy *Policy) Evaluate(request Request) (Decision, error) {
+	country, looked := "", false
+	lookUpCountry := func() (string, bool, error) {
+		if request.Country == nil {
+			return "", false, nil
+		}
+		if !looked {
+			var err error
+			if country, err = request.Country(); err != nil {
+				return "", false, err
+			}
+			looked = true
+		}
+		return country, true, nil
+	}
+
+	for _, rule := range policy.Rules {
+		if !rule.applies(request) {
+			continue
+		}
+		reason, err := rule.evaluate(request, lookUpCountry)
+		if err != nil {
+			return Decision{}, err
+		}
+		if len(reason) > 0 {
+			return Decision{Rule: rule.Name, Reason: reason}, nil
+		}
+	}
+	return Decision{Allowed: true}, nil
+}
+
+// applies reports whether the rule applies to the route and the method of the request
+func (rule *Rule) applies(request Request) bool {
+	return (len(rule.Routes) == 0 || contains(rule.Routes, request.Route)) &&
+		(len(rule.Methods) == 0 || contains(rule.Methods, request.Method))
+}
+
+// evaluate returns the reason the rule denies the request, empty when it is allowed
+func (rule *Rule) evaluate(request Request, lookUpCountry func() (string, bool, error)) (string, error) {
+	if containsIP(rule.denyNetworks, request.IP) {
+		return ReasonDeniedIP, nil
+	}
+	if rule.RequireAuth && !request.Authenticated {
+		return ReasonUnauthenticated, nil
+	}
+	if len(rule.TimeWindows) > 0 && !rule.withinTimeWindows(request.Time) {
+		return ReasonOutsideTimeWindow, nil
+	}
+
+	allowedIP := containsIP(rule.allowNetworks, request.IP)
+	needsCountry := len(rule.DenyCountries) > 0 || (len(rule.AllowCountries) > 0 && !allowedIP)
+	country, known := "", false
+	if needsCountry {
+		var err error
+		if country, known, err = lookUpCountry(); err != nil {
+			return "", err
+		}
+	}
+
+	if known && contains(rule.DenyCountries, country) {
+		return ReasonDeniedCountry, nil
+	}
+	if len(rule.AllowIPs) == 0 && len(rule.AllowCountries) == 0 {
+		return "", nil
+	}
+	if allowedIP || (len(rule.AllowCountries) > 0 && (!known || contains(rule.AllowCountries, country))) {
+		return "", nil
+	}
+	return ReasonNotAllowed, nil
+}
+
+// withinTimeWindows reports whether the time is within any of the windows of the rule
+func (rule *Rule) withinTimeWindows(t time.Time) bool {
+	for index := range rule.TimeWindows {
+		if rule.TimeWindows[index].contains(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseNetworks parses the CIDRs, a single ip is a network of its own
+func ParseNetworks(cidrs []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid ip: %q", cidr)
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ip: %q", cidr)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// parseClock parses a time of the day (HH:MM) into minutes since midnight
+func parseClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time: %q", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if ip != nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,164 @@
+package policy
+
+import (
+	"bytes"
+	"errors"
+	"github.com/rs/zerolog"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testRoutes = []string{"create", "list", "delete"}
+
+const testPolicyYAML = `
+rules:
+  - name: blocklist
+    denyIPs: [203.0.113.0/24]
+    denyCountries: [kp]
+  - name: office-hours
+    routes: [delete]
+    timeWindows:
+      - days: [mon, tue, wed, thu, fri]
+        start: "08:00"
+        end: "18:00"
+        timeZone: Europe/Nicosia
+  - name: origin
+    routes: [create, delete]
+    methods: [post, delete]
+    allowCountries: [CY]
+    allowIPs: [10.0.0.0/8]
+  - name: authenticated
+    routes: [list]
+    requireAuth: true
+`
+
+func TestEvaluate(t *testing.T) {
+	policy, err := Parse([]byte(testPolicyYAML), FormatYAML, testRoutes)
+	if err != nil {
+		t.Fatalf("unable to parse policy: %v", err)
+	}
+	// a wednesday, 12:00 in Nicosia
+	noon := time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC)
+	lookUpErr := errors.New("connection refused")
+
+	tests := []struct {
+		name             string
+		request          Request
+		country          string
+		countryErr       error
+		expectedDecision Decision
+		expectedErr      error
+		expectedLookups  int
+	}{
+		{"+ve:ShouldAllowCallerFromAllowedCountry", Request{Route: "create", Method: "POST", IP: net.ParseIP("1.1.1.1"), Time: noon}, "CY", nil, Decision{Allowed: true}, nil, 1},
+		{"+ve:ShouldAllowCallerFromAllowedIP", Request{Route: "create", Method: "POST", IP: net.ParseIP("10.1.2.3"), Time: noon}, "US", nil, Decision{Allowed: true}, nil, 1},
+		{"+ve:ShouldNotApplyRuleToOtherMethods", Request{Route: "create", Method: "GET", IP: net.ParseIP("1.1.1.1"), Time: noon}, "US", nil, Decision{Allowed: true}, nil, 1},
+		{"-ve:ShouldDenyCallerFromOtherCountry", Request{Route: "create", Method: "POST", IP: net.ParseIP("1.1.1.1"), Time: noon}, "US", nil, Decision{Rule: "origin", Reason: ReasonNotAllowed}, nil, 1},
+		{"-ve:ShouldDenyCallerFromDeniedIP", Request{Route: "create", Method: "POST", IP: net.ParseIP("203.0.113.9"), Time: noon}, "CY", nil, Decision{Rule: "blocklist", Reason: ReasonDeniedIP}, nil, 0},
+		{"-ve:ShouldDenyCallerFromDeniedCountry", Request{Route: "list", Method: "GET", IP: net.ParseIP("1.1.1.1"), Time: noon, Authenticated: true}, "KP", nil, Decision{Rule: "blocklist", Reason: ReasonDeniedCountry}, nil, 1},
+		{"-ve:ShouldDenyOutsideTimeWindow", Request{Route: "delete", Method: "DELETE", IP: net.ParseIP("1.1.1.1"), Time: noon.Add(8 * time.Hour)}, "CY", nil, Decision{Rule: "office-hours", Reason: ReasonOutsideTimeWindow}, nil, 1},
+		{"-ve:ShouldDenyOnDayOutsideTimeWindow", Request{Route: "delete", Method: "DELETE", IP: net.ParseIP("1.1.1.1"), Time: noon.AddDate(0, 0, 3)}, "CY", nil, Decision{Rule: "office-hours", Reason: ReasonOutsideTimeWindow}, nil, 1},
+		{"-ve:ShouldDenyUnauthenticatedCaller", Request{Route: "list", Method: "GET", IP: net.ParseIP("1.1.1.1"), Time: noon}, "CY", nil, Decision{Rule: "authenticated", Reason: ReasonUnauthenticated}, nil, 1},
+		{"-ve:ShouldDenyCallerWithoutCountry", Request{Route: "create", Method: "POST", IP: net.ParseIP("1.1.1.1"), Time: noon}, "", nil, Decision{Rule: "origin", Reason: ReasonNotAllowed}, nil, 1},
+		{"-ve:ShouldReturnLookupError", Request{Route: "create", Method: "POST", IP: net.ParseIP("1.1.1.1"), Time: noon}, "", lookUpErr, Decision{}, lookUpErr, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookups := 0
+			tt.request.Country = func() (string, error) {
+				lookups++
+				return tt.country, tt.countryErr
+			}
+
+			decision, err := policy.Evaluate(tt.request)
+
+			if tt.expectedErr != err {
+				t.Errorf("expected error %v, got %v", tt.expectedErr, err)
+			}
+			if tt.expectedDecision != decision {
+				t.Errorf("expected decision %+v, got %+v", tt.expectedDecision, decision)
+			}
+			if tt.expectedLookups != lookups {
+				t.Errorf("expected %d lookups, got %d", tt.expectedLookups, lookups)
+			}
+		})
+	}
+
+	skipped := Request{Route: "create", Method: "POST", IP: net.ParseIP("1.1.1.1"), Time: noon}
+	if decision, err := policy.Evaluate(skipped); err != nil || !decision.Allowed {
+		t.Errorf("expected country conditions to be skipped without country lookup, got %+v (%v)", decision, err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name   string
+		data   string
+		format Format
+		valid  bool
+	}{
+		{"+ve:ShouldParseJSON", `{"rules": [{"name": "origin", "routes": ["create"], "allowCountries": ["CY"]}]}`, FormatJSON, true},
+		{"+ve:ShouldParseTimeWindowSpanningMidnight", `{"rules": [{"name": "night", "timeWindows": [{"start": "22:00", "end": "06:00"}]}]}`, FormatJSON, true},
+		{"-ve:ShouldFailWhenUnknownField", `{"rules": [{"name": "origin", "allowCountry": ["CY"]}]}`, FormatJSON, false},
+		{"-ve:ShouldFailWhenUnknownRoute", `{"rules": [{"name": "origin", "routes": ["update"]}]}`, FormatJSON, false},
+		{"-ve:ShouldFailWhenRuleHasNoName", `{"rules": [{"allowCountries": ["CY"]}]}`, FormatJSON, false},
+		{"-ve:ShouldFailWhenDuplicateRule", `{"rules": [{"name": "origin"}, {"name": "origin"}]}`, FormatJSON, false},
+		{"-ve:ShouldFailWhenInvalidIP", `{"rules": [{"name": "origin", "allowIPs": ["10.0.0.0/33"]}]}`, FormatJSON, false},
+		{"-ve:ShouldFailWhenInvalidTime", `{"rules": [{"name": "origin", "timeWindows": [{"start": "8am", "end": "18:00"}]}]}`, FormatJSON, false},
+		{"-ve:ShouldFailWhenInvalidDay", `{"rules": [{"name": "origin", "timeWindows": [{"days": ["monday"], "start": "08:00", "end": "18:00"}]}]}`, FormatJSON, false},
+		{"-ve:ShouldFailWhenInvalidTimeZone", `{"rules": [{"name": "origin", "timeWindows": [{"start": "08:00", "end": "18:00", "timeZone": "Mars/Olympus"}]}]}`, FormatJSON, false},
+		{"-ve:ShouldFailWhenInvalidYAML", "rules:\n  - name: [origin", FormatYAML, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.data), tt.format, testRoutes)
+			if tt.valid != (err == nil) {
+				t.Errorf("expected valid %v, got %v", tt.valid, err)
+			}
+		})
+	}
+}
+
+func TestFileEngineReloads(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.json")
+	writePolicy := func(data string, modTime time.Time) {
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatalf("unable to write policy: %v", err)
+		}
+		os.Chtimes(path, modTime, modTime)
+	}
+	request := Request{Route: "create", Method: "POST", IP: net.ParseIP("1.1.1.1"), Country: func() (string, error) { return "CY", nil }}
+
+	writePolicy(`{"rules": [{"name": "origin", "allowCountries": ["CY"]}]}`, time.Now().Add(-time.Hour))
+	var log bytes.Buffer
+	logger := zerolog.New(&log)
+	engine, err := NewFileEngine(path, 0, testRoutes, &logger)
+	if err != nil {
+		t.Fatalf("unable to load policy: %v", err)
+	}
+	if decision, _ := engine.Evaluate(request); !decision.Allowed {
+		t.Errorf("expected request to be allowed, got %+v", decision)
+	}
+
+	writePolicy(`{"rules": [{"name": "lockdown", "allowCountries": ["GR"]}]}`, time.Now())
+	if decision, _ := engine.Evaluate(request); decision.Rule != "lockdown" {
+		t.Errorf("expected request to be denied by the reloaded policy, got %+v", decision)
+	}
+
+	writePolicy(`{"rules": [`, time.Now().Add(time.Hour))
+	if decision, _ := engine.Evaluate(request); decision.Rule != "lockdown" {
+		t.Errorf("expected invalid policy to be ignored, got %+v", decision)
+	}
+	if !strings.Contains(log.String(), `"level":"error"`) || !strings.Contains(log.String(), `"path":"`+path+`"`) {
+		t.Errorf("expected the failed reload to be logged with the path, got %s", log.String())
+	}
+
+	if _, err := NewFileEngine(filepath.Join(t.TempDir(), "policy.toml"), 0, testRoutes, &logger); err == nil {
+		t.Errorf("expected error for unknown policy format")
+	}
+}
@@ -0,0 +1,62 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit is a token bucket of Requests tokens, refilled evenly over the Period, so that bursts of up to Requests
+// requests are allowed
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// periods are the units of the periods of the limits
+var periods = map[string]time.Duration{"s": time.Second, "m": time.Minute, "h": time.Hour}
+
+// ParseLimit parses a limit written as <requests>/<period>, the period being s, m or h, e.g. 100/m
+func ParseLimit(value string) (Limit, error) {
+	index := strings.Index(value, "/")
+	if index < 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: expected <requests>/<s|m|h>", value)
+	}
+	requests, err := strconv.Atoi(strings.TrimSpace(value[:index]))
+	if err != nil || requests <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: the requests must be a positive number", value)
+	}
+	period, ok := periods[strings.TrimSpace(value[index+1:])]
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: the period must be s, m or h", value)
+	}
+	return Limit{Requests: requests, Period: period}, nil
+}
+
+// rate returns the tokens added to the bucket per second
+func (limit Limit) rate() float64 {
+	return float64(limit.Requests) / limit.Period.Seconds()
+}
+
+// Result is the outcome of taking a token from a bucket
+type Result struct {
+	// Allowed reports whether a token has been taken
+	Allowed bool
+	// Limit is the size of the bucket
+	Limit int
+	// Remaining are the tokens left in the bucket
+	Remaining int
+	// Reset is the delay until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is the delay until a token is available, 0 when the request is allowed
+	RetryAfter time.Duration
+}
+
+// Store keeps the token buckets of the clients, taking a token must be atomic so that stores can be shared by
+// several instances of the service
+type Store interface {
+	// Take takes a token from the bucket of the key, which is full when it doesn't exist yet
+	Take(ctx context.Context, key string, limit Limit) (Result, error)
+}
@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often the buckets that have been refilled are dropped from memory
+const sweepInterval = time.Minute
+
+// NewMemoryStore returns a Store keeping the buckets in memory, which is only suitable to a single instance of the
+// service. The buckets that are full again are dropped, as they are the same as the buckets that don't exist.
+func NewMemoryStore() Store {
+	return &memoryStoreImpl{buckets: map[string]*bucket{}, now: time.Now}
+}
+
+type memoryStoreImpl struct {
+	mutex   sync.Mutex
+	buckets map[string]*bucket
+	sweptAt time.Time
+	now     func() time.Time
+}
+
+// bucket is the state of a token bucket
+type bucket struct {
+	limit     Limit
+	tokens    float64
+	updatedAt time.Time
+}
+
+// refill adds the tokens of the time elapsed since the last update
+func (bucket *bucket) refill(now time.Time) {
+	elapsed := now.Sub(bucket.updatedAt).Seconds()
+	if elapsed > 0 {
+		bucket.tokens = math.Min(float64(bucket.limit.Requests), bucket.tokens+elapsed*bucket.limit.rate())
+		bucket.updatedAt = now
+	}
+}
+
+// Take implements interface Store
+func (impl *memoryStoreImpl) Take(ctx context.Context, key string, limit Limit) (Result, error) {
+	impl.mutex.Lock()
+	defer impl.mutex.Unlock()
+
+	now := impl.now()
+	impl.sweep(now)
+
+	current, found := impl.buckets[key]
+	if !found || current.limit != limit {
+		current = &bucket{limit: limit, tokens: float64(limit.Requests), updatedAt: now}
+		impl.buckets[key] = current
+	}
+	current.refill(now)
+
+	result := Result{Limit: limit.Requests}
+	if current.tokens >= 1 {
+		current.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - current.tokens) / limit.rate())
+	}
+	result.Remaining = int(current.tokens)
+	result.Reset = seconds((float64(limit.Requests) - current.tokens) / limit.rate())
+	return result, nil
+}
+
+// sweep drops the buckets that are full again, at most every sweepInterval, the mutex must be held
+func (impl *memoryStoreImpl) sweep(now time.Time) {
+	if now.Sub(impl.sweptAt) < sweepInterval {
+		return
+	}
+	impl.sweptAt = now
+	for key, bucket := range impl.buckets {
+		bucket.refill(now)
+		if bucket.tokens >= float64(bucket.limit.Requests) {
+			delete(impl.buckets, key)
+		}
+	}
+}
+
+// seconds returns the duration of a number of seconds
+func seconds(value float64) time.Duration {
+	return time.Duration(value * float64(time.Second))
+}
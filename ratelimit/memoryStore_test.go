@@ -0,0 +1,75 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		name          string
+		value         string
+		expectedLimit Limit
+		expectedErr   bool
+	}{
+		{"+ve:ShouldParseRequestsPerSecond", "10/s", Limit{Requests: 10, Period: time.Second}, false},
+		{"+ve:ShouldParseRequestsPerMinute", "100 / m", Limit{Requests: 100, Period: time.Minute}, false},
+		{"+ve:ShouldParseRequestsPerHour", "1000/h", Limit{Requests: 1000, Period: time.Hour}, false},
+		{"-ve:ShouldFailWhenPeriodIsMissing", "100", Limit{}, true},
+		{"-ve:ShouldFailWhenPeriodIsUnknown", "100/d", Limit{}, true},
+		{"-ve:ShouldFailWhenRequestsAreNotPositive", "0/s", Limit{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit, err := ParseLimit(tt.value)
+
+			if (err != nil) != tt.expectedErr {
+				t.Errorf("expected error %v, got %v", tt.expectedErr, err)
+			}
+			if tt.expectedLimit != limit {
+				t.Errorf("expected limit %+v, got %+v", tt.expectedLimit, limit)
+			}
+		})
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Date(2026, 10, 18, 9, 0, 0, 0, time.UTC)
+	store := &memoryStoreImpl{buckets: map[string]*bucket{}, now: func() time.Time { return now }}
+	limit := Limit{Requests: 2, Period: 10 * time.Second}
+	take := func(key string) Result {
+		result, err := store.Take(context.Background(), key, limit)
+		if err != nil {
+			t.Fatalf("unable to take token: %v", err)
+		}
+		return result
+	}
+
+	if result := take("alice"); !result.Allowed || result.Remaining != 1 || result.Reset != 5*time.Second {
+		t.Errorf("expected first request to be allowed, got %+v", result)
+	}
+	if result := take("alice"); !result.Allowed || result.Remaining != 0 || result.Reset != 10*time.Second {
+		t.Errorf("expected burst to be allowed, got %+v", result)
+	}
+	if result := take("alice"); result.Allowed || result.RetryAfter != 5*time.Second || result.Limit != 2 {
+		t.Errorf("expected request to be limited, got %+v", result)
+	}
+	if result := take("bob"); !result.Allowed {
+		t.Errorf("expected other client not to be limited, got %+v", result)
+	}
+
+	now = now.Add(5 * time.Second)
+	if result := take("alice"); !result.Allowed || result.Remaining != 0 {
+		t.Errorf("expected refilled token to be taken, got %+v", result)
+	}
+
+	now = now.Add(time.Minute)
+	take("carol")
+	if _, found := store.buckets["alice"]; found {
+		t.Errorf("expected full bucket to be swept")
+	}
+	if _, found := store.buckets["carol"]; !found {
+		t.Errorf("expected bucket in use to be kept")
+	}
+}
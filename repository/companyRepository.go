@@ -1,17 +1,31 @@
 package repository
 
 import (
+	"database/sql/driver"
+	"fmt"
 	uuid "github.com/satori/go.uuid"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
 	dbError "xm/error"
 )
 
 type Repository interface {
 	GetAll(uow *UnitOfWork, out interface{}, queryProcessors []QueryProcessor) dbError.DatabaseError
+	Count(uow *UnitOfWork, out interface{}, count *int64, queryProcessors []QueryProcessor) dbError.DatabaseError
+	Each(uow *UnitOfWork, out interface{}, queryProcessors []QueryProcessor, fn func() error) dbError.DatabaseError
 	Get(uow *UnitOfWork, out interface{}, id uuid.UUID) dbError.DatabaseError
 	Add(uow *UnitOfWork, out interface{}) dbError.DatabaseError
 	Update(uow *UnitOfWork, out interface{}) dbError.DatabaseError
+	UpdateColumns(uow *UnitOfWork, out interface{}, columns ...string) dbError.DatabaseError
 	Delete(uow *UnitOfWork, out interface{}, where ...interface{}) dbError.DatabaseError
+	Restore(uow *UnitOfWork, out interface{}) dbError.DatabaseError
+	Purge(uow *UnitOfWork, out interface{}, deletedBefore time.Time, purged *int64) dbError.DatabaseError
+	Search(uow *UnitOfWork, out interface{}, terms []string, limit int, matches *[]SearchMatch) dbError.DatabaseError
 }
 
 // GormRepository implements Repository
@@ -23,6 +37,13 @@ func NewRepository() Repository {
 	return &GormRepository{}
 }
 
+// Versioned is implemented by entities that are protected against concurrent modifications (optimistic locking).
+// The version is incremented on every update and updates or deletes of a stale version fail.
+type Versioned interface {
+	GetVersion() uint
+	SetVersion(version uint)
+}
+
 // QueryProcessor allows to modify the query before it is executed
 type QueryProcessor func(db *gorm.DB, out interface{}) (*gorm.DB, dbError.DatabaseError)
 
@@ -34,6 +55,200 @@ func Filter(condition string, args ...interface{}) QueryProcessor {
 	}
 }
 
+// Operator compares a column with a value
+type Operator int
+
+const (
+	// Equal matches when column is equal to value
+	Equal Operator = iota
+	// NotEqual matches when column is not equal to value
+	NotEqual
+	// Like matches when column matches the pattern in value ignoring case, '%' matches any sequence of characters and
+	// '_' any character, EscapeLike escapes them
+	Like
+	// In matches when column is equal to any of the values, value should be a slice
+	In
+	// NotIn matches when column is not equal to any of the values, value should be a slice
+	NotIn
+	// GreaterThan matches when column is greater than value
+	GreaterThan
+	// GreaterThanOrEqual matches when column is greater than or equal to value
+	GreaterThanOrEqual
+	// LessThan matches when column is less than value
+	LessThan
+	// LessThanOrEqual matches when column is less than or equal to value
+	LessThanOrEqual
+)
+
+// likeEscape escapes the wildcards of the patterns of the Like operator, it isn't a backslash as its quoting differs
+// between databases
+const likeEscape = "!"
+
+// likeEscaper escapes the wildcards and the escape character of the Like operator
+var likeEscaper = strings.NewReplacer(likeEscape, likeEscape+likeEscape, "%", likeEscape+"%", "_", likeEscape+"_")
+
+// EscapeLike returns the pattern of the Like operator matching value as is
+func EscapeLike(value string) string {
+	return likeEscaper.Replace(value)
+}
+
+// FilterBy will filter the results by comparing column with value using operator
+func FilterBy(column string, operator Operator, value interface{}) QueryProcessor {
+	return func(db *gorm.DB, out interface{}) (*gorm.DB, dbError.DatabaseError) {
+		col := clause.Column{Name: column}
+		var expression clause.Expression
+		switch operator {
+		case Equal:
+			expression = clause.Eq{Column: col, Value: value}
+		case NotEqual:
+			expression = clause.Neq{Column: col, Value: value}
+		case Like:
+			// LIKE ignores case on SQLite and MySQL but not on Postgres
+			expression = clause.Expr{SQL: "LOWER(?) LIKE LOWER(?) ESCAPE '" + likeEscape + "'", Vars: []interface{}{col, value}}
+		case In:
+			expression = clause.IN{Column: col, Values: value.([]interface{})}
+		case NotIn:
+			expression = clause.Not(clause.IN{Column: col, Values: value.([]interface{})})
+		case GreaterThan:
+			expression = clause.Gt{Column: col, Value: value}
+		case GreaterThanOrEqual:
+			expression = clause.Gte{Column: col, Value: value}
+		case LessThan:
+			expression = clause.Lt{Column: col, Value: value}
+		case LessThanOrEqual:
+			expression = clause.Lte{Column: col, Value: value}
+		default:
+			return db, dbError.NewDatabaseError(fmt.Errorf("unsupported operator: %v", operator))
+		}
+		db = db.Where(expression)
+		return db, nil
+	}
+}
+
+// Unscoped includes soft deleted records in the results
+func Unscoped() QueryProcessor {
+	return func(db *gorm.DB, out interface{}) (*gorm.DB, dbError.DatabaseError) {
+		db = db.Unscoped()
+		return db, nil
+	}
+}
+
+// OnlyDeleted restricts the results to soft deleted records
+func OnlyDeleted() QueryProcessor {
+	return func(db *gorm.DB, out interface{}) (*gorm.DB, dbError.DatabaseError) {
+		column, err := deletedAtColumn(out)
+		if err != nil {
+			return db, dbError.NewDatabaseError(err)
+		}
+		db = db.Unscoped().Where(clause.Neq{Column: clause.Column{Name: column}, Value: nil})
+		return db, nil
+	}
+}
+
+// SortField specifies a column the results are ordered by. NULL values of a Nullable column are positioned first in
+// ascending order and last in descending order, whatever the database.
+type SortField struct {
+	Column     string
+	Descending bool
+	Nullable   bool
+}
+
+// Order sorts the results by the specified fields, in the order they are specified
+func Order(sortFields ...SortField) QueryProcessor {
+	return func(db *gorm.DB, out interface{}) (*gorm.DB, dbError.DatabaseError) {
+		for _, sortField := range sortFields {
+			if sortField.Nullable {
+				isNull := db.Statement.Quote(sortField.Column) + " IS NULL"
+				db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: isNull, Raw: true}, Desc: !sortField.Descending})
+			}
+			db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: sortField.Column}, Desc: sortField.Descending})
+		}
+		return db, nil
+	}
+}
+
+// Limit restricts the number of results
+func Limit(limit int) QueryProcessor {
+	return func(db *gorm.DB, out interface{}) (*gorm.DB, dbError.DatabaseError) {
+		db = db.Limit(limit)
+		return db, nil
+	}
+}
+
+// After filters the results to the records that are positioned after the specified values when ordered by sortFields
+// (keyset pagination). 'values' must hold one value for every sort field. The last sort field should be unique
+// (e.g. the primary key) so that the position of every record is unambiguous. A nil value stands for NULL.
+func After(sortFields []SortField, values []interface{}) QueryProcessor {
+	return func(db *gorm.DB, out interface{}) (*gorm.DB, dbError.DatabaseError) {
+		var conditions []clause.Expression
+		for index, sortField := range sortFields {
+			var expressions []clause.Expression
+			for previous := 0; previous < index; previous++ {
+				// renders as IS NULL for a nil value
+				expressions = append(expressions, clause.Eq{Column: clause.Column{Name: sortFields[previous].Column}, Value: values[previous]})
+			}
+			column := clause.Column{Name: sortField.Column}
+			switch {
+			case values[index] == nil && sortField.Descending:
+				// NULL values are last in descending order, no value of this column comes after them
+				continue
+			case values[index] == nil:
+				expressions = append(expressions, clause.Neq{Column: column, Value: nil})
+			case sortField.Descending && sortField.Nullable:
+				expressions = append(expressions, clause.Or(clause.Lt{Column: column, Value: values[index]}, clause.Eq{Column: column, Value: nil}))
+			case sortField.Descending:
+				expressions = append(expressions, clause.Lt{Column: column, Value: values[index]})
+			default:
+				expressions = append(expressions, clause.Gt{Column: column, Value: values[index]})
+			}
+			conditions = append(conditions, clause.And(expressions...))
+		}
+		db = db.Where(clause.Or(conditions...))
+		return db, nil
+	}
+}
+
+var schemaCache = &sync.Map{}
+
+// Columns returns the fields of the specified entity keyed by their column name
+func Columns(entity interface{}) (map[string]*schema.Field, error) {
+	entitySchema, err := schema.Parse(entity, schemaCache, schema.NamingStrategy{})
+	if err != nil {
+		return nil, err
+	}
+	return entitySchema.FieldsByDBName, nil
+}
+
+// Nullable reports whether the column of the specified field may hold NULL, i.e. its Go type is a pointer or its
+// zero value is stored as NULL (e.g. gorm.DeletedAt, sql.NullString)
+func Nullable(field *schema.Field) bool {
+	if field.NotNull || field.PrimaryKey {
+		return false
+	}
+	if field.FieldType.Kind() == reflect.Ptr {
+		return true
+	}
+	valuer, ok := reflect.Zero(field.FieldType).Interface().(driver.Valuer)
+	if !ok {
+		return false
+	}
+	value, err := valuer.Value()
+	return err == nil && value == nil
+}
+
+// deletedAtColumn returns the soft delete column of the specified entity
+func deletedAtColumn(entity interface{}) (string, error) {
+	entitySchema, err := schema.Parse(entity, schemaCache, schema.NamingStrategy{})
+	if err != nil {
+		return "", err
+	}
+	field := entitySchema.LookUpField("DeletedAt")
+	if field == nil {
+		return "", fmt.Errorf("%s doesn't support soft delete", entitySchema.Name)
+	}
+	return field.DBName, nil
+}
+
 // GetAll retrieves all the records for a specified entity and returns it
 func (repository *GormRepository) GetAll(uow *UnitOfWork, out interface{}, queryProcessors []QueryProcessor) dbError.DatabaseError {
 	db := uow.DB
@@ -53,6 +268,59 @@ func (repository *GormRepository) GetAll(uow *UnitOfWork, out interface{}, query
 	return nil
 }
 
+// Count counts the records for a specified entity that match the query
+func (repository *GormRepository) Count(uow *UnitOfWork, out interface{}, count *int64, queryProcessors []QueryProcessor) dbError.DatabaseError {
+	db := uow.DB.Model(out)
+
+	var err error
+	for _, queryProcessor := range queryProcessors {
+		db, err = queryProcessor(db, out)
+		if err != nil {
+			return dbError.NewDatabaseError(err)
+		}
+	}
+	if err := db.Count(count).Error; err != nil {
+		return dbError.NewDatabaseError(err)
+	}
+	return nil
+}
+
+// Each retrieves the records for a specified entity one at a time, every record is scanned into out before fn is
+// called. The records are streamed from the database so that they are never all held in memory, iteration stops at
+// the first error returned by fn.
+func (repository *GormRepository) Each(uow *UnitOfWork, out interface{}, queryProcessors []QueryProcessor, fn func() error) dbError.DatabaseError {
+	db := uow.DB.Model(out)
+
+	var err error
+	for _, queryProcessor := range queryProcessors {
+		db, err = queryProcessor(db, out)
+		if err != nil {
+			return dbError.NewDatabaseError(err)
+		}
+	}
+
+	rows, err := db.Rows()
+	if err != nil {
+		return dbError.NewDatabaseError(err)
+	}
+	defer rows.Close()
+
+	entity := reflect.ValueOf(out).Elem()
+	for rows.Next() {
+		entity.Set(reflect.Zero(entity.Type()))
+		if err := db.ScanRows(rows, out); err != nil {
+			return dbError.NewDatabaseError(err)
+		}
+		if err := fn(); err != nil {
+			return dbError.NewDatabaseError(err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return dbError.NewDatabaseError(err)
+	}
+	return nil
+}
+
 // Get a record for specified entity with specific id
 func (repository *GormRepository) Get(uow *UnitOfWork, out interface{}, id uuid.UUID) dbError.DatabaseError {
 	if err := uow.DB.First(out, "id = ?", id).Error; err != nil {
@@ -64,14 +332,59 @@ func (repository *GormRepository) Get(uow *UnitOfWork, out interface{}, id uuid.
 // Add specified Entity
 func (repository *GormRepository) Add(uow *UnitOfWork, entity interface{}) dbError.DatabaseError {
 	if err := uow.DB.Create(entity).Error; err != nil {
-		return dbError.NewDatabaseError(err)
+		return newWriteError(err)
 	}
-	return nil
+	return syncSearchIndexResult(uow, entity, nil)
 }
 
 // Update specified Entity
 func (repository *GormRepository) Update(uow *UnitOfWork, entity interface{}) dbError.DatabaseError {
-	if err := uow.DB.Model(entity).Updates(entity).Error; err != nil {
+	db := uow.DB.Model(entity)
+	versioned, isVersioned := entity.(Versioned)
+	if isVersioned {
+		db = db.Where("version = ?", versioned.GetVersion())
+		versioned.SetVersion(versioned.GetVersion() + 1)
+	}
+
+	result := db.Updates(entity)
+	return syncSearchIndexResult(uow, entity, checkVersionedResult(versioned, isVersioned, result))
+}
+
+// UpdateColumns updates only the specified columns of the Entity, zero values included
+func (repository *GormRepository) UpdateColumns(uow *UnitOfWork, entity interface{}, columns ...string) dbError.DatabaseError {
+	db := uow.DB.Model(entity)
+	versioned, isVersioned := entity.(Versioned)
+	if isVersioned {
+		db = db.Where("version = ?", versioned.GetVersion())
+		versioned.SetVersion(versioned.GetVersion() + 1)
+		columns = append(columns, "version")
+	}
+
+	result := db.Select(columns).Updates(entity)
+	return syncSearchIndexResult(uow, entity, checkVersionedResult(versioned, isVersioned, result))
+}
+
+// checkVersionedResult fails when no record of a versioned entity has been modified, as its version has changed
+func checkVersionedResult(versioned Versioned, isVersioned bool, result *gorm.DB) dbError.DatabaseError {
+	if result.Error != nil {
+		if isVersioned {
+			versioned.SetVersion(versioned.GetVersion() - 1)
+		}
+		return newWriteError(result.Error)
+	}
+	if isVersioned && result.RowsAffected == 0 {
+		versioned.SetVersion(versioned.GetVersion() - 1)
+		return dbError.NewDatabaseError(dbError.ErrConcurrentModification)
+	}
+	return nil
+}
+
+// syncSearchIndexResult updates the full-text search index of the entity unless the modification has failed
+func syncSearchIndexResult(uow *UnitOfWork, entity interface{}, err dbError.DatabaseError) dbError.DatabaseError {
+	if err != nil {
+		return err
+	}
+	if err := syncSearchIndex(uow.DB, entity); err != nil {
 		return dbError.NewDatabaseError(err)
 	}
 	return nil
@@ -79,8 +392,48 @@ func (repository *GormRepository) Update(uow *UnitOfWork, entity interface{}) db
 
 // Delete specified Entity
 func (repository *GormRepository) Delete(uow *UnitOfWork, entity interface{}, where ...interface{}) dbError.DatabaseError {
-	if err := uow.DB.Delete(entity, where...).Error; err != nil {
+	db := uow.DB
+	versioned, isVersioned := entity.(Versioned)
+	if isVersioned {
+		db = db.Where("version = ?", versioned.GetVersion())
+	}
+
+	result := db.Delete(entity, where...)
+	if result.Error != nil {
+		return dbError.NewDatabaseError(result.Error)
+	}
+	if isVersioned && result.RowsAffected == 0 {
+		return dbError.NewDatabaseError(dbError.ErrConcurrentModification)
+	}
+	return syncSearchIndexResult(uow, entity, nil)
+}
+
+// Restore soft deleted Entity
+func (repository *GormRepository) Restore(uow *UnitOfWork, entity interface{}) dbError.DatabaseError {
+	db := uow.DB.Unscoped().Model(entity)
+	updates := map[string]interface{}{"DeletedAt": nil}
+	versioned, isVersioned := entity.(Versioned)
+	if isVersioned {
+		db = db.Where("version = ?", versioned.GetVersion())
+		versioned.SetVersion(versioned.GetVersion() + 1)
+		updates["Version"] = versioned.GetVersion()
+	}
+
+	result := db.Updates(updates)
+	return syncSearchIndexResult(uow, entity, checkVersionedResult(versioned, isVersioned, result))
+}
+
+// Purge permanently removes the records of the Entity that have been soft deleted before the specified time
+func (repository *GormRepository) Purge(uow *UnitOfWork, entity interface{}, deletedBefore time.Time, purged *int64) dbError.DatabaseError {
+	column, err := deletedAtColumn(entity)
+	if err != nil {
 		return dbError.NewDatabaseError(err)
 	}
+
+	result := uow.DB.Unscoped().Where(clause.Lt{Column: clause.Column{Name: column}, Value: deletedBefore}).Delete(entity)
+	if result.Error != nil {
+		return dbError.NewDatabaseError(result.Error)
+	}
+	*purged = result.RowsAffected
 	return nil
 }
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"errors"
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgconn"
+	"github.com/mattn/go-sqlite3"
+	dbError "xm/error"
+)
+
+// error codes of unique constraint violations of postgres (SQLSTATE) and mysql
+const (
+	postgresUniqueViolation = "23505"
+	mysqlDuplicateEntry     = 1062
+)
+
+// uniqueConstraintError is the error of the database driver for a unique constraint violation, it matches
+// dbError.ErrUniqueConstraint
+type uniqueConstraintError struct {
+	error
+}
+
+func (e uniqueConstraintError) Unwrap() error {
+	return e.error
+}
+
+func (e uniqueConstraintError) Is(target error) bool {
+	return target == dbError.ErrUniqueConstraint
+}
+
+// IsUniqueViolation reports whether the error is a unique constraint violation, according to the error code of the
+// database driver
+func IsUniqueViolation(err error) bool {
+	var sqliteError sqlite3.Error
+	if errors.As(err, &sqliteError) {
+		return sqliteError.ExtendedCode == sqlite3.ErrConstraintUnique
+	}
+	var postgresError *pgconn.PgError
+	if errors.As(err, &postgresError) {
+		return postgresError.Code == postgresUniqueViolation
+	}
+	var mysqlError *mysql.MySQLError
+	if errors.As(err, &mysqlError) {
+		return mysqlError.Number == mysqlDuplicateEntry
+	}
+	return false
+}
+
+// newWriteError creates the database error of a failed write, the unique constraint violations are recognized by
+// dbError.ErrUniqueConstraint
+func newWriteError(err error) dbError.DatabaseError {
+	if IsUniqueViolation(err) {
+		err = uniqueConstraintError{err}
+	}
+	return dbError.NewDatabaseError(err)
+}
@@ -0,0 +1,23 @@
+package repository
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+	"strings"
+)
+
+// uniqueIndexName returns the name of the unique index on the columns of the table
+func uniqueIndexName(table string, columns []string) string {
+	return fmt.Sprintf("uq_%s_%s", table, strings.Join(columns, "_"))
+}
+
+// HasUniqueIndex reports whether the unique index on the columns of the entity exists, the migrations name it
+// uq_<table>_<columns>
+func HasUniqueIndex(db *gorm.DB, entity interface{}, columns ...string) (bool, error) {
+	entitySchema, err := schema.Parse(entity, schemaCache, schema.NamingStrategy{})
+	if err != nil {
+		return false, err
+	}
+	return db.Migrator().HasIndex(entity, uniqueIndexName(entitySchema.Table, columns)), nil
+}
@@ -0,0 +1,232 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/schema"
+	"html"
+	"reflect"
+	"strings"
+	"sync"
+	dbError "xm/error"
+)
+
+// searchIndexes holds the full-text search indexes that have been registered, keyed by the table of the entity
+var searchIndexes = &sync.Map{}
+
+// searchIndex is an SQLite FTS5 virtual table holding the searchable columns of the records of a table that haven't
+// been soft deleted. It uses the trigram tokenizer so that any fragment of 3 or more characters matches.
+type searchIndex struct {
+	name       string
+	table      string
+	primaryKey *schema.Field
+	deletedAt  *schema.Field
+	columns    []string
+}
+
+// SearchMatch is a record matching a full-text search, with its relevance (higher is more relevant) and an HTML snippet
+// of the matching text where the matches are surrounded by <mark></mark>, the text itself is HTML-escaped
+type SearchMatch struct {
+	ID      string
+	Score   float64
+	Snippet string
+}
+
+// searchIndexName returns the name of the full-text search index of the table
+func searchIndexName(table string) string {
+	return table + "_search"
+}
+
+// lookUpSearchIndex returns the search index of the entity, nil when it hasn't been registered
+func lookUpSearchIndex(entity interface{}) (*searchIndex, error) {
+	entitySchema, err := schema.Parse(entity, schemaCache, schema.NamingStrategy{})
+	if err != nil {
+		return nil, err
+	}
+	index, ok := searchIndexes.Load(entitySchema.Table)
+	if !ok {
+		return nil, nil
+	}
+	return index.(*searchIndex), nil
+}
+
+// newSearchIndex returns the full-text search index on the columns of the entity, it fails when the database isn't
+// SQLite
+func newSearchIndex(db *gorm.DB, entity interface{}, columns []string) (*searchIndex, error) {
+	if dialect := db.Dialector.Name(); dialect != "sqlite" {
+		return nil, fmt.Errorf("%w: full-text search isn't supported by %s", dbError.ErrSearchUnavailable, dialect)
+	}
+	entitySchema, err := schema.Parse(entity, schemaCache, schema.NamingStrategy{})
+	if err != nil {
+		return nil, err
+	}
+
+	index := &searchIndex{
+		name:       searchIndexName(entitySchema.Table),
+		table:      entitySchema.Table,
+		primaryKey: entitySchema.PrioritizedPrimaryField,
+		deletedAt:  entitySchema.LookUpField("DeletedAt"),
+		columns:    columns,
+	}
+	if index.primaryKey == nil {
+		return nil, fmt.Errorf("%s doesn't have a primary key", entitySchema.Name)
+	}
+	return index, nil
+}
+
+// CreateSearchIndex creates the full-text search index on the columns of the entity, when it doesn't exist, and fills
+// it with the existing records. It fails when the database isn't SQLite or the SQLite driver has been built without
+// FTS5 (build tag 'sqlite_fts5'), search is unavailable then.
+func CreateSearchIndex(db *gorm.DB, entity interface{}, columns ...string) error {
+	index, err := newSearchIndex(db, entity, columns)
+	if err != nil {
+		return err
+	}
+	var fts5 bool
+	if err := db.Raw("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&fts5).Error; err != nil {
+		return err
+	}
+	if !fts5 {
+		return fmt.Errorf("%w: the SQLite driver has been built without FTS5", dbError.ErrSearchUnavailable)
+	}
+
+	if !db.Migrator().HasTable(index.name) {
+		sql := fmt.Sprintf("CREATE VIRTUAL TABLE %s USING fts5(%s UNINDEXED, %s, tokenize = 'trigram')",
+			db.Statement.Quote(index.name), db.Statement.Quote(index.primaryKey.DBName), quoteColumns(db, columns))
+		if err := db.Exec(sql).Error; err != nil {
+			return err
+		}
+	}
+	return index.rebuild(db)
+}
+
+// RegisterSearchIndex enables the search of the entity and the synchronisation of its full-text search index on add,
+// update and delete. The index is created by a migration, it fails when the index doesn't exist, search is unavailable
+// then.
+func RegisterSearchIndex(db *gorm.DB, entity interface{}, columns ...string) error {
+	index, err := newSearchIndex(db, entity, columns)
+	if err != nil {
+		return err
+	}
+	if !db.Migrator().HasTable(index.name) {
+		return fmt.Errorf("%w: %s doesn't exist", dbError.ErrSearchUnavailable, index.name)
+	}
+
+	searchIndexes.Store(index.table, index)
+	return nil
+}
+
+// RebuildSearchIndex refills the full-text search index of the entity from its table
+func RebuildSearchIndex(db *gorm.DB, entity interface{}) error {
+	index, err := lookUpSearchIndex(entity)
+	if err != nil {
+		return err
+	}
+	if index == nil {
+		return dbError.ErrSearchUnavailable
+	}
+	return db.Transaction(index.rebuild)
+}
+
+// rebuild removes all the records from the index and adds the ones of the table again
+func (index *searchIndex) rebuild(db *gorm.DB) error {
+	if err := db.Exec("DELETE FROM ?", clause.Table{Name: index.name}).Error; err != nil {
+		return err
+	}
+	return index.insert(db, nil)
+}
+
+// insert adds the records of the table with the specified primary key, or all of them when it is nil, to the index
+func (index *searchIndex) insert(db *gorm.DB, primaryKey interface{}) error {
+	columns := quoteColumns(db, append([]string{index.primaryKey.DBName}, index.columns...))
+
+	sql := fmt.Sprintf("INSERT INTO ? (%s) SELECT %s FROM ? WHERE 1 = 1", columns, columns)
+	values := []interface{}{clause.Table{Name: index.name}, clause.Table{Name: index.table}}
+	if index.deletedAt != nil {
+		sql += " AND ? IS NULL"
+		values = append(values, clause.Column{Name: index.deletedAt.DBName})
+	}
+	if primaryKey != nil {
+		sql += " AND ? = ?"
+		values = append(values, clause.Column{Name: index.primaryKey.DBName}, primaryKey)
+	}
+	return db.Exec(sql, values...).Error
+}
+
+// quoteColumns returns the quoted names of the columns separated by commas
+func quoteColumns(db *gorm.DB, columns []string) string {
+	quoted := make([]string, len(columns))
+	for position, column := range columns {
+		quoted[position] = db.Statement.Quote(column)
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// syncSearchIndex updates the full-text search index after the entity has been added, updated or deleted, entities
+// that aren't indexed are ignored. When the primary key of the entity isn't set the records that no longer exist are
+// removed from the index.
+func syncSearchIndex(db *gorm.DB, entity interface{}) error {
+	index, err := lookUpSearchIndex(entity)
+	if index == nil || err != nil {
+		return err
+	}
+
+	primaryKey, isZero := index.primaryKey.ValueOf(context.Background(), reflect.Indirect(reflect.ValueOf(entity)))
+	column := clause.Column{Name: index.primaryKey.DBName}
+	if isZero {
+		sql := "DELETE FROM ? WHERE ? NOT IN (SELECT ? FROM ?"
+		values := []interface{}{clause.Table{Name: index.name}, column, column, clause.Table{Name: index.table}}
+		if index.deletedAt != nil {
+			sql += " WHERE ? IS NULL"
+			values = append(values, clause.Column{Name: index.deletedAt.DBName})
+		}
+		return db.Exec(sql+")", values...).Error
+	}
+
+	if err := db.Exec("DELETE FROM ? WHERE ? = ?", clause.Table{Name: index.name}, column, primaryKey).Error; err != nil {
+		return err
+	}
+	return index.insert(db, primaryKey)
+}
+
+// snippet markers surround the matches in the snippets of FTS5, they're replaced by <mark></mark> once the text of the
+// snippet has been HTML-escaped
+const (
+	snippetMatchStart = "\x02"
+	snippetMatchEnd   = "\x03"
+)
+
+// snippetHTML turns the markers of the matches into <mark></mark>
+var snippetHTML = strings.NewReplacer(snippetMatchStart, "<mark>", snippetMatchEnd, "</mark>")
+
+// Search finds the records of the entity matching all the terms of the query, ordered by relevance
+func (repository *GormRepository) Search(uow *UnitOfWork, entity interface{}, terms []string, limit int, matches *[]SearchMatch) dbError.DatabaseError {
+	index, err := lookUpSearchIndex(entity)
+	if err != nil {
+		return dbError.NewDatabaseError(err)
+	}
+	if index == nil {
+		return dbError.NewDatabaseError(dbError.ErrSearchUnavailable)
+	}
+
+	// every term is quoted so that it is matched as a string rather than parsed as a query expression
+	quotedTerms := make([]string, len(terms))
+	for position, term := range terms {
+		quotedTerms[position] = `"` + strings.ReplaceAll(term, `"`, `""`) + `"`
+	}
+
+	table := clause.Table{Name: index.name}
+	err = uow.DB.Raw("SELECT ? AS id, -bm25(?) AS score, snippet(?, -1, ?, ?, '…', 16) AS snippet FROM ? WHERE ? MATCH ? ORDER BY rank LIMIT ?",
+		clause.Column{Name: index.primaryKey.DBName}, table, table, snippetMatchStart, snippetMatchEnd, table, table, strings.Join(quotedTerms, " "), limit).
+		Scan(matches).Error
+	if err != nil {
+		return dbError.NewDatabaseError(err)
+	}
+	// the indexed text is stored as is, it's escaped so that the snippets can be rendered as HTML
+	for position := range *matches {
+		(*matches)[position].Snippet = snippetHTML.Replace(html.EscapeString((*matches)[position].Snippet))
+	}
+	return nil
+}
@@ -4,29 +4,39 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"github.com/gorilla/mux"
 	"gorm.io/gorm"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"xm/app"
+	"xm/auth"
 	"xm/client"
 	"xm/controller"
 	apiError "xm/error"
+	"xm/migration"
 	"xm/model"
 	"xm/repository"
 )
 
 var testApplication *app.TestApp
 
+// testAPIKey is the admin key the API is called with, unless the test sets the credentials of the request
+var testAPIKey string
+
 func TestMain(m *testing.M) {
 	routeProvider := func(app2 *app.App) []app.RouteSpecifier {
 		ipLocationClient := client.NewIpLocationClient("https://ipapi.co")
 		companyRepository := repository.NewRepository()
+		apiKeyRepository := repository.NewRepository()
+		authenticator := auth.NewAPIKeyAuthenticator(app2.DB, apiKeyRepository)
 
 		return []app.RouteSpecifier{
-			controller.NewCompanyController(app2, ipLocationClient, companyRepository),
+			controller.NewCompanyController(app2, ipLocationClient, companyRepository, authenticator, nil),
+			controller.NewAPIKeyController(app2, apiKeyRepository, authenticator, nil),
 		}
 	}
 	testApplication = app.NewTestApp("XM", routeProvider, initializeDB)
@@ -37,12 +47,26 @@ func TestMain(m *testing.M) {
 }
 
 func initializeDB(db *gorm.DB) {
-	db.Migrator().DropTable(&model.Company{})
-	db.Migrator().AutoMigrate(&model.Company{})
+	db.Migrator().DropTable(&model.Company{}, &model.APIKey{}, "companies_search", "schema_migrations")
+	migration.Up(db, migration.Migrations(), 0)
+	apiKey, key, _ := model.NewAPIKey("tests", model.RoleAdmin, model.Scopes())
+	db.Create(apiKey)
+	testAPIKey = key
+	repository.RegisterSearchIndex(db, &model.Company{}, model.CompanySearchColumns()...)
 }
 
 // callAPI invokes http API
 func callAPI(httpMethod string, apiURL string, req interface{}) *httptest.ResponseRecorder {
+	return callAPIWithHeaders(httpMethod, apiURL, req, nil)
+}
+
+// callAPIWithHeaders invokes http API with the specified request headers
+func callAPIWithHeaders(httpMethod string, apiURL string, req interface{}, headers map[string]string) *httptest.ResponseRecorder {
+	return callRouter(testApplication.Application.Router, httpMethod, apiURL, req, headers)
+}
+
+// callRouter invokes http API served by the router, e.g. a router built with another config by TestApp.NewRouter
+func callRouter(router *mux.Router, httpMethod string, apiURL string, req interface{}, headers map[string]string) *httptest.ResponseRecorder {
 
 	var payload io.Reader
 	if req != nil {
@@ -51,12 +75,38 @@ func callAPI(httpMethod string, apiURL string, req interface{}) *httptest.Respon
 	}
 
 	httpReq, _ := http.NewRequest(httpMethod, apiURL, payload)
+	withTestAPIKey(httpReq, headers)
+	for header, value := range headers {
+		httpReq.Header.Set(header, value)
+	}
+
+	rr := httptest.NewRecorder()
+	router.ServeHTTP(rr, httpReq)
+	return rr
+}
+
+// callAPIWithBody invokes http API with a raw request body of the specified content type
+func callAPIWithBody(httpMethod string, apiURL string, contentType string, body string) *httptest.ResponseRecorder {
+	httpReq, _ := http.NewRequest(httpMethod, apiURL, strings.NewReader(body))
+	httpReq.Header.Set("Content-Type", contentType)
+	withTestAPIKey(httpReq, nil)
 
 	rr := httptest.NewRecorder()
 	testApplication.Application.Router.ServeHTTP(rr, httpReq)
 	return rr
 }
 
+// withTestAPIKey authenticates the request with the admin key of the tests, unless the headers carry credentials
+func withTestAPIKey(httpReq *http.Request, headers map[string]string) {
+	if _, ok := headers["X-API-Key"]; ok {
+		return
+	}
+	if _, ok := headers["Authorization"]; ok {
+		return
+	}
+	httpReq.Header.Set("X-API-Key", testAPIKey)
+}
+
 // checkResponseCode checks if the http response is as expected
 func checkResponseCode(t *testing.T, expected, actual int) {
 	if expected != actual {
@@ -89,6 +139,13 @@ type companyDTO struct {
 	Phone   string `json:"phone"`
 }
 
+// data transfer object of a page of companies
+type companyPageDTO struct {
+	Items      []companyDTO `json:"items"`
+	NextCursor *string      `json:"nextCursor"`
+	TotalCount int64        `json:"totalCount"`
+}
+
 func addCompanyToDB(t *testing.T, name, code, country, website, phone string) *model.Company {
 	company, err := model.NewCompany(name, code, country, website, phone)
 	if err != nil {
@@ -103,6 +160,13 @@ func addCompanyToDB(t *testing.T, name, code, country, website, phone string) *m
 	return company
 }
 
+func deleteCompanyFromDB(t *testing.T, company *model.Company) {
+	err := testApplication.Application.DB.Delete(company).Error
+	if err != nil {
+		t.Errorf("unable to delete company from DB [%v]!", err)
+	}
+}
+
 func getCompanyToDB(t *testing.T, id string) (bool, *model.Company) {
 	company := &model.Company{}
 	err := testApplication.Application.DB.First(company, "id = ?", id).Error
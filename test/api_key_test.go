@@ -0,0 +1,193 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	apiError "xm/error"
+	"xm/model"
+)
+
+// data transfer object of api key
+type apiKeyDTO struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Scopes    []string `json:"scopes"`
+	Role      string   `json:"role"`
+	Prefix    string   `json:"prefix"`
+	RevokedOn *string  `json:"revokedOn"`
+	Key       string   `json:"key"`
+}
+
+func addAPIKeyToDB(t *testing.T, name string, role string, scopes ...string) string {
+	apiKey, key, err := model.NewAPIKey(name, role, scopes)
+	if err != nil {
+		t.Fatalf("unable to create api key [%v]!", err)
+	}
+	if err := testApplication.Application.DB.Create(apiKey).Error; err != nil {
+		t.Fatalf("unable to insert api key into DB [%v]!", err)
+	}
+	return key
+}
+
+func callAPIWithKey(httpMethod string, apiURL string, req interface{}, key string) *apiKeyResponse {
+	response := callAPIWithHeaders(httpMethod, apiURL, req, map[string]string{"X-API-Key": key})
+	respDTO := apiKeyDTO{}
+	json.Unmarshal(response.Body.Bytes(), &respDTO)
+	return &apiKeyResponse{code: response.Code, apiKey: respDTO, body: response.Body.String()}
+}
+
+type apiKeyResponse struct {
+	code   int
+	apiKey apiKeyDTO
+	body   string
+}
+
+func TestAPIKeyAdmin(t *testing.T) {
+	testApplication.PrepareEmptyTables()
+
+	adminKey := addAPIKeyToDB(t, "admin", model.RoleAdmin, model.ScopeKeysAdmin)
+	readerKey := addAPIKeyToDB(t, "reader", model.RoleViewer, model.ScopeCompaniesRead)
+
+	tests := []struct {
+		name               string
+		key                string
+		req                interface{}
+		wantHttpStatusCode int
+	}{
+		{"+ve:ShouldCreateKey", adminKey, map[string]interface{}{"name": "crm", "role": model.RoleViewer, "scopes": []string{model.ScopeCompaniesRead}}, http.StatusCreated},
+		{"-ve:ShouldFailWhenNameIsMissing", adminKey, map[string]interface{}{"role": model.RoleViewer, "scopes": []string{model.ScopeCompaniesRead}}, http.StatusBadRequest},
+		{"+ve:ShouldCreateKeyWithRole", adminKey, map[string]interface{}{"name": "crm", "role": model.RoleEditor, "scopes": []string{model.ScopeCompaniesRead}}, http.StatusCreated},
+		{"-ve:ShouldFailWhenRoleIsMissing", adminKey, map[string]interface{}{"name": "crm", "scopes": []string{model.ScopeCompaniesRead}}, http.StatusBadRequest},
+		{"-ve:ShouldFailWhenRoleIsUnknown", adminKey, map[string]interface{}{"name": "crm", "role": "owner", "scopes": []string{model.ScopeCompaniesRead}}, http.StatusBadRequest},
+		{"-ve:ShouldFailWhenScopeIsUnknown", adminKey, map[string]interface{}{"name": "crm", "scopes": []string{"companies:all"}}, http.StatusBadRequest},
+		{"-ve:ShouldFailWhenKeyIsMissing", "", map[string]interface{}{"name": "crm", "scopes": []string{model.ScopeCompaniesRead}}, http.StatusUnauthorized},
+		{"-ve:ShouldFailWhenKeyIsUnknown", "xm_unknown", map[string]interface{}{"name": "crm", "scopes": []string{model.ScopeCompaniesRead}}, http.StatusUnauthorized},
+		{"-ve:ShouldFailWhenScopeIsMissing", readerKey, map[string]interface{}{"name": "crm", "scopes": []string{model.ScopeCompaniesRead}}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := callAPIWithKey(http.MethodPost, "/api/keys", tt.req, tt.key)
+
+			checkResponseCode(t, tt.wantHttpStatusCode, response.code)
+
+			if tt.wantHttpStatusCode == http.StatusCreated {
+				if len(response.apiKey.Key) == 0 || response.apiKey.Prefix != response.apiKey.Key[:len(response.apiKey.Prefix)] {
+					t.Errorf("expected the key and its prefix, got %v", response.body)
+				}
+				var stored model.APIKey
+				testApplication.Application.DB.First(&stored, "id = ?", response.apiKey.ID)
+				if stored.Hash == response.apiKey.Key || stored.Hash != model.HashAPIKey(response.apiKey.Key) {
+					t.Errorf("expected the hash of the key to be stored")
+				}
+			}
+		})
+	}
+
+	list := callAPIWithHeaders(http.MethodGet, "/api/keys", nil, map[string]string{"X-API-Key": adminKey})
+	checkResponseCode(t, http.StatusOK, list.Code)
+	var apiKeys []apiKeyDTO
+	json.Unmarshal(list.Body.Bytes(), &apiKeys)
+	if len(apiKeys) != 5 {
+		t.Errorf("expected 5 api keys, got %v", list.Body.String())
+	}
+	for _, apiKey := range apiKeys {
+		if len(apiKey.Key) > 0 {
+			t.Errorf("expected keys not to be listed, got %v", list.Body.String())
+		}
+	}
+}
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	testApplication.PrepareEmptyTables()
+
+	adminKey := addAPIKeyToDB(t, "admin", model.RoleAdmin, model.ScopeKeysAdmin)
+	created := callAPIWithKey(http.MethodPost, "/api/keys", map[string]interface{}{"name": "crm", "role": model.RoleAdmin, "scopes": []string{model.ScopeCompaniesRead}}, adminKey)
+	checkResponseCode(t, http.StatusCreated, created.code)
+	company := addCompanyToDB(t, "ABC Enterprise", "001", "CY", "https://www.abc.com", "990100000")
+	companyURL := fmt.Sprintf("/api/companies/%s", company.ID)
+
+	// anonymous callers have no role, which every company route requires
+	checkResponseCode(t, http.StatusUnauthorized, callAPIWithKey(http.MethodGet, companyURL, nil, "").code)
+	checkResponseCode(t, http.StatusOK, callAPIWithKey(http.MethodGet, companyURL, nil, created.apiKey.Key).code)
+	checkResponseCode(t, http.StatusForbidden, callAPIWithKey(http.MethodDelete, companyURL, nil, created.apiKey.Key).code)
+	checkResponseCode(t, http.StatusUnauthorized, callAPIWithKey(http.MethodGet, companyURL, nil, "xm_unknown").code)
+
+	rotated := callAPIWithKey(http.MethodPost, fmt.Sprintf("/api/keys/%s/rotate", created.apiKey.ID), nil, adminKey)
+	checkResponseCode(t, http.StatusOK, rotated.code)
+	checkResponseCode(t, http.StatusUnauthorized, callAPIWithKey(http.MethodGet, companyURL, nil, created.apiKey.Key).code)
+	checkResponseCode(t, http.StatusOK, callAPIWithKey(http.MethodGet, companyURL, nil, rotated.apiKey.Key).code)
+
+	revoked := callAPIWithKey(http.MethodDelete, fmt.Sprintf("/api/keys/%s", created.apiKey.ID), nil, adminKey)
+	checkResponseCode(t, http.StatusOK, revoked.code)
+	if revoked.apiKey.RevokedOn == nil {
+		t.Errorf("expected revoked key, got %v", revoked.body)
+	}
+	checkResponseCode(t, http.StatusUnauthorized, callAPIWithKey(http.MethodGet, companyURL, nil, rotated.apiKey.Key).code)
+	checkResponseCode(t, http.StatusNotFound, callAPIWithKey(http.MethodPost, fmt.Sprintf("/api/keys/%s/rotate", created.apiKey.ID), nil, adminKey).code)
+}
+
+func TestRoleAuthorization(t *testing.T) {
+	testApplication.PrepareEmptyTables()
+
+	scopes := []string{model.ScopeCompaniesRead, model.ScopeCompaniesWrite}
+	viewerKey := addAPIKeyToDB(t, "viewer", model.RoleViewer, scopes...)
+	editorKey := addAPIKeyToDB(t, "editor", model.RoleEditor, scopes...)
+	adminKey := addAPIKeyToDB(t, "admin", model.RoleAdmin, scopes...)
+	company := addCompanyToDB(t, "ABC Enterprise", "001", "CY", "https://www.abc.com", "990100000")
+	companyURL := fmt.Sprintf("/api/companies/%s", company.ID)
+	update := map[string]interface{}{"name": "ABC Enterprise", "code": "001", "country": "CY", "website": "https://www.abc.com", "phone": "990100000"}
+
+	tests := []struct {
+		name               string
+		httpMethod         string
+		req                interface{}
+		key                string
+		wantHttpStatusCode int
+	}{
+		{"+ve:ShouldAllowViewerToGet", http.MethodGet, nil, viewerKey, http.StatusOK},
+		{"-ve:ShouldForbidViewerToUpdate", http.MethodPut, update, viewerKey, http.StatusForbidden},
+		{"+ve:ShouldAllowEditorToUpdate", http.MethodPut, update, editorKey, http.StatusOK},
+		{"-ve:ShouldForbidEditorToDelete", http.MethodDelete, nil, editorKey, http.StatusForbidden},
+		{"+ve:ShouldAllowAdminToDelete", http.MethodDelete, nil, adminKey, http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := callAPIWithKey(tt.httpMethod, companyURL, tt.req, tt.key)
+
+			checkResponseCode(t, tt.wantHttpStatusCode, response.code)
+			if tt.wantHttpStatusCode == http.StatusForbidden && !strings.Contains(response.body, apiError.ErrorCodeForbidden) {
+				t.Errorf("expected %s, got %v", apiError.ErrorCodeForbidden, response.body)
+			}
+		})
+	}
+}
+
+func TestMetricsAdmin(t *testing.T) {
+	testApplication.PrepareEmptyTables()
+
+	adminKey := addAPIKeyToDB(t, "admin", model.RoleAdmin, model.ScopeKeysAdmin)
+	readerKey := addAPIKeyToDB(t, "reader", model.RoleViewer, model.ScopeCompaniesRead)
+
+	tests := []struct {
+		name               string
+		key                string
+		wantHttpStatusCode int
+	}{
+		{"+ve:ShouldServeMetricsToAdmin", adminKey, http.StatusOK},
+		{"-ve:ShouldFailWhenKeyIsMissing", "", http.StatusUnauthorized},
+		{"-ve:ShouldFailWhenScopeIsMissing", readerKey, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := callAPIWithHeaders(http.MethodGet, "/debug/vars", nil, map[string]string{"X-API-Key": tt.key})
+
+			checkResponseCode(t, tt.wantHttpStatusCode, response.Code)
+			if tt.wantHttpStatusCode == http.StatusOK && !strings.Contains(response.Body.String(), `"memstats"`) {
+				t.Errorf("expected the metrics, got %s", response.Body.String())
+			}
+		})
+	}
+}
@@ -4,8 +4,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
 	"testing"
+	"xm/app"
 	apiError "xm/error"
 )
 
@@ -138,16 +138,16 @@ func TestAddCompany(t *testing.T) {
 		},
 	}
 
+	otherOriginRouter := testApplication.NewRouter(func(config *app.Config) { config.OriginCountry = "US" })
 	for _, tt := range testCases {
-		os.Unsetenv("ORIGIN_COUNTRY")
-
 		t.Run(tt.name, func(t *testing.T) {
 
+			router := testApplication.Application.Router
 			if tt.setInvalidRequestOrigin {
-				os.Setenv("ORIGIN_COUNTRY", "US")
+				router = otherOriginRouter
 			}
 
-			response := callAPI(http.MethodPost, "/api/companies", tt.payload)
+			response := callRouter(router, http.MethodPost, "/api/companies", tt.payload, nil)
 
 			checkResponseCode(t, tt.wantHttpStatus, response.Code)
 
@@ -0,0 +1,183 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	apiError "xm/error"
+	"xm/model"
+)
+
+type batchOperation struct {
+	Op      string     `json:"op"`
+	ID      string     `json:"id,omitempty"`
+	IfMatch string     `json:"ifMatch,omitempty"`
+	Company companyDTO `json:"company"`
+}
+
+type batchResult struct {
+	Index   int                       `json:"index"`
+	Status  int                       `json:"status"`
+	Company *companyDTO               `json:"company"`
+	Error   *apiError.ValidationError `json:"error"`
+}
+
+func TestBatchCompanies(t *testing.T) {
+	newCompany := func(name, code string) companyDTO {
+		return companyDTO{Name: name, Code: code, Country: "India", Website: "https://www.abc.com", Phone: "990100000"}
+	}
+
+	tests := []struct {
+		name            string
+		atomic          string
+		operations      func() []batchOperation
+		wantCode        int
+		wantStatuses    []int
+		wantStoredCodes []string
+	}{
+		{"+ve:ShouldApplyAllOperationsAtomically",
+			"true",
+			func() []batchOperation {
+				company1 := addCompanyToDB(t, "ABC Enterprise", "001", "India", "https://www.abc.com", "990100000")
+				company2 := addCompanyToDB(t, "XYZ Enterprise", "002", "India", "https://www.xyz.com", "990100000")
+				return []batchOperation{
+					{Op: "create", Company: newCompany("123 Enterprise", "003")},
+					{Op: "update", ID: company1.ID.String(), IfMatch: `"1"`, Company: newCompany("ABC Enterprise", "004")},
+					{Op: "delete", ID: company2.ID.String()},
+				}
+			},
+			http.StatusOK,
+			[]int{http.StatusCreated, http.StatusOK, http.StatusOK},
+			[]string{"004", "003"},
+		},
+		{"-ve:ShouldRollbackAllOperationsWhenOneFailsAtomically",
+			"true",
+			func() []batchOperation {
+				company1 := addCompanyToDB(t, "ABC Enterprise", "001", "India", "https://www.abc.com", "990100000")
+				return []batchOperation{
+					{Op: "create", Company: newCompany("123 Enterprise", "003")},
+					{Op: "update", ID: company1.ID.String(), Company: newCompany("", "004")},
+					{Op: "delete", ID: company1.ID.String()},
+				}
+			},
+			http.StatusMultiStatus,
+			[]int{http.StatusFailedDependency, http.StatusBadRequest, http.StatusFailedDependency},
+			[]string{"001"},
+		},
+		{"+ve:ShouldApplySucceedingOperationsOnBestEffort",
+			"false",
+			func() []batchOperation {
+				company1 := addCompanyToDB(t, "ABC Enterprise", "001", "India", "https://www.abc.com", "990100000")
+				return []batchOperation{
+					{Op: "create", Company: newCompany("123 Enterprise", "003")},
+					{Op: "create", Company: newCompany("456 Enterprise", "003")},
+					{Op: "update", ID: company1.ID.String(), IfMatch: `"7"`, Company: newCompany("ABC Enterprise", "004")},
+					{Op: "delete", ID: "unknown"},
+					{Op: "merge", ID: company1.ID.String()},
+					{Op: "create", Company: newCompany("789 Enterprise", "005")},
+				}
+			},
+			http.StatusMultiStatus,
+			[]int{http.StatusCreated, http.StatusConflict, http.StatusPreconditionFailed, http.StatusNotFound, http.StatusBadRequest, http.StatusCreated},
+			[]string{"001", "003", "005"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testApplication.PrepareEmptyTables()
+
+			response := callAPI(http.MethodPost, "/api/companies:batch?atomic="+tt.atomic, tt.operations())
+
+			checkResponseCode(t, tt.wantCode, response.Code)
+
+			var results []batchResult
+			if err := json.Unmarshal(response.Body.Bytes(), &results); err != nil {
+				t.Errorf("unable to parse response: %v", err)
+				return
+			}
+
+			if len(tt.wantStatuses) != len(results) {
+				t.Errorf("expected count of results %d, got %v", len(tt.wantStatuses), len(results))
+				return
+			}
+			for index, result := range results {
+				if tt.wantStatuses[index] != result.Status {
+					t.Errorf("expected status %d at %d\nGot %v", tt.wantStatuses[index], index, result.Status)
+				}
+			}
+
+			var storedCodes []string
+			testApplication.Application.DB.Table("companies").Where(`"deletedOn" IS NULL`).Order(`"createdOn"`).Pluck("code", &storedCodes)
+			if len(tt.wantStoredCodes) != len(storedCodes) {
+				t.Errorf("expected stored codes %v\nGot %v", tt.wantStoredCodes, storedCodes)
+				return
+			}
+			for index, code := range storedCodes {
+				if tt.wantStoredCodes[index] != code {
+					t.Errorf("expected stored codes %v\nGot %v", tt.wantStoredCodes, storedCodes)
+					return
+				}
+			}
+		})
+	}
+}
+
+func TestBatchCompaniesWithInvalidRequest(t *testing.T) {
+	testApplication.PrepareEmptyTables()
+
+	response := callAPI(http.MethodPost, "/api/companies:batch?atomic=yes", []batchOperation{})
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+	assertErrorResponse(t, response, apiError.ErrorCodeInvalidFields, "atomic", apiError.ErrorCodeInvalidValue)
+
+	response = callAPI(http.MethodPost, "/api/companies:batch", map[string]string{"op": "create"})
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+	assertErrorResponse(t, response, apiError.ErrorCodeInvalidRequestPayload, "payload", apiError.ErrorCodeInvalidJSON)
+}
+
+func TestBatchCompaniesRoles(t *testing.T) {
+	scopes := []string{model.ScopeCompaniesRead, model.ScopeCompaniesWrite}
+	newCompany := companyDTO{Name: "123 Enterprise", Code: "003", Country: "India", Website: "https://www.abc.com", Phone: "990100000"}
+
+	tests := []struct {
+		name         string
+		role         string
+		atomic       string
+		wantCode     int
+		wantStatuses []int
+	}{
+		{"+ve:ShouldAllowAdminToCreateAndDelete", model.RoleAdmin, "false", http.StatusOK, []int{http.StatusCreated, http.StatusOK}},
+		{"-ve:ShouldForbidEditorToDelete", model.RoleEditor, "false", http.StatusMultiStatus, []int{http.StatusCreated, http.StatusForbidden}},
+		{"-ve:ShouldForbidEditorToDeleteAtomically", model.RoleEditor, "true", http.StatusMultiStatus, []int{http.StatusFailedDependency, http.StatusForbidden}},
+		{"-ve:ShouldForbidViewerToBatch", model.RoleViewer, "false", http.StatusForbidden, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testApplication.PrepareEmptyTables()
+			key := addAPIKeyToDB(t, tt.role, tt.role, scopes...)
+			company := addCompanyToDB(t, "ABC Enterprise", "001", "India", "https://www.abc.com", "990100000")
+			operations := []batchOperation{{Op: "create", Company: newCompany}, {Op: "delete", ID: company.ID.String()}}
+
+			response := callAPIWithHeaders(http.MethodPost, "/api/companies:batch?atomic="+tt.atomic, operations, map[string]string{"X-API-Key": key})
+
+			checkResponseCode(t, tt.wantCode, response.Code)
+			if tt.wantStatuses == nil {
+				return
+			}
+			var results []batchResult
+			if err := json.Unmarshal(response.Body.Bytes(), &results); err != nil {
+				t.Fatalf("unable to parse response: %v", err)
+			}
+			if len(tt.wantStatuses) != len(results) {
+				t.Fatalf("expected count of results %d, got %v", len(tt.wantStatuses), len(results))
+			}
+			for index, result := range results {
+				if tt.wantStatuses[index] != result.Status {
+					t.Errorf("expected status %d at %d\nGot %v", tt.wantStatuses[index], index, result.Status)
+				}
+			}
+			if found, _ := getCompanyToDB(t, company.ID.String()); found != (tt.wantStatuses[1] != http.StatusOK) {
+				t.Errorf("expected the company to be deleted only by the admin")
+			}
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"xm/controller"
+	apiError "xm/error"
+	"xm/model"
+	"xm/repository"
+)
+
+func TestCompanyCodeConflict(t *testing.T) {
+	testApplication.PrepareEmptyTables()
+
+	restorableCompany := addCompanyToDB(t, "456 Enterprise", "001", "India", "https://www.456.com", "980100011")
+	deleteCompanyFromDB(t, restorableCompany)
+	existingCompany := addCompanyToDB(t, "ABC Enterprise", "001", "India", "https://www.abc.com", "990100000")
+	company := addCompanyToDB(t, "XYZ Enterprise", "002", "US", "https://www.xyz.com", "800100009")
+	deletedCompany := addCompanyToDB(t, "123 Enterprise", "003", "India", "https://www.123.com", "980100010")
+	deleteCompanyFromDB(t, deletedCompany)
+
+	payload := func(code string) companyDTO {
+		return companyDTO{Name: "XYZ Enterprise", Code: code, Country: "US", Website: "https://www.xyz.com", Phone: "800100009"}
+	}
+
+	tests := []struct {
+		name         string
+		httpMethod   string
+		apiURL       string
+		req          interface{}
+		headers      map[string]string
+		wantCode     int
+		wantLocation string
+	}{
+		{"-ve:ShouldFailAddWhenCodeExists", http.MethodPost, "/api/companies", payload("001"), nil, http.StatusConflict, "/api/companies/" + existingCompany.ID.String()},
+		{"-ve:ShouldFailUpdateWhenCodeExists", http.MethodPut, "/api/companies/" + company.ID.String(), payload("001"), nil, http.StatusConflict, "/api/companies/" + existingCompany.ID.String()},
+		{"-ve:ShouldFailPatchWhenCodeExists", http.MethodPatch, "/api/companies/" + company.ID.String(), map[string]string{"code": "001"}, map[string]string{"Content-Type": "application/merge-patch+json"}, http.StatusConflict, "/api/companies/" + existingCompany.ID.String()},
+		{"-ve:ShouldFailRestoreWhenCodeExists", http.MethodPost, "/api/companies/" + restorableCompany.ID.String() + "/restore", nil, nil, http.StatusConflict, "/api/companies/" + existingCompany.ID.String()},
+		{"+ve:ShouldUpdateWithOwnCode", http.MethodPut, "/api/companies/" + company.ID.String(), payload("002"), nil, http.StatusOK, ""},
+		{"+ve:ShouldUpdateWithCodeOfDeletedCompany", http.MethodPut, "/api/companies/" + company.ID.String(), payload("003"), nil, http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := callAPIWithHeaders(tt.httpMethod, tt.apiURL, tt.req, tt.headers)
+
+			checkResponseCode(t, tt.wantCode, response.Code)
+			if tt.wantCode != http.StatusConflict {
+				return
+			}
+
+			assertErrorResponse(t, response, apiError.ErrorCodeConflict, "code", apiError.ErrorCodeAlreadyExists)
+
+			var errData map[string]interface{}
+			if err := json.Unmarshal(response.Body.Bytes(), &errData); err != nil {
+				t.Errorf("unable to parse response: %v", err)
+				return
+			}
+			if errData["location"] != tt.wantLocation {
+				t.Errorf("expected location %v\nGot %v", tt.wantLocation, errData["location"])
+			}
+		})
+	}
+}
+
+func TestCompanyCodeUniqueIndex(t *testing.T) {
+	testApplication.PrepareEmptyTables()
+
+	addCompanyToDB(t, "ABC Enterprise", "001", "India", "https://www.abc.com", "990100000")
+
+	company, _ := model.NewCompany("XYZ Enterprise", "001", "US", "https://www.xyz.com", "800100009")
+	if err := testApplication.Application.DB.Create(company).Error; err == nil {
+		t.Errorf("expected unique constraint violation when inserting duplicated code")
+	} else if !repository.IsUniqueViolation(err) {
+		t.Errorf("expected unique constraint violation, got %v", err)
+	}
+
+	// the transaction is rolled back before the next companies are added
+	uow := repository.NewUnitOfWork(testApplication.Application.DB, false)
+	company, _ = model.NewCompany("XYZ Enterprise", "001", "US", "https://www.xyz.com", "800100009")
+	if err := repository.NewRepository().Add(uow, company); err == nil || !err.IsUniqueConstraintError() {
+		t.Errorf("expected the repository to report the unique constraint violation, got %v", err)
+	}
+	uow.Complete()
+
+	deletedCompany := addCompanyToDB(t, "123 Enterprise", "002", "India", "https://www.123.com", "980100010")
+	deleteCompanyFromDB(t, deletedCompany)
+	addCompanyToDB(t, "456 Enterprise", "002", "India", "https://www.456.com", "980100011")
+}
+
+// racingRepository misses the companies with the same code once, as when another request takes the code between the
+// conflict check and the write
+type racingRepository struct {
+	repository.Repository
+	races int
+}
+
+func (r *racingRepository) GetAll(uow *repository.UnitOfWork, out interface{}, queryProcessors []repository.QueryProcessor) apiError.DatabaseError {
+	if r.races > 0 {
+		r.races--
+		return nil
+	}
+	return r.Repository.GetAll(uow, out, queryProcessors)
+}
+
+func TestCompanyCodeConflictOnUniqueIndex(t *testing.T) {
+	testApplication.PrepareEmptyTables()
+
+	existingCompany := addCompanyToDB(t, "ABC Enterprise", "001", "India", "https://www.abc.com", "990100000")
+
+	companyRepository := &racingRepository{Repository: repository.NewRepository(), races: 1}
+	companyController := controller.NewCompanyController(testApplication.Application, nil, companyRepository, nil, nil)
+	report, err := companyController.Import(strings.NewReader("name,code,country,website,phone\nXYZ Enterprise,001,US,https://www.xyz.com,800100009\n"), controller.ImportOptions{Format: controller.ImportFormatCSV})
+	if err != nil {
+		t.Fatalf("unable to import: %v", err)
+	}
+
+	if report.Failed != 1 || len(report.Errors) != 1 {
+		t.Fatalf("expected the row to fail, got %+v", report)
+	}
+	conflictError, ok := report.Errors[0].Error.(apiError.ConflictError)
+	if !ok {
+		t.Fatalf("expected conflict error, got %+v", report.Errors[0].Error)
+	}
+	if conflictError.Errors["code"] != apiError.ErrorCodeAlreadyExists || conflictError.Location != "/api/companies/"+existingCompany.ID.String() {
+		t.Errorf("expected conflict on code at the existing company, got %+v", conflictError)
+	}
+}
@@ -4,8 +4,8 @@ import (
 	"fmt"
 	uuid "github.com/satori/go.uuid"
 	"net/http"
-	"os"
 	"testing"
+	"xm/app"
 )
 
 func TestDeleteCompany(t *testing.T) {
@@ -35,16 +35,16 @@ func TestDeleteCompany(t *testing.T) {
 			http.StatusUnauthorized,
 		},
 	}
+	otherOriginRouter := testApplication.NewRouter(func(config *app.Config) { config.OriginCountry = "US" })
 	for _, tt := range tests {
-		os.Unsetenv("ORIGIN_COUNTRY")
-
 		t.Run(tt.name, func(t *testing.T) {
 
+			router := testApplication.Application.Router
 			if tt.setInvalidRequestOrigin {
-				os.Setenv("ORIGIN_COUNTRY", "US")
+				router = otherOriginRouter
 			}
 
-			response := callAPI(http.MethodDelete, fmt.Sprintf("/api/companies/%s", tt.companyID), nil)
+			response := callRouter(router, http.MethodDelete, fmt.Sprintf("/api/companies/%s", tt.companyID), nil, nil)
 
 			checkResponseCode(t, tt.wantHttpStatusCode, response.Code)
 
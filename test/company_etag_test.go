@@ -0,0 +1,52 @@
+package test
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestCompanyEntityTags(t *testing.T) {
+	testApplication.PrepareEmptyTables()
+
+	company := addCompanyToDB(t, "ABC Enterprise", "001", "India", "https://www.abc.com", "990100000")
+	companyURL := fmt.Sprintf("/api/companies/%s", company.ID)
+
+	payload := companyDTO{Name: "ABC Enterprise 001", Code: "001", Country: "India", Website: "https://www.abc.com", Phone: "990100000"}
+	mergePatch := map[string]string{"Content-Type": "application/merge-patch+json"}
+
+	tests := []struct {
+		name          string
+		httpMethod    string
+		req           interface{}
+		headers       map[string]string
+		wantCode      int
+		wantEntityTag string
+	}{
+		{"+ve:ShouldReturnEntityTag", http.MethodGet, nil, nil, http.StatusOK, `"1"`},
+		{"+ve:ShouldReturnNotModifiedWhenEntityTagMatches", http.MethodGet, nil, map[string]string{"If-None-Match": `"1"`}, http.StatusNotModified, `"1"`},
+		{"+ve:ShouldReturnNotModifiedWhenWeakEntityTagMatches", http.MethodGet, nil, map[string]string{"If-None-Match": `W/"1", "5"`}, http.StatusNotModified, `"1"`},
+		{"+ve:ShouldReturnCompanyWhenEntityTagDoesntMatch", http.MethodGet, nil, map[string]string{"If-None-Match": `"5"`}, http.StatusOK, `"1"`},
+		{"-ve:ShouldFailUpdateWhenEntityTagDoesntMatch", http.MethodPut, payload, map[string]string{"If-Match": `"5"`}, http.StatusPreconditionFailed, ""},
+		{"-ve:ShouldFailUpdateWhenEntityTagIsWeak", http.MethodPut, payload, map[string]string{"If-Match": `W/"1"`}, http.StatusPreconditionFailed, ""},
+		{"+ve:ShouldUpdateWhenEntityTagMatches", http.MethodPut, payload, map[string]string{"If-Match": `"1"`}, http.StatusOK, `"2"`},
+		{"-ve:ShouldFailUpdateWithStaleEntityTag", http.MethodPut, payload, map[string]string{"If-Match": `"1"`}, http.StatusPreconditionFailed, ""},
+		{"+ve:ShouldUpdateWithoutEntityTag", http.MethodPut, payload, nil, http.StatusOK, `"3"`},
+		{"-ve:ShouldFailPatchWithStaleEntityTag", http.MethodPatch, map[string]string{"code": "002"}, map[string]string{"Content-Type": "application/merge-patch+json", "If-Match": `"2"`}, http.StatusPreconditionFailed, ""},
+		{"+ve:ShouldPatchWhenEntityTagMatches", http.MethodPatch, map[string]string{"code": "002"}, map[string]string{"Content-Type": "application/merge-patch+json", "If-Match": `"3"`}, http.StatusOK, `"4"`},
+		{"+ve:ShouldPatchWhenAnyEntityTagMatches", http.MethodPatch, map[string]string{"code": "003"}, map[string]string{"Content-Type": "application/merge-patch+json", "If-Match": `*`}, http.StatusOK, `"5"`},
+		{"+ve:ShouldNotChangeVersionWhenPatchDoesntChangeAnything", http.MethodPatch, map[string]string{"code": "003"}, mergePatch, http.StatusOK, `"5"`},
+		{"+ve:ShouldReturnLatestEntityTag", http.MethodGet, nil, nil, http.StatusOK, `"5"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := callAPIWithHeaders(tt.httpMethod, companyURL, tt.req, tt.headers)
+
+			checkResponseCode(t, tt.wantCode, response.Code)
+
+			if tt.wantEntityTag != response.Header().Get("ETag") {
+				t.Errorf("expected ETag %v\nGot %v", tt.wantEntityTag, response.Header().Get("ETag"))
+			}
+		})
+	}
+}
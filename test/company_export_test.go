@@ -0,0 +1,144 @@
+package test
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestExportCompanies(t *testing.T) {
+	testApplication.PrepareEmptyTables()
+
+	testDataCompany1 := addCompanyToDB(t, "Delta Enterprise", "001", "India", "https://www.delta.com", "990100000")
+	testDataCompany2 := addCompanyToDB(t, "Alpha, Enterprise", "002", "US", "https://www.alpha.com", "800100009")
+	testDataCompany3 := addCompanyToDB(t, "Charlie Enterprise", "003", "India", "https://www.charlie.com", "980100010")
+	deletedCompany := addCompanyToDB(t, "Bravo Enterprise", "004", "India", "https://www.bravo.com", "980100011")
+	deleteCompanyFromDB(t, deletedCompany)
+
+	csvHeader := []string{"id", "name", "code", "country", "website", "phone"}
+
+	tests := []struct {
+		name                string
+		query               string
+		accept              string
+		wantContentType     string
+		wantFilename        string
+		wantRecords         [][]string
+		wantNDJSONCompanies []string
+	}{
+		{"+ve:ShouldExportCSV",
+			"",
+			"text/csv",
+			"text/csv",
+			`attachment; filename="companies.csv"`,
+			[][]string{
+				csvHeader,
+				{testDataCompany1.ID.String(), "Delta Enterprise", "001", "India", "https://www.delta.com", "990100000"},
+				{testDataCompany2.ID.String(), "Alpha, Enterprise", "002", "US", "https://www.alpha.com", "800100009"},
+				{testDataCompany3.ID.String(), "Charlie Enterprise", "003", "India", "https://www.charlie.com", "980100010"},
+			},
+			nil,
+		},
+		{"+ve:ShouldExportFilteredAndSortedCSV",
+			"country=India&sort=-name",
+			"text/csv;q=0.9, application/json;q=0.5",
+			"text/csv",
+			`attachment; filename="companies.csv"`,
+			[][]string{
+				csvHeader,
+				{testDataCompany1.ID.String(), "Delta Enterprise", "001", "India", "https://www.delta.com", "990100000"},
+				{testDataCompany3.ID.String(), "Charlie Enterprise", "003", "India", "https://www.charlie.com", "980100010"},
+			},
+			nil,
+		},
+		{"+ve:ShouldExportHeaderOnlyWhenNothingMatches",
+			"country=Cyprus",
+			"text/csv",
+			"text/csv",
+			`attachment; filename="companies.csv"`,
+			[][]string{csvHeader},
+			nil,
+		},
+		{"+ve:ShouldExportNDJSON",
+			"code[ne]=001",
+			"application/x-ndjson",
+			"application/x-ndjson",
+			`attachment; filename="companies.ndjson"`,
+			nil,
+			[]string{testDataCompany2.ID.String(), testDataCompany3.ID.String()},
+		},
+		{"+ve:ShouldExportDeletedNDJSON",
+			"deleted=only",
+			"application/x-ndjson",
+			"application/x-ndjson",
+			`attachment; filename="companies.ndjson"`,
+			nil,
+			[]string{deletedCompany.ID.String()},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := callAPIWithHeaders(http.MethodGet, "/api/companies?"+tt.query, nil, map[string]string{"Accept": tt.accept})
+
+			checkResponseCode(t, http.StatusOK, response.Code)
+
+			if contentType := response.Header().Get("Content-Type"); tt.wantContentType != contentType {
+				t.Errorf("expected content type %v, got %v", tt.wantContentType, contentType)
+			}
+			if filename := response.Header().Get("Content-Disposition"); tt.wantFilename != filename {
+				t.Errorf("expected content disposition %v, got %v", tt.wantFilename, filename)
+			}
+
+			if tt.wantRecords != nil {
+				records, err := csv.NewReader(response.Body).ReadAll()
+				if err != nil {
+					t.Errorf("unable to parse response: %v", err)
+					return
+				}
+				if len(tt.wantRecords) != len(records) {
+					t.Errorf("expected records %v\nGot %v", tt.wantRecords, records)
+					return
+				}
+				for index, record := range records {
+					if strings.Join(tt.wantRecords[index], "|") != strings.Join(record, "|") {
+						t.Errorf("expected record %v at %d\nGot %v", tt.wantRecords[index], index, record)
+					}
+				}
+				return
+			}
+
+			var ids []string
+			scanner := bufio.NewScanner(response.Body)
+			for scanner.Scan() {
+				var responseDto companyDTO
+				if err := json.Unmarshal(scanner.Bytes(), &responseDto); err != nil {
+					t.Errorf("unable to parse response line %v: %v", scanner.Text(), err)
+					return
+				}
+				ids = append(ids, responseDto.ID)
+			}
+			if strings.Join(tt.wantNDJSONCompanies, ",") != strings.Join(ids, ",") {
+				t.Errorf("expected companies %v\nGot %v", tt.wantNDJSONCompanies, ids)
+			}
+		})
+	}
+}
+
+func TestExportCompaniesFallsBackToJSON(t *testing.T) {
+	testApplication.PrepareEmptyTables()
+	addCompanyToDB(t, "ABC Enterprise", "001", "India", "https://www.abc.com", "990100000")
+
+	response := callAPIWithHeaders(http.MethodGet, "/api/companies", nil, map[string]string{"Accept": "application/json, text/csv;q=0.5"})
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var responsePage companyPageDTO
+	if err := json.Unmarshal(response.Body.Bytes(), &responsePage); err != nil || len(responsePage.Items) != 1 {
+		t.Errorf("expected page of companies, got %v", response.Body.String())
+	}
+
+	response = callAPIWithHeaders(http.MethodGet, "/api/companies?sort=unknown", nil, map[string]string{"Accept": "text/csv"})
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+}
@@ -0,0 +1,91 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+	apiError "xm/error"
+	"xm/model"
+)
+
+func TestGetAllFiltered(t *testing.T) {
+	testApplication.PrepareEmptyTables()
+
+	testDataCompany1 := addCompanyToDB(t, "Acme Enterprise", "001", "CY", "https://www.acme.com", "990100000")
+	testDataCompany2 := addCompanyToDB(t, "Acme Holdings", "002", "GR", "https://www.acme-holdings.com", "800100009")
+	testDataCompany3 := addCompanyToDB(t, "XYZ Enterprise", "003", "US", "https://www.xyz.com", "980100010")
+
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+
+	tests := []struct {
+		name              string
+		query             string
+		expectedCompanies []*model.Company
+	}{
+		{"+ve:ShouldFilterWithLikeOperator", "name[like]=acme*", []*model.Company{testDataCompany1, testDataCompany2}},
+		{"+ve:ShouldFilterWithLikeOperatorIgnoringCase", "name[like]=ACME*", []*model.Company{testDataCompany1, testDataCompany2}},
+		{"+ve:ShouldMatchUnderscoreLiterally", "name[like]=acm_*", []*model.Company{}},
+		{"+ve:ShouldMatchPercentLiterally", "name[like]=%25", []*model.Company{}},
+		{"+ve:ShouldFilterWithInOperator", "country[in]=CY,GR", []*model.Company{testDataCompany1, testDataCompany2}},
+		{"+ve:ShouldFilterWithNotInOperator", "country[nin]=CY,GR", []*model.Company{testDataCompany3}},
+		{"+ve:ShouldFilterWithNotEqualOperator", "code[ne]=001", []*model.Company{testDataCompany2, testDataCompany3}},
+		{"+ve:ShouldFilterWithEqualOperator", "code[eq]=002", []*model.Company{testDataCompany2}},
+		{"+ve:ShouldFilterWithGreaterThanOperator", "code[gt]=001", []*model.Company{testDataCompany2, testDataCompany3}},
+		{"+ve:ShouldFilterWithLessThanOrEqualOperator", "code[lte]=002", []*model.Company{testDataCompany1, testDataCompany2}},
+		{"+ve:ShouldFilterWithDateOperator", "createdOn[gte]=2024-01-01", []*model.Company{testDataCompany1, testDataCompany2, testDataCompany3}},
+		{"+ve:ShouldFilterWithFutureDateOperator", "createdOn[gte]=" + tomorrow, []*model.Company{}},
+		{"+ve:ShouldCombineFilters", "name[like]=*enterprise&country[ne]=US", []*model.Company{testDataCompany1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := callAPI(http.MethodGet, "/api/companies?"+tt.query, nil)
+
+			checkResponseCode(t, http.StatusOK, response.Code)
+
+			var responsePage companyPageDTO
+			if err := json.Unmarshal(response.Body.Bytes(), &responsePage); err != nil {
+				t.Errorf("unable to parse response: %v", err)
+				return
+			}
+
+			if len(tt.expectedCompanies) != len(responsePage.Items) {
+				t.Errorf("expected count of companies %d, got %v", len(tt.expectedCompanies), len(responsePage.Items))
+				return
+			}
+
+			for index, responseDto := range responsePage.Items {
+				if tt.expectedCompanies[index].ID.String() != responseDto.ID {
+					t.Errorf("expected company %v at %d\nGot %v", tt.expectedCompanies[index].Name, index, responseDto.Name)
+					return
+				}
+			}
+		})
+	}
+}
+
+func TestGetAllFilteredWithInvalidFilters(t *testing.T) {
+	testApplication.PrepareEmptyTables()
+
+	tests := []struct {
+		name       string
+		query      string
+		errorField string
+		errorCode  string
+	}{
+		{"-ve:ShouldFailWhenFieldIsUnknown", "revenue=100", "revenue", apiError.ErrorCodeUnknownField},
+		{"-ve:ShouldFailWhenFieldWithOperatorIsUnknown", "revenue[gt]=100", "revenue[gt]", apiError.ErrorCodeUnknownField},
+		{"-ve:ShouldFailWhenOperatorIsUnknown", "name[between]=a", "name[between]", apiError.ErrorCodeUnknownOperator},
+		{"-ve:ShouldFailWhenParameterIsMalformed", "name[like", "name[like", apiError.ErrorCodeUnknownField},
+		{"-ve:ShouldFailWhenDateIsInvalid", "createdOn[gte]=yesterday", "createdOn[gte]", apiError.ErrorCodeInvalidValue},
+		{"-ve:ShouldFailWhenLikeIsUsedOnDate", "createdOn[like]=2024*", "createdOn[like]", apiError.ErrorCodeInvalidValue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := callAPI(http.MethodGet, "/api/companies?"+tt.query, nil)
+
+			checkResponseCode(t, http.StatusBadRequest, response.Code)
+			assertErrorResponse(t, response, apiError.ErrorCodeInvalidFields, tt.errorField, tt.errorCode)
+		})
+	}
+}
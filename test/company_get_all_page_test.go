@@ -0,0 +1,118 @@
+package test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	apiError "xm/error"
+	"xm/model"
+)
+
+func TestGetAllPaginated(t *testing.T) {
+	testApplication.PrepareEmptyTables()
+
+	testDataCompany1 := addCompanyToDB(t, "Delta Enterprise", "001", "India", "https://www.delta.com", "990100000")
+	testDataCompany2 := addCompanyToDB(t, "Alpha Enterprise", "002", "US", "https://www.alpha.com", "800100009")
+	testDataCompany3 := addCompanyToDB(t, "Charlie Enterprise", "003", "India", "https://www.charlie.com", "980100010")
+	testDataCompany4 := addCompanyToDB(t, "Bravo Enterprise", "004", "India", "https://www.bravo.com", "980100011")
+	deletedCompany := addCompanyToDB(t, "Echo Enterprise", "005", "US", "https://www.echo.com", "800100010")
+	deleteCompanyFromDB(t, deletedCompany)
+
+	tests := []struct {
+		name              string
+		query             string
+		expectedCompanies []*model.Company
+		expectedTotal     int64
+	}{
+		{"+ve:ShouldGetPagesInCreationOrder", "limit=3", []*model.Company{testDataCompany1, testDataCompany2, testDataCompany3, testDataCompany4}, 4},
+		{"+ve:ShouldGetPagesSortedByName", "limit=3&sort=name", []*model.Company{testDataCompany2, testDataCompany4, testDataCompany3, testDataCompany1}, 4},
+		{"+ve:ShouldGetPagesSortedByNameDescending", "limit=1&sort=-name", []*model.Company{testDataCompany1, testDataCompany3, testDataCompany4, testDataCompany2}, 4},
+		{"+ve:ShouldGetPagesSortedByCountryThenName", "limit=2&sort=-country,name", []*model.Company{testDataCompany2, testDataCompany4, testDataCompany3, testDataCompany1}, 4},
+		{"+ve:ShouldGetFilteredPages", "limit=1&sort=-createdOn&country=India", []*model.Company{testDataCompany4, testDataCompany3, testDataCompany1}, 3},
+		{"+ve:ShouldGetPagesPastNullValues", "limit=1&deleted=include&sort=deletedOn,createdOn", []*model.Company{testDataCompany1, testDataCompany2, testDataCompany3, testDataCompany4, deletedCompany}, 5},
+		{"+ve:ShouldGetPagesPastNullValuesDescending", "limit=2&deleted=include&sort=-deletedOn,-createdOn", []*model.Company{deletedCompany, testDataCompany4, testDataCompany3, testDataCompany2, testDataCompany1}, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var responseDTOs []companyDTO
+			var cursor *string
+			for pages := 0; pages <= len(tt.expectedCompanies); pages++ {
+				apiURL := "/api/companies?" + tt.query
+				if cursor != nil {
+					apiURL += "&cursor=" + url.QueryEscape(*cursor)
+				}
+				response := callAPI(http.MethodGet, apiURL, nil)
+
+				checkResponseCode(t, http.StatusOK, response.Code)
+
+				var responsePage companyPageDTO
+				if err := json.Unmarshal(response.Body.Bytes(), &responsePage); err != nil {
+					t.Errorf("unable to parse response: %v", err)
+					return
+				}
+
+				if tt.expectedTotal != responsePage.TotalCount {
+					t.Errorf("expected total count of companies %d, got %v", tt.expectedTotal, responsePage.TotalCount)
+					return
+				}
+
+				responseDTOs = append(responseDTOs, responsePage.Items...)
+				cursor = responsePage.NextCursor
+				if cursor == nil {
+					break
+				}
+			}
+
+			if len(tt.expectedCompanies) != len(responseDTOs) {
+				t.Errorf("expected count of companies %d, got %v", len(tt.expectedCompanies), len(responseDTOs))
+				return
+			}
+
+			for index, responseDto := range responseDTOs {
+				if tt.expectedCompanies[index].ID.String() != responseDto.ID {
+					t.Errorf("expected company %v at %d\nGot %v", tt.expectedCompanies[index].Name, index, responseDto.Name)
+					return
+				}
+			}
+		})
+	}
+}
+
+func TestGetAllPaginatedWithInvalidParameters(t *testing.T) {
+	testApplication.PrepareEmptyTables()
+
+	addCompanyToDB(t, "ABC Enterprise", "001", "India", "https://www.abc.com", "990100000")
+	addCompanyToDB(t, "XYZ Enterprise", "002", "US", "https://www.xyz.com", "800100009")
+
+	response := callAPI(http.MethodGet, "/api/companies?limit=1&sort=name", nil)
+	checkResponseCode(t, http.StatusOK, response.Code)
+	var responsePage companyPageDTO
+	if err := json.Unmarshal(response.Body.Bytes(), &responsePage); err != nil || responsePage.NextCursor == nil {
+		t.Fatalf("expected cursor of next page, got %v", response.Body.String())
+	}
+
+	tests := []struct {
+		name       string
+		query      string
+		errorField string
+	}{
+		{"-ve:ShouldFailWhenLimitIsNotANumber", "limit=abc", "limit"},
+		{"-ve:ShouldFailWhenLimitIsZero", "limit=0", "limit"},
+		{"-ve:ShouldFailWhenLimitIsTooLarge", "limit=100000", "limit"},
+		{"-ve:ShouldFailWhenSortFieldIsUnknown", "sort=-unknown", "sort"},
+		{"-ve:ShouldFailWhenCursorIsMalformed", "cursor=abc", "cursor"},
+		{"-ve:ShouldFailWhenCursorValueIsNull", "cursor=" + base64.RawURLEncoding.EncodeToString([]byte(`{"s":"createdOn","v":[null,null]}`)), "cursor"},
+		{"-ve:ShouldFailWhenCursorIsUsedWithAnotherSort", fmt.Sprintf("sort=code&cursor=%s", url.QueryEscape(*responsePage.NextCursor)), "cursor"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := callAPI(http.MethodGet, "/api/companies?"+tt.query, nil)
+
+			checkResponseCode(t, http.StatusBadRequest, response.Code)
+			assertErrorResponse(t, response, apiError.ErrorCodeInvalidFields, tt.errorField, apiError.ErrorCodeInvalidValue)
+		})
+	}
+}
@@ -44,11 +44,17 @@ func TestGetAll(t *testing.T) {
 
 			checkResponseCode(t, http.StatusOK, response.Code)
 
-			var responseDTOs []companyDTO
-			if err := json.Unmarshal(response.Body.Bytes(), &responseDTOs); err != nil {
+			var responsePage companyPageDTO
+			if err := json.Unmarshal(response.Body.Bytes(), &responsePage); err != nil {
 				t.Errorf("unable to parse response: %v", err)
 				return
 			}
+			responseDTOs := responsePage.Items
+
+			if int64(len(tt.expectedCompanies)) != responsePage.TotalCount {
+				t.Errorf("expected total count of companies %d, got %v", len(tt.expectedCompanies), responsePage.TotalCount)
+				return
+			}
 
 			if len(tt.expectedCompanies) != len(responseDTOs) {
 				t.Errorf("expected count of companies %d, got %v", len(tt.expectedCompanies), len(responseDTOs))
@@ -0,0 +1,203 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	apiError "xm/error"
+)
+
+type importRowError struct {
+	Line  int                      `json:"line"`
+	Error apiError.ValidationError `json:"error"`
+}
+
+type importReport struct {
+	DryRun  bool             `json:"dryRun"`
+	Rows    int              `json:"rows"`
+	Created int              `json:"created"`
+	Updated int              `json:"updated"`
+	Failed  int              `json:"failed"`
+	Errors  []importRowError `json:"errors"`
+}
+
+func TestImportCompanies(t *testing.T) {
+	tests := []struct {
+		name            string
+		query           string
+		contentType     string
+		body            string
+		wantReport      importReport
+		wantErrorLines  []int
+		wantStoredNames []string
+	}{
+		{"+ve:ShouldImportCSV",
+			"",
+			"text/csv",
+			"name,code,country,website,phone\n" +
+				"ABC Enterprise,002,India,https://www.abc.com,990100000\n" +
+				"XYZ Enterprise,003,India,https://www.xyz.com,990100001\n",
+			importReport{Rows: 2, Created: 2},
+			nil,
+			[]string{"Existing Enterprise", "ABC Enterprise", "XYZ Enterprise"},
+		},
+		{"+ve:ShouldImportNDJSON",
+			"",
+			"application/x-ndjson",
+			`{"name":"ABC Enterprise","code":"002","country":"India","website":"https://www.abc.com","phone":"990100000"}` + "\n\n" +
+				`{"name":"XYZ Enterprise","code":"003","country":"India","website":"https://www.xyz.com","phone":"990100001"}` + "\n",
+			importReport{Rows: 2, Created: 2},
+			nil,
+			[]string{"Existing Enterprise", "ABC Enterprise", "XYZ Enterprise"},
+		},
+		{"+ve:ShouldReportFailedRowsByLine",
+			"",
+			"text/csv",
+			"name,code,country,website,phone\n" +
+				"ABC Enterprise,002,India,abc,990100000\n" +
+				"XYZ Enterprise,003,India,https://www.xyz.com,990100001\n" +
+				"Duplicate Enterprise,001,India,https://www.duplicate.com,990100002\n" +
+				"Short Enterprise,004\n",
+			importReport{Rows: 4, Created: 1, Failed: 3},
+			[]int{2, 4, 5},
+			[]string{"Existing Enterprise", "XYZ Enterprise"},
+		},
+		{"+ve:ShouldUpdateCompanyWithSameCodeWhenUpserting",
+			"upsert=true",
+			"text/csv",
+			"name,code,country,website,phone\n" +
+				"Renamed Enterprise,001,India,https://www.renamed.com,990100000\n" +
+				"XYZ Enterprise,003,India,https://www.xyz.com,990100001\n",
+			importReport{Rows: 2, Created: 1, Updated: 1},
+			nil,
+			[]string{"Renamed Enterprise", "XYZ Enterprise"},
+		},
+		{"+ve:ShouldNotWriteOnDryRun",
+			"dryRun=true&upsert=true",
+			"text/csv",
+			"name,code,country,website,phone\n" +
+				"Renamed Enterprise,001,India,https://www.renamed.com,990100000\n" +
+				"XYZ Enterprise,003,India,https://www.xyz.com,990100001\n" +
+				"Invalid Enterprise,004,India,xyz,990100001\n",
+			importReport{DryRun: true, Rows: 3, Created: 1, Updated: 1, Failed: 1},
+			[]int{4},
+			[]string{"Existing Enterprise"},
+		},
+		{"+ve:ShouldReportDuplicateCodesInFile",
+			"",
+			"text/csv",
+			"name,code,country,website,phone\n" +
+				"ABC Enterprise,002,India,https://www.abc.com,990100000\n" +
+				"XYZ Enterprise,002,India,https://www.xyz.com,990100001\n",
+			importReport{Rows: 2, Created: 1, Failed: 1},
+			[]int{3},
+			[]string{"Existing Enterprise", "ABC Enterprise"},
+		},
+		{"+ve:ShouldReportDuplicateCodesInFileOnDryRun",
+			"dryRun=true",
+			"text/csv",
+			"name,code,country,website,phone\n" +
+				"ABC Enterprise,002,India,https://www.abc.com,990100000\n" +
+				"XYZ Enterprise,002,India,https://www.xyz.com,990100001\n",
+			importReport{DryRun: true, Rows: 2, Created: 1, Failed: 1},
+			[]int{3},
+			[]string{"Existing Enterprise"},
+		},
+		{"+ve:ShouldUpdateDuplicateCodesInFileOnDryRunWhenUpserting",
+			"dryRun=true&upsert=true",
+			"text/csv",
+			"name,code,country,website,phone\n" +
+				"ABC Enterprise,002,India,https://www.abc.com,990100000\n" +
+				"XYZ Enterprise,002,India,https://www.xyz.com,990100001\n",
+			importReport{DryRun: true, Rows: 2, Created: 1, Updated: 1},
+			nil,
+			[]string{"Existing Enterprise"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testApplication.PrepareEmptyTables()
+			addCompanyToDB(t, "Existing Enterprise", "001", "India", "https://www.existing.com", "990100000")
+
+			response := callAPIWithBody(http.MethodPost, "/api/companies:import?"+tt.query, tt.contentType, tt.body)
+
+			checkResponseCode(t, http.StatusOK, response.Code)
+
+			var report importReport
+			if err := json.Unmarshal(response.Body.Bytes(), &report); err != nil {
+				t.Errorf("unable to parse response: %v", err)
+				return
+			}
+
+			if tt.wantReport.DryRun != report.DryRun || tt.wantReport.Rows != report.Rows || tt.wantReport.Created != report.Created ||
+				tt.wantReport.Updated != report.Updated || tt.wantReport.Failed != report.Failed {
+				t.Errorf("expected report %+v\nGot %+v", tt.wantReport, report)
+				return
+			}
+
+			if len(tt.wantErrorLines) != len(report.Errors) {
+				t.Errorf("expected errors on lines %v\nGot %+v", tt.wantErrorLines, report.Errors)
+				return
+			}
+			for index, rowError := range report.Errors {
+				if tt.wantErrorLines[index] != rowError.Line {
+					t.Errorf("expected errors on lines %v\nGot %+v", tt.wantErrorLines, report.Errors)
+					return
+				}
+			}
+
+			var storedNames []string
+			testApplication.Application.DB.Table("companies").Where(`"deletedOn" IS NULL`).Order(`"createdOn"`).Pluck("name", &storedNames)
+			if len(tt.wantStoredNames) != len(storedNames) {
+				t.Errorf("expected stored names %v\nGot %v", tt.wantStoredNames, storedNames)
+				return
+			}
+			for index, name := range storedNames {
+				if tt.wantStoredNames[index] != name {
+					t.Errorf("expected stored names %v\nGot %v", tt.wantStoredNames, storedNames)
+					return
+				}
+			}
+		})
+	}
+}
+
+func TestImportCompaniesWithInvalidRequest(t *testing.T) {
+	testApplication.PrepareEmptyTables()
+
+	response := callAPIWithBody(http.MethodPost, "/api/companies:import", "application/xml", "<companies/>")
+	checkResponseCode(t, http.StatusUnsupportedMediaType, response.Code)
+
+	response = callAPIWithBody(http.MethodPost, "/api/companies:import?dryRun=yes", "text/csv", "name\n")
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+	assertErrorResponse(t, response, apiError.ErrorCodeInvalidFields, "dryRun", apiError.ErrorCodeInvalidValue)
+
+	response = callAPIWithBody(http.MethodPost, "/api/companies:import", "text/csv", "name,revenue\nABC Enterprise,100\n")
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+	assertErrorResponse(t, response, apiError.ErrorCodeInvalidFields, "revenue", apiError.ErrorCodeUnknownField)
+
+	response = callAPIWithBody(http.MethodPost, "/api/companies:import", "text/csv", "")
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+	assertErrorResponse(t, response, apiError.ErrorCodeInvalidRequestPayload, "payload", apiError.ErrorCodeEmptyRequestBody)
+}
+
+func TestImportCompaniesWithUnreadableFile(t *testing.T) {
+	testApplication.PrepareEmptyTables()
+
+	body := `{"name":"ABC Enterprise","code":"002","country":"India","website":"https://www.abc.com","phone":"990100000"}` + "\n" +
+		`{"name":"` + strings.Repeat("X", 70*1024) + `"}` + "\n"
+	response := callAPIWithBody(http.MethodPost, "/api/companies:import", "application/x-ndjson", body)
+
+	checkResponseCode(t, http.StatusInternalServerError, response.Code)
+	var report struct {
+		importReport
+		Error map[string]string `json:"error"`
+	}
+	if err := json.Unmarshal(response.Body.Bytes(), &report); err != nil {
+		t.Fatalf("unable to parse response: %v", err)
+	}
+	if report.Rows != 1 || report.Created != 1 || report.Error["error"] != apiError.ErrorCodeInternalError {
+		t.Errorf("expected the report of the imported row with the error, got %s", response.Body.String())
+	}
+}
@@ -0,0 +1,165 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	uuid "github.com/satori/go.uuid"
+	"net/http"
+	"testing"
+	apiError "xm/error"
+)
+
+func TestPatchCompany(t *testing.T) {
+	testApplication.PrepareEmptyTables()
+
+	company := addCompanyToDB(t, "ABC Enterprise", "001", "India", "https://www.abc.com", "990100000")
+
+	type errData struct {
+		errKey string
+		err    map[string]string
+	}
+
+	const mergePatch = "application/merge-patch+json"
+	const jsonPatch = "application/json-patch+json"
+
+	testCases := []struct {
+		name        string
+		companyID   string
+		contentType string
+		patch       interface{}
+		wantCode    int
+		want        *companyDTO
+		wantErr     *errData
+	}{
+		{"+ve:ShouldMergePatchCompany",
+			company.ID.String(),
+			mergePatch,
+			map[string]interface{}{"name": "ABC Enterprise 001"},
+			http.StatusOK,
+			&companyDTO{
+				ID:      company.ID.String(),
+				Name:    "ABC Enterprise 001",
+				Code:    "001",
+				Country: "India",
+				Website: "https://www.abc.com",
+				Phone:   "990100000",
+			},
+			nil,
+		},
+		{"+ve:ShouldJSONPatchCompany",
+			company.ID.String(),
+			jsonPatch,
+			[]map[string]interface{}{
+				{"op": "test", "path": "/code", "value": "001"},
+				{"op": "replace", "path": "/code", "value": "002"},
+				{"op": "replace", "path": "/website", "value": "https://www.abc1.com"},
+			},
+			http.StatusOK,
+			&companyDTO{
+				ID:      company.ID.String(),
+				Name:    "ABC Enterprise 001",
+				Code:    "002",
+				Country: "India",
+				Website: "https://www.abc1.com",
+				Phone:   "990100000",
+			},
+			nil,
+		},
+		{"-ve:ShouldFailWhenNonExistingIDPassed",
+			uuid.NewV4().String(),
+			mergePatch,
+			map[string]interface{}{"name": "ABC Enterprise 002"},
+			http.StatusNotFound,
+			nil,
+			nil,
+		},
+		{"-ve:ShouldFailWhenContentTypeIsNotSupported",
+			company.ID.String(),
+			"application/json",
+			map[string]interface{}{"name": "ABC Enterprise 002"},
+			http.StatusUnsupportedMediaType,
+			nil,
+			nil,
+		},
+		{"-ve:ShouldFailWhenMergePatchRemovesName",
+			company.ID.String(),
+			mergePatch,
+			map[string]interface{}{"name": nil},
+			http.StatusBadRequest,
+			nil,
+			&errData{apiError.ErrorCodeInvalidFields, map[string]string{"name": apiError.ErrorCodeRequired}},
+		},
+		{"-ve:ShouldFailWhenMergePatchSetsInvalidWebsite",
+			company.ID.String(),
+			mergePatch,
+			map[string]interface{}{"website": "abc.com"},
+			http.StatusBadRequest,
+			nil,
+			&errData{apiError.ErrorCodeInvalidFields, map[string]string{"website": apiError.ErrorCodeInvalidValue}},
+		},
+		{"-ve:ShouldFailWhenPatchChangesID",
+			company.ID.String(),
+			mergePatch,
+			map[string]interface{}{"id": uuid.NewV4().String()},
+			http.StatusBadRequest,
+			nil,
+			&errData{apiError.ErrorCodeInvalidFields, map[string]string{"id": apiError.ErrorCodeInvalidValue}},
+		},
+		{"-ve:ShouldFailWhenJSONPatchTestFails",
+			company.ID.String(),
+			jsonPatch,
+			[]map[string]interface{}{
+				{"op": "test", "path": "/code", "value": "001"},
+				{"op": "replace", "path": "/code", "value": "003"},
+			},
+			http.StatusBadRequest,
+			nil,
+			&errData{apiError.ErrorCodeInvalidRequestPayload, map[string]string{"payload": apiError.ErrorCodeInvalidPatch}},
+		},
+		{"-ve:ShouldFailWhenJSONPatchIsMalformed",
+			company.ID.String(),
+			jsonPatch,
+			map[string]interface{}{"name": "ABC Enterprise 002"},
+			http.StatusBadRequest,
+			nil,
+			&errData{apiError.ErrorCodeInvalidRequestPayload, map[string]string{"payload": apiError.ErrorCodeInvalidJSON}},
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			response := callAPIWithHeaders(http.MethodPatch, fmt.Sprintf("/api/companies/%s", tt.companyID), tt.patch, map[string]string{"Content-Type": tt.contentType})
+
+			checkResponseCode(t, tt.wantCode, response.Code)
+
+			if tt.wantErr != nil {
+				wantErr := *tt.wantErr
+				for errField, errMsg := range wantErr.err {
+					assertErrorResponse(t, response, wantErr.errKey, errField, errMsg)
+				}
+				return
+			}
+
+			if tt.want == nil {
+				return
+			}
+
+			var responseDto companyDTO
+			if err := json.Unmarshal(response.Body.Bytes(), &responseDto); err != nil {
+				t.Errorf("unable to parse response: %v", err)
+				return
+			}
+
+			if *tt.want != responseDto {
+				t.Errorf("expected company %v\nGot %v", *tt.want, responseDto)
+				return
+			}
+
+			_, storedCompany := getCompanyToDB(t, tt.companyID)
+			if storedCompany.Name != tt.want.Name || storedCompany.Code != tt.want.Code || storedCompany.Website != tt.want.Website {
+				t.Errorf("expected stored company %v\nGot %v", *tt.want, storedCompany)
+				return
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	uuid "github.com/satori/go.uuid"
+	"net/http"
+	"testing"
+	"xm/app"
+	"xm/model"
+)
+
+func TestGetAllDeletedCompanies(t *testing.T) {
+	testApplication.PrepareEmptyTables()
+
+	testDataCompany1 := addCompanyToDB(t, "ABC Enterprise", "001", "India", "https://www.abc.com", "990100000")
+	testDataCompany2 := addCompanyToDB(t, "XYZ Enterprise", "002", "US", "https://www.xyz.com", "800100009")
+	deleteCompanyFromDB(t, testDataCompany2)
+
+	tests := []struct {
+		name              string
+		query             string
+		wantCode          int
+		expectedCompanies []*model.Company
+	}{
+		{"+ve:ShouldNotGetDeletedCompanies", "", http.StatusOK, []*model.Company{testDataCompany1}},
+		{"+ve:ShouldGetOnlyDeletedCompanies", "deleted=only", http.StatusOK, []*model.Company{testDataCompany2}},
+		{"+ve:ShouldIncludeDeletedCompanies", "deleted=include", http.StatusOK, []*model.Company{testDataCompany1, testDataCompany2}},
+		{"-ve:ShouldFailWhenDeletedIsInvalid", "deleted=all", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := callAPI(http.MethodGet, "/api/companies?"+tt.query, nil)
+
+			checkResponseCode(t, tt.wantCode, response.Code)
+			if tt.wantCode != http.StatusOK {
+				return
+			}
+
+			var responsePage companyPageDTO
+			if err := json.Unmarshal(response.Body.Bytes(), &responsePage); err != nil {
+				t.Errorf("unable to parse response: %v", err)
+				return
+			}
+
+			if len(tt.expectedCompanies) != len(responsePage.Items) {
+				t.Errorf("expected count of companies %d, got %v", len(tt.expectedCompanies), len(responsePage.Items))
+				return
+			}
+
+			for index, responseDto := range responsePage.Items {
+				if tt.expectedCompanies[index].ID.String() != responseDto.ID {
+					t.Errorf("expected company %v at %d\nGot %v", tt.expectedCompanies[index].Name, index, responseDto.Name)
+					return
+				}
+			}
+		})
+	}
+}
+
+func TestRestoreCompany(t *testing.T) {
+	testApplication.PrepareEmptyTables()
+
+	company := addCompanyToDB(t, "ABC Enterprise", "001", "India", "https://www.abc.com", "990100000")
+	deleteCompanyFromDB(t, company)
+	activeCompany := addCompanyToDB(t, "XYZ Enterprise", "002", "US", "https://www.xyz.com", "800100009")
+
+	tests := []struct {
+		name                    string
+		setInvalidRequestOrigin bool
+		companyID               string
+		wantHttpStatusCode      int
+	}{
+		{"-ve:ShouldFailWhenInvalidRequestOrigin",
+			true,
+			company.ID.String(),
+			http.StatusUnauthorized,
+		},
+		{"+ve:ShouldRestoreCompany",
+			false,
+			company.ID.String(),
+			http.StatusOK,
+		},
+		{"-ve:ShouldFailWhenCompanyIsNotDeleted",
+			false,
+			activeCompany.ID.String(),
+			http.StatusNotFound,
+		},
+		{"-ve:ShouldFailWhenCompanyDoesntExist",
+			false,
+			uuid.NewV4().String(),
+			http.StatusNotFound,
+		},
+	}
+	otherOriginRouter := testApplication.NewRouter(func(config *app.Config) { config.OriginCountry = "US" })
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+
+			router := testApplication.Application.Router
+			if tt.setInvalidRequestOrigin {
+				router = otherOriginRouter
+			}
+
+			response := callRouter(router, http.MethodPost, fmt.Sprintf("/api/companies/%s/restore", tt.companyID), nil, nil)
+
+			checkResponseCode(t, tt.wantHttpStatusCode, response.Code)
+
+			if tt.wantHttpStatusCode == http.StatusOK {
+				exists, _ := getCompanyToDB(t, tt.companyID)
+				if !exists {
+					t.Errorf("company record not restored in db")
+				}
+			}
+		})
+	}
+}
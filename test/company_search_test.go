@@ -0,0 +1,139 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	apiError "xm/error"
+	"xm/model"
+	"xm/repository"
+)
+
+type companySearchResultDTO struct {
+	companyDTO
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"`
+}
+
+type companySearchDTO struct {
+	Items []companySearchResultDTO `json:"items"`
+}
+
+// searchCompanies searches companies and returns the names of the results, skipping the test when search is unavailable
+func searchCompanies(t *testing.T, query string) []companySearchResultDTO {
+	response := callAPI(http.MethodGet, "/api/companies/search?q="+url.QueryEscape(query), nil)
+	if response.Code == http.StatusNotImplemented {
+		t.Skip("full-text search is unavailable, run the tests with -tags sqlite_fts5")
+	}
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	var responseDTO companySearchDTO
+	if err := json.Unmarshal(response.Body.Bytes(), &responseDTO); err != nil {
+		t.Fatalf("unable to parse response: %v", err)
+	}
+	return responseDTO.Items
+}
+
+func TestSearchCompanies(t *testing.T) {
+	testApplication.PrepareEmptyTables()
+
+	addCompanyToDB(t, "Acme Enterprise", "001", "Cyprus", "https://www.enterprise.com", "990100000")
+	addCompanyToDB(t, "Globex Holdings", "002", "Greece", "https://globex.example.org", "800123456")
+	addCompanyToDB(t, "Acme Acme Trading", "003", "India", "https://www.trading.com", "980100010")
+	repository.RebuildSearchIndex(testApplication.Application.DB, &model.Company{})
+
+	tests := []struct {
+		name          string
+		query         string
+		expectedNames []string
+	}{
+		{"+ve:ShouldFindByPartialName", "cme", []string{"Acme Acme Trading", "Acme Enterprise"}},
+		{"+ve:ShouldFindByWebsiteDomain", "example.org", []string{"Globex Holdings"}},
+		{"+ve:ShouldFindByPhoneFragment", "0123", []string{"Globex Holdings"}},
+		{"+ve:ShouldMatchAllTerms", "acme india", []string{"Acme Acme Trading"}},
+		{"+ve:ShouldIgnoreQuerySyntax", `acme" OR "globex`, []string{}},
+		{"+ve:ShouldFindNothing", "initech", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := searchCompanies(t, tt.query)
+
+			if len(tt.expectedNames) != len(results) {
+				t.Errorf("expected count of companies %d, got %v", len(tt.expectedNames), len(results))
+				return
+			}
+			for index, result := range results {
+				if tt.expectedNames[index] != result.Name {
+					t.Errorf("expected company %v at %d\nGot %v", tt.expectedNames[index], index, result.Name)
+				}
+				if !strings.Contains(result.Snippet, "<mark>") {
+					t.Errorf("expected highlighted snippet, got %v", result.Snippet)
+				}
+			}
+		})
+	}
+}
+
+func TestSearchSnippetIsEscaped(t *testing.T) {
+	testApplication.PrepareEmptyTables()
+
+	addCompanyToDB(t, "<script>alert(1)</script> Acme", "001", "Cyprus", "https://www.example.com", "990100000")
+	repository.RebuildSearchIndex(testApplication.Application.DB, &model.Company{})
+
+	results := searchCompanies(t, "acme")
+
+	if len(results) != 1 {
+		t.Fatalf("expected the company to be found, got %v", results)
+	}
+	snippet := results[0].Snippet
+	if !strings.Contains(snippet, "&lt;/script&gt; <mark>Acme</mark>") || strings.Contains(snippet, "</script>") {
+		t.Errorf("expected snippet with escaped markup, got %q", snippet)
+	}
+}
+
+func TestSearchIndexIsSynchronized(t *testing.T) {
+	testApplication.PrepareEmptyTables()
+
+	response := callAPI(http.MethodPost, "/api/companies", companyDTO{Name: "Acme Enterprise", Code: "001", Country: "Cyprus", Website: "https://www.acme.com", Phone: "990100000"})
+	checkResponseCode(t, http.StatusCreated, response.Code)
+	var createdDTO companyDTO
+	json.Unmarshal(response.Body.Bytes(), &createdDTO)
+
+	if results := searchCompanies(t, "acme"); len(results) != 1 {
+		t.Fatalf("expected added company to be found, got %v", results)
+	}
+
+	response = callAPI(http.MethodPut, "/api/companies/"+createdDTO.ID, companyDTO{Name: "Initech", Code: "001", Country: "Cyprus", Website: "https://www.initech.com", Phone: "990100000"})
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	if results := searchCompanies(t, "acme"); len(results) != 0 {
+		t.Fatalf("expected updated company not to be found by its old name, got %v", results)
+	}
+	if results := searchCompanies(t, "initech"); len(results) != 1 {
+		t.Fatalf("expected updated company to be found by its new name, got %v", results)
+	}
+
+	response = callAPI(http.MethodDelete, "/api/companies/"+createdDTO.ID, nil)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	if results := searchCompanies(t, "initech"); len(results) != 0 {
+		t.Fatalf("expected deleted company not to be found, got %v", results)
+	}
+
+	response = callAPI(http.MethodPost, "/api/companies/"+createdDTO.ID+"/restore", nil)
+	checkResponseCode(t, http.StatusOK, response.Code)
+
+	if results := searchCompanies(t, "initech"); len(results) != 1 {
+		t.Fatalf("expected restored company to be found, got %v", results)
+	}
+}
+
+func TestSearchCompaniesWithInvalidQuery(t *testing.T) {
+	testApplication.PrepareEmptyTables()
+
+	response := callAPI(http.MethodGet, "/api/companies/search?q=ab", nil)
+	checkResponseCode(t, http.StatusBadRequest, response.Code)
+	assertErrorResponse(t, response, apiError.ErrorCodeInvalidFields, "q", apiError.ErrorCodeInvalidValue)
+}
@@ -0,0 +1,53 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+	"xm/app"
+)
+
+func TestHealth(t *testing.T) {
+	// the server is marked ready once it has started serving
+	for deadline := time.Now().Add(time.Second); !testApplication.Application.Ready() && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	tests := []struct {
+		name           string
+		url            string
+		expectedChecks []string
+	}{
+		{"+ve:ShouldBeAlive", "/healthz", nil},
+		{"+ve:ShouldBeReadyWithDatabase", "/readyz", []string{"database"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := callAPI(http.MethodGet, tt.url, nil)
+
+			checkResponseCode(t, http.StatusOK, response.Code)
+			var report app.HealthReport
+			json.Unmarshal(response.Body.Bytes(), &report)
+			if report.Status != app.HealthStatusOK || len(report.Checks) != len(tt.expectedChecks) {
+				t.Errorf("expected status ok with checks %v, got %s", tt.expectedChecks, response.Body.String())
+			}
+			for _, name := range tt.expectedChecks {
+				if check := report.Checks[name]; check.Status != app.HealthStatusOK || len(check.Latency) == 0 {
+					t.Errorf("expected check %s to pass with its latency, got %+v", name, check)
+				}
+			}
+		})
+	}
+}
+
+func TestVersion(t *testing.T) {
+	response := callAPI(http.MethodGet, "/version", nil)
+
+	checkResponseCode(t, http.StatusOK, response.Code)
+	var buildInfo app.BuildInfo
+	json.Unmarshal(response.Body.Bytes(), &buildInfo)
+	if buildInfo.Version != app.Version {
+		t.Errorf("expected version %s, got %s", app.Version, response.Body.String())
+	}
+}